@@ -0,0 +1,59 @@
+// Command graph is an example of a pipeline wired as a graph (see pipeline.Graph) rather than a chain: the primes below 1000
+// are found by a set of workers and split between three sinks, printing them, writing them to primes.txt and counting them. Run it with:
+//
+//	go run ./examples/graph
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+func main() {
+	g := pipeline.NewGraph()
+	candidates := pipeline.NewEdge[int64](g, "candidates")
+	primes := pipeline.NewEdge[int64](g, "primes")
+
+	pipeline.AddSource(g, "source", candidates, func(ctx context.Context) (<-chan int64, <-chan error) {
+		return pipeline.CreateValueStream(ctx, pipeline.SequentialVal(1000))
+	})
+	pipeline.AddStage(g, "workers", candidates, primes, func(ctx context.Context, in <-chan int64) (<-chan int64, <-chan error) {
+		workers := make([]<-chan int64, runtime.NumCPU())
+		errcs := make([]<-chan error, len(workers))
+		for i := range workers {
+			workers[i], errcs[i] = pipeline.PrimeNumberWorker(ctx, in, pipeline.ProbablyPrime(0), nil)
+		}
+		return pipeline.ReduceWorkers(ctx, workers), pipeline.MergeErrors(errcs...)
+	})
+	pipeline.AddSink(g, "stdout", primes, func(ctx context.Context, in <-chan int64) <-chan error {
+		return forEach(in, func(prime int64) { fmt.Println(prime) })
+	})
+	pipeline.AddSink(g, "file", primes, func(ctx context.Context, in <-chan int64) <-chan error {
+		return pipeline.SinkToFile(ctx, in, "primes.txt")
+	})
+	found := 0
+	pipeline.AddSink(g, "count", primes, func(ctx context.Context, in <-chan int64) <-chan error {
+		return forEach(in, func(int64) { found++ })
+	})
+
+	if err := g.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Found %d primes, written to primes.txt\n", found)
+}
+
+// forEach calls fn with each item of a stream, returning an error channel that is closed once the stream is
+func forEach[T any](valueStream <-chan T, fn func(T)) <-chan error {
+	errc := make(chan error)
+	go func() {
+		defer close(errc)
+		for item := range valueStream {
+			fn(item)
+		}
+	}()
+	return errc
+}
@@ -0,0 +1,65 @@
+// Command sourceplugin is an example Go plugin registering a source for the CLI's source flag. Build and use it with:
+//
+//	go build -buildmode=plugin -o progression.so ./examples/sourceplugin
+//	go run ./main -source-plugin=progression.so -source=progression -source-arg=7,30 -p=10
+//
+// The progression source draws the arithmetic progression start, start+step, start+2*step... given as start,step by the source-arg flag,
+// such as the numbers 7 mod 30 above. It ends (pipeline.ErrExhausted) before overflowing int64
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+func init() {
+	pipeline.RegisterSource("progression", newProgression)
+}
+
+// progression is the source of an arithmetic progression, safe for concurrent use as the pipeline's producers share it
+type progression struct {
+	mu   sync.Mutex
+	next int64
+	step int64
+	done bool
+}
+
+// newProgression opens a progression from an argument such as 7,30
+func newProgression(arg string) (pipeline.Source, error) {
+	startText, stepText, ok := strings.Cut(arg, ",")
+	if !ok {
+		return nil, fmt.Errorf("%w: progression takes start,step, got %q", pipeline.ErrInvalidInput, arg)
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(startText), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: progression start %q is not an int64", pipeline.ErrInvalidInput, startText)
+	}
+	step, err := strconv.ParseInt(strings.TrimSpace(stepText), 10, 64)
+	if err != nil || step < 1 {
+		return nil, fmt.Errorf("%w: progression step %q is not a positive int64", pipeline.ErrInvalidInput, stepText)
+	}
+	return &progression{next: start, step: step}, nil
+}
+
+func (p *progression) Next() (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return 0, pipeline.ErrExhausted
+	}
+	val := p.next
+	if val > math.MaxInt64-p.step {
+		p.done = true
+	} else {
+		p.next += p.step
+	}
+	return val, nil
+}
+
+// main is never run, a plugin is only opened for its init functions
+func main() {}
@@ -0,0 +1,11 @@
+;; An example filter module for the CLI's filter-wasm flag, keeping the candidates whose last decimal digit is 7. Build and use it with:
+;;
+;;   wat2wasm examples/wasmfilter/endsin7.wat -o endsin7.wasm
+;;   go run ./main -filter-wasm=endsin7.wasm -source=sequential -p=10
+;;
+;; Any language compiling to WebAssembly can export accept instead, such as TinyGo (//export accept) or Rust (#[no_mangle] pub extern "C")
+(module
+  (func (export "accept") (param $num i64) (result i32)
+    (i64.eq
+      (i64.rem_u (local.get $num) (i64.const 10))
+      (i64.const 7))))
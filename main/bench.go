@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// benchRun is one row of the bench mode's table
+type benchRun struct {
+	name     string
+	workers  int
+	duration time.Duration
+	found    int
+	tested   int64
+	allocs   uint64 // Heap objects allocated during the run
+	bytes    uint64 // Heap bytes allocated during the run
+}
+
+// discardOutput is the output of the bench mode's runs, which keeps nothing so the outputs don't weigh on the timings
+type discardOutput struct{}
+
+func (discardOutput) start(cfg config)                   {}
+func (discardOutput) prime(found pipeline.Found[result]) {}
+func (discardOutput) finish(sum summary) error           { return nil }
+
+// defaultBenchWorkers returns the worker counts the pipeline is benchmarked with when the bench-workers flag isn't set: powers of 2 up to the number of CPUs, and that number
+func defaultBenchWorkers() string {
+	var counts []string
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		counts = append(counts, strconv.Itoa(n))
+	}
+	return strings.Join(append(counts, strconv.Itoa(runtime.NumCPU())), ",")
+}
+
+// parseBenchWorkers parses the bench-workers flag, a comma separated list of worker counts
+func parseBenchWorkers(s string) ([]int, error) {
+	var counts []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid worker count %q in bench-workers", field)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+// runBench finds the same primes single-threaded, with the stream strategy's two pools at each of the bench-workers counts and with the sieve strategy, then prints how they compare.
+// Candidates are drawn from the sequential source so every run does the same work, finding the first p primes from the bottom of the range.
+// The sieve sieves the whole range however many primes are asked for, its items are the numbers sieved
+func runBench(cfg config, workerCounts string) error {
+	counts, err := parseBenchWorkers(workerCounts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case cfg.search != SEARCH_PRIMES && cfg.search != SEARCH_TWIN:
+		return fmt.Errorf("the bench mode runs the %s and %s modes, not %s", SEARCH_PRIMES, SEARCH_TWIN, cfg.search)
+	case bigRange(cfg):
+		return fmt.Errorf("the bench mode can't run on a range beyond int64")
+	case cfg.numPrimes < 1:
+		return fmt.Errorf("the bench mode needs a number of primes to find, got %d", cfg.numPrimes)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cfg.source = SOURCE_SEQUENTIAL
+	cfg.strategy = STRATEGY_STREAM
+	cfg.autoscale, cfg.seeded = false, false
+
+	fmt.Printf("Benchmarking %d %s within range %d-%d...\n", cfg.numPrimes, searchNoun(cfg), cfg.from, cfg.numRange)
+	var runs []benchRun
+	run, err := bench("single-threaded", 1, func(rep *report) (int, error) { return singleThreaded(ctx, cfg, rep) })
+	if err != nil {
+		return err
+	}
+	runs = append(runs, run)
+	// Both pools of workers at each count, so the per-worker channels and the semaphore can be compared
+	for _, pool := range []string{POOL_WORKERS, POOL_SEMAPHORE} {
+		for _, n := range counts {
+			stream := cfg
+			stream.numWorkers, stream.pool = n, pool
+			run, err := bench("pipeline ("+pool+")", n, func(rep *report) (int, error) { return runStream(ctx, stream, rep, discardOutput{}) })
+			if err != nil {
+				return err
+			}
+			runs = append(runs, run)
+		}
+	}
+	if cfg.predicate == PREDICATE_PRIME {
+		sieve := cfg
+		sieve.strategy = STRATEGY_SIEVE
+		run, err := bench("sieve", cfg.numWorkers, func(rep *report) (int, error) { return runSieve(ctx, sieve, rep, discardOutput{}) })
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	printBench(os.Stdout, runs)
+	return nil
+}
+
+// bench times one run and counts its allocations. The garbage collector is run first, so the previous run's garbage isn't collected on this one's time
+func bench(name string, workers int, run func(rep *report) (int, error)) (benchRun, error) {
+	var rep report
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	found, err := run(&rep)
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return benchRun{}, fmt.Errorf("%s run: %w", name, err)
+	}
+	return benchRun{
+		name:     name,
+		workers:  workers,
+		duration: duration,
+		found:    found,
+		tested:   rep.tested(),
+		allocs:   after.Mallocs - before.Mallocs,
+		bytes:    after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+// singleThreaded tests the candidates in order in a plain loop, the baseline the concurrent runs are measured against
+func singleThreaded(ctx context.Context, cfg config, rep *report) (int, error) {
+	_, stats := rep.addWorker()
+	isPrime := candidateTest(cfg)
+	found := 0
+	for num := cfg.from; num < cfg.numRange && found < cfg.numPrimes && ctx.Err() == nil; num++ {
+		stats.Tested.Add(1)
+		if isPrime(num) {
+			found++
+		}
+	}
+	stats.Found.Add(int64(found))
+	return found, nil
+}
+
+// printBench writes the table comparing the runs, with each run's speedup over the single-threaded one
+func printBench(w io.Writer, runs []benchRun) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Run\tWorkers\tFound\tItems\tDuration\tItems/s\tSpeedup\tAllocs\tAlloc bytes\t")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%v\t%.0f\t%.2fx\t%d\t%d\t\n", run.name, run.workers, run.found, run.tested,
+			run.duration.Round(time.Microsecond), float64(run.tested)/run.duration.Seconds(), runs[0].duration.Seconds()/run.duration.Seconds(), run.allocs, run.bytes)
+	}
+	tw.Flush()
+}
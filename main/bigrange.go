@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// rangeBound is the value of a flag bounding the range, a decimal integer of any size. A bound that fits an int64 is stored in the int64
+// the rest of the program reads, a larger one in the big.Int of the arbitrary precision path (see runBig)
+type rangeBound struct {
+	small *int64
+	big   **big.Int
+}
+
+func (b rangeBound) String() string {
+	switch {
+	case b.big != nil && *b.big != nil:
+		return (*b.big).String()
+	case b.small != nil:
+		return strconv.FormatInt(*b.small, 10)
+	}
+	return "" // Zero value, which the flag package formats to tell whether a flag has a default
+}
+
+func (b rangeBound) Set(s string) error {
+	num, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("%q isn't a decimal integer", s)
+	}
+	if num.IsInt64() {
+		*b.small, *b.big = num.Int64(), nil
+		return nil
+	}
+	*b.big = num
+	return nil
+}
+
+// bigRange returns whether a bound of the range is beyond int64, which runs the pipeline on *big.Int candidates
+func bigRange(cfg config) bool {
+	return cfg.bigFrom != nil || cfg.bigTo != nil
+}
+
+// rangeBounds returns the bounds of the range as big.Ints, whichever of them are beyond int64
+func rangeBounds(cfg config) (low, high *big.Int) {
+	low, high = cfg.bigFrom, cfg.bigTo
+	if low == nil {
+		low = big.NewInt(cfg.from)
+	}
+	if high == nil {
+		high = big.NewInt(cfg.numRange)
+	}
+	return low, high
+}
+
+// runBig finds prime numbers in a range beyond int64, with the candidates drawn, tested and deduped as *big.Int.
+// The composites (nearly every candidate) are recycled through a BigPool, as are the duplicates dropped by the dedup stage.
+// It supports the random, crypto and sequential sources with local workers, and returns how many primes were found and the first error reported by any stage
+func runBig(ctx context.Context, cancel context.CancelFunc, cfg config, rep *report, out output) (int, error) {
+	switch {
+	case cfg.seeded || cfg.autoscale || cfg.batchSize > 1 || enveloped(cfg) || cfg.unique || cfg.priorityAbove > 0:
+		return 0, fmt.Errorf("a range beyond int64 can't be combined with a seed, autoscaling, batching, tracing, latency tracking, the unique flag or priorities")
+	case cfg.deterministic:
+		return 0, fmt.Errorf("the deterministic test only covers int64, it can't be used for a range beyond it")
+	case cfg.search != SEARCH_PRIMES || cfg.predicate != PREDICATE_PRIME:
+		return 0, fmt.Errorf("a range beyond int64 only finds primes")
+	case cfg.wasmFilter != nil:
+		return 0, fmt.Errorf("a filter module tests int64 candidates, it can't be used for a range beyond int64")
+	case cfg.numProducers < 1:
+		return 0, fmt.Errorf("need at least one producer, got %d", cfg.numProducers)
+	}
+	low, high := rangeBounds(cfg)
+	pool := pipeline.NewBigPool()
+	var getValue func() (*big.Int, error)
+	switch cfg.source {
+	case SOURCE_RANDOM:
+		getValue = pipeline.BigRandVal(low, high, pool)
+	case SOURCE_CRYPTO:
+		getValue = pipeline.BigCryptoRandVal(low, high, pool)
+	case SOURCE_SEQUENTIAL:
+		getValue = pipeline.BigSequentialVal(low, high, pool)
+	default:
+		return 0, fmt.Errorf("a range beyond int64 can't be searched with the %s source", cfg.source)
+	}
+
+	var errcs []<-chan error
+	sourceCtx := sourceContext(ctx, cfg)
+	producers := make([]<-chan *big.Int, cfg.numProducers)
+	for i := range producers {
+		var sourceErrs <-chan error
+		producers[i], sourceErrs = pipeline.CreateValueStream(sourceCtx, countValues(rep, cfg.maxCandidates, getValue), stageOptions(cfg, rep, "source")...)
+		errcs = append(errcs, sourceErrs)
+	}
+	numStream := producers[0]
+	if len(producers) > 1 {
+		numStream = pipeline.ReduceWorkers(ctx, producers, stageOptions(cfg, rep, "producer fan-in")...)
+	}
+	numStream = throttle(ctx, cfg, rep, numStream, 1)
+
+	keep := pipeline.BigPrimeFilter(cfg.certainty, pool)
+	workerOpts, annotateOpts := stageOptions(cfg, rep, "worker"), stageOptions(cfg, rep, "annotate")
+	workers := make([]<-chan pipeline.Found[*big.Int], cfg.numWorkers)
+	switch cfg.pool {
+	case POOL_STEALING:
+		var workerErrs <-chan error
+		workers, workerErrs = stealingWorkers(ctx, cfg, numStream, rep, func(num *big.Int) (*big.Int, bool, error) {
+			prime, err := keep(num)
+			return num, prime, err
+		})
+		errcs = append(errcs, workerErrs)
+	case POOL_WORKERS:
+		for i := range workers {
+			index, stats := rep.addWorker()
+			worker, workerErrs := pipeline.FilterWorker(ctx, numStream, keep, stats, workerOpts...)
+			workers[i] = pipeline.Annotate(ctx, worker, index, stats, annotateOpts...)
+			errcs = append(errcs, workerErrs)
+		}
+	default:
+		return 0, fmt.Errorf("a range beyond int64 runs the %s or %s pool, not %s", POOL_WORKERS, POOL_STEALING, cfg.pool)
+	}
+	reducedStream := pipeline.ReduceWorkers(ctx, workers, stageOptions(cfg, rep, "worker fan-in")...)
+
+	recycle := pipeline.WithDiscard(func(item any) { pool.Put(item.(pipeline.Found[*big.Int]).Value) })
+	distinctStream := pipeline.DistinctBy(ctx, reducedStream, func(f pipeline.Found[*big.Int]) string { return f.Value.String() }, cfg.dedupLimit, append(distinctOptions(cfg, rep), recycle)...)
+	resultStream := pipeline.Take(ctx, distinctStream, cfg.numPrimes, stageOptions(cfg, rep, "result")...)
+	return collectResults(cancel, resultStream, pipeline.MergeErrors(errcs...), func(found pipeline.Found[*big.Int]) {
+		out.prime(pipeline.Found[result]{Value: result{Big: found.Value}, Worker: found.Worker, At: found.At, Attempts: found.Attempts})
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/primefinderpb"
+)
+
+// protoOutput streams a run as size-delimited primefinder.v1.Record messages (see primefinderpb/results.proto): a result per number found, then the summary.
+// The first error writing one stops the output, and is returned by finish
+type protoOutput struct {
+	w   io.Writer
+	err error
+}
+
+func (o *protoOutput) start(cfg config) {}
+
+func (o *protoOutput) prime(found pipeline.Found[result]) {
+	r := &primefinderpb.Result{
+		Value:    found.Value.Value,
+		Worker:   int32(found.Worker),
+		FoundAt:  timestamppb.New(found.At),
+		Attempts: found.Attempts,
+		Twin:     found.Value.Twin,
+		Factors:  found.Value.Factors,
+		Mersenne: found.Value.Mersenne,
+	}
+	if found.Value.Big != nil {
+		r.Big = found.Value.Big.String()
+	}
+	o.write(&primefinderpb.Record{Record: &primefinderpb.Record_Result{Result: r}})
+}
+
+func (o *protoOutput) finish(sum summary) error {
+	var latency *primefinderpb.Latency
+	if l := sum.Latency; l != nil {
+		latency = &primefinderpb.Latency{Results: l.Results, P50Seconds: l.P50Seconds, P95Seconds: l.P95Seconds, P99Seconds: l.P99Seconds, MaxSeconds: l.MaxSeconds}
+	}
+	o.write(&primefinderpb.Record{Record: &primefinderpb.Record_Summary{Summary: &primefinderpb.Summary{
+		Requested:       int32(sum.Requested),
+		Found:           int32(sum.Found),
+		Tested:          sum.Tested,
+		DurationSeconds: sum.DurationSeconds,
+		Interrupted:     sum.Interrupted,
+		TimedOut:        sum.TimedOut,
+		Strategy:        sum.Strategy,
+		Capped:          sum.Capped,
+		Generated:       sum.Generated,
+		Duplicates:      sum.Duplicates,
+		Prefiltered:     sum.Prefiltered,
+		Speedup:         sum.Speedup,
+		Latency:         latency,
+	}}})
+	return o.err
+}
+
+func (o *protoOutput) write(record *primefinderpb.Record) {
+	if o.err == nil {
+		_, o.err = protodelim.MarshalTo(o.w, record)
+	}
+}
+
+// msgpackOutput streams a run as MessagePack maps, one after the other: a result per number found, with the fields of results.proto's Result,
+// then the summary with the fields of the JSON outputs. Each has a type of result or summary. found_at is a MessagePack timestamp.
+// The first error writing one stops the output, and is returned by finish
+type msgpackOutput struct {
+	enc *msgpack.Encoder
+	err error
+}
+
+// msgpackResult is a result of the msgpack output, named as in results.proto
+type msgpackResult struct {
+	Type     string    `json:"type"`
+	Value    int64     `json:"value"`
+	Worker   int       `json:"worker"`
+	FoundAt  time.Time `json:"found_at"`
+	Attempts int64     `json:"attempts"`
+	Twin     int64     `json:"twin,omitempty"`
+	Factors  []int64   `json:"factors,omitempty"`
+	Mersenne bool      `json:"mersenne,omitempty"`
+	Big      string    `json:"big,omitempty"`
+}
+
+func newMsgpackOutput(w io.Writer) *msgpackOutput {
+	enc := msgpack.NewEncoder(w)
+	// The summary is encoded with the names of its JSON fields
+	enc.SetCustomStructTag("json")
+	return &msgpackOutput{enc: enc}
+}
+
+func (o *msgpackOutput) start(cfg config) {}
+
+func (o *msgpackOutput) prime(found pipeline.Found[result]) {
+	r := msgpackResult{
+		Type:     "result",
+		Value:    found.Value.Value,
+		Worker:   found.Worker,
+		FoundAt:  found.At,
+		Attempts: found.Attempts,
+		Twin:     found.Value.Twin,
+		Factors:  found.Value.Factors,
+		Mersenne: found.Value.Mersenne,
+	}
+	if found.Value.Big != nil {
+		r.Big = found.Value.Big.String()
+	}
+	o.write(r)
+}
+
+func (o *msgpackOutput) finish(sum summary) error {
+	o.write(struct {
+		Type string `json:"type"`
+		summary
+	}{"summary", sum})
+	return o.err
+}
+
+func (o *msgpackOutput) write(v any) {
+	if o.err == nil {
+		o.err = o.enc.Encode(v)
+	}
+}
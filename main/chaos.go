@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// checkChaos returns an error if a chaos flag is out of bounds
+func checkChaos(cfg config) error {
+	switch {
+	case cfg.chaosDelay < 0:
+		return fmt.Errorf("chaos-delay %v can't be negative", cfg.chaosDelay)
+	case cfg.chaosDropRate < 0 || cfg.chaosDropRate > 1:
+		return fmt.Errorf("chaos-drop-rate %v must be from 0 to 1", cfg.chaosDropRate)
+	case cfg.chaosPanicRate < 0 || cfg.chaosPanicRate > 1:
+		return fmt.Errorf("chaos-panic-rate %v must be from 0 to 1", cfg.chaosPanicRate)
+	}
+	return nil
+}
+
+// withChaos wraps the workers' test with the faults set by the chaos flags, returning it as is when they're all off.
+// Before testing a candidate the worker waits for a random time up to chaos-delay, then drops the candidate
+// (reporting it as not matching, so it's lost) with a probability of chaos-drop-rate, or panics with a probability of chaos-panic-rate
+func withChaos(ctx context.Context, cfg config, test func(int64) (bool, error)) func(int64) (bool, error) {
+	if cfg.chaosDelay == 0 && cfg.chaosDropRate == 0 && cfg.chaosPanicRate == 0 {
+		return test
+	}
+	return func(num int64) (bool, error) {
+		if cfg.chaosDelay > 0 {
+			timer := time.NewTimer(time.Duration(rand.Int63n(int64(cfg.chaosDelay) + 1)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false, nil
+			case <-timer.C:
+			}
+		}
+		// One draw decides both faults, so their rates add up rather than the panics only hitting the candidates that weren't dropped
+		switch draw := rand.Float64(); {
+		case draw < cfg.chaosDropRate:
+			return false, nil
+		case draw < cfg.chaosDropRate+cfg.chaosPanicRate:
+			panic("chaos: injected panic")
+		}
+		return test(num)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const DEFAULT_CHECKPOINT_EVERY = 10 * time.Second
+
+// checkpoint is the state of a run saved to the checkpoint file, enough for -resume to carry on from it
+type checkpoint struct {
+	Primes    int                `json:"primes"`
+	Range     int64              `json:"range"`
+	From      int64              `json:"from,omitempty"`
+	Source    string             `json:"source"`
+	Mode      string             `json:"mode,omitempty"`
+	Predicate string             `json:"predicate,omitempty"`
+	Seed      *int64             `json:"seed,omitempty"`
+	Found     []checkpointPrime  `json:"found"`
+	Tested    int64              `json:"tested"`
+	Next      int64              `json:"next,omitempty"`    // Sequential source: every candidate below it has been tested
+	Workers   []workerCheckpoint `json:"workers,omitempty"` // Seeded runs: one per worker, in order
+	SavedAt   time.Time          `json:"saved_at"`
+}
+
+type checkpointPrime struct {
+	Value   int64     `json:"value"`
+	Worker  int       `json:"worker"`
+	FoundAt time.Time `json:"found_at"`
+}
+
+// workerCheckpoint is where a seeded worker's generator got to. The generator can't be saved, it's replayed from its seed on resume (see fastForward)
+type workerCheckpoint struct {
+	Drawn int64  `json:"drawn"`          // Candidates the worker tested or the wheel rejected, its primes were all drawn before this many
+	Last  *int64 `json:"last,omitempty"` // Last prime the worker reported
+}
+
+// loadCheckpoint reads the checkpoint file at path
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("decoding checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// apply sets the flags that decide which candidates a run draws to the ones of the checkpointed run, so the resumed run picks up the same search
+func (cp *checkpoint) apply(cfg config) config {
+	cfg.numPrimes = cp.Primes
+	cfg.numRange = cp.Range
+	cfg.from = cp.From
+	cfg.source = cp.Source
+	// A checkpoint without them was saved by a run searching for primes
+	cfg.search, cfg.predicate = cmp.Or(cp.Mode, SEARCH_PRIMES), cmp.Or(cp.Predicate, PREDICATE_PRIME)
+	cfg.seeded = cp.Seed != nil
+	if cp.Seed != nil {
+		cfg.seed = *cp.Seed
+		cfg.numWorkers = len(cp.Workers)
+	}
+	return cfg
+}
+
+// checkCheckpointing returns an error if the run can't be checkpointed: only the stream strategy drawing from the range, with local workers that aren't autoscaled, is
+func checkCheckpointing(cfg config) error {
+	switch {
+	case cfg.strategy != STRATEGY_STREAM:
+		return fmt.Errorf("only the %s strategy can be checkpointed", STRATEGY_STREAM)
+	case !rangeSource(cfg):
+		return fmt.Errorf("a run reading from the %s source can't be checkpointed", cfg.source)
+	case bigRange(cfg):
+		return fmt.Errorf("a range beyond int64 can't be checkpointed")
+	case cfg.search == SEARCH_FACTOR:
+		return fmt.Errorf("a run in the %s mode can't be checkpointed", cfg.search)
+	case cfg.autoscale || cfg.transport != "" || enveloped(cfg) || cfg.duration > 0:
+		return fmt.Errorf("checkpointing can't be combined with autoscaling, a coordinator, tracing, latency tracking or a duration")
+	}
+	return nil
+}
+
+// checkpointer is the output saving the state of a run every interval, and once more when the run finishes (interrupted or not),
+// to the checkpoint file (see checkpointFile) or to the job store of the server (see jobStore).
+// It also tracks which candidates are done with, through the workers' test (see track), so a resumed run doesn't skip the ones still in flight:
+// with the sequential source it keeps the lowest candidate not done yet, and for seeded runs the last prime each worker reported
+type checkpointer struct {
+	write    func(cp checkpoint) error
+	interval time.Duration
+	cfg      config
+	rep      *report
+	resumed  *checkpoint // Checkpoint the run was resumed from, or nil
+
+	mu       sync.Mutex
+	found    []checkpointPrime
+	previous map[int64]bool // Primes found before the run was resumed, skipped by the workers
+	next     int64          // Lowest candidate of the sequential source not done with yet
+	done     map[int64]bool // Sequential candidates done with above next
+	last     map[int]int64  // Last prime reported by each seeded worker
+	offsets  map[int]int64  // Candidates each seeded worker's generator was fast-forwarded by
+	wheeled  map[int]int64  // Candidates of each seeded worker rejected by the wheel pre-filter, never tested by the worker
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// newCheckpointer returns the checkpointer of a run saving with write, resumed from the given checkpoint if it isn't nil
+func newCheckpointer(write func(cp checkpoint) error, interval time.Duration, cfg config, rep *report, resumed *checkpoint) *checkpointer {
+	c := &checkpointer{
+		write:    write,
+		interval: interval,
+		cfg:      cfg,
+		rep:      rep,
+		resumed:  resumed,
+		previous: make(map[int64]bool),
+		done:     make(map[int64]bool),
+		last:     make(map[int]int64),
+		offsets:  make(map[int]int64),
+		wheeled:  make(map[int]int64),
+		next:     cfg.from,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if resumed != nil {
+		c.next = resumed.Next
+		for _, p := range resumed.Found {
+			c.previous[p.Value] = true
+		}
+	}
+	return c
+}
+
+// resumedPrimes returns the primes found before the run was resumed, to be output again ahead of the new ones
+func (c *checkpointer) resumedPrimes() []pipeline.Found[int64] {
+	if c.resumed == nil {
+		return nil
+	}
+	primes := make([]pipeline.Found[int64], len(c.resumed.Found))
+	for i, p := range c.resumed.Found {
+		primes[i] = pipeline.Found[int64]{Value: p.Value, Worker: p.Worker, At: p.FoundAt}
+	}
+	return primes
+}
+
+// track wraps the workers' test, so a candidate found before the run was resumed is skipped and a candidate that isn't prime is done with once tested.
+// Primes are done with once they're output, after they made it through the rest of the pipeline
+func (c *checkpointer) track(keep func(int64) (bool, error)) func(int64) (bool, error) {
+	return func(num int64) (bool, error) {
+		c.mu.Lock()
+		previous := c.previous[num]
+		c.mu.Unlock()
+		if previous {
+			c.doneWith(num)
+			return false, nil
+		}
+		prime, err := keep(num)
+		if err == nil && !prime {
+			c.doneWith(num)
+		}
+		return prime, err
+	}
+}
+
+// doneWith moves the lowest sequential candidate not done yet past num, and any run of candidates done above it
+func (c *checkpointer) doneWith(num int64) {
+	if c.cfg.source != SOURCE_SEQUENTIAL {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if num < c.next {
+		return
+	}
+	c.done[num] = true
+	for c.done[c.next] {
+		delete(c.done, c.next)
+		c.next++
+	}
+}
+
+// prefiltered is done with a candidate the wheel pre-filter rejected before it reached the workers. In a seeded run it's counted as drawn
+// by the worker whose stream it was rejected from, as the worker's tested count leaves it out; worker is -1 for the stream the workers share
+func (c *checkpointer) prefiltered(worker int, num int64) {
+	c.doneWith(num)
+	if worker < 0 || !c.cfg.seeded {
+		return
+	}
+	c.mu.Lock()
+	c.wheeled[worker]++
+	c.mu.Unlock()
+}
+
+// fastForward moves a seeded worker's generator past the candidates it was done with when the checkpoint was saved: up to the last prime it reported.
+// replay is a second generator with the same seed, used to find how far that is, since the worker may have tested more candidates than the ones it reported
+func (c *checkpointer) fastForward(worker int, getValue, replay func() (int64, error)) (func() (int64, error), error) {
+	if c.resumed == nil || worker >= len(c.resumed.Workers) {
+		return getValue, nil
+	}
+	wc := c.resumed.Workers[worker]
+	if wc.Last == nil {
+		return getValue, nil // Nothing reported, the worker starts over
+	}
+	var offset int64
+	for i := int64(1); i <= wc.Drawn; i++ {
+		val, err := replay()
+		if err != nil {
+			return nil, err
+		}
+		if val == *wc.Last {
+			offset = i
+		}
+	}
+	for i := int64(0); i < offset; i++ {
+		if _, err := getValue(); err != nil {
+			return nil, err
+		}
+	}
+	c.mu.Lock()
+	c.offsets[worker] = offset
+	c.last[worker] = *wc.Last
+	c.mu.Unlock()
+	return getValue, nil
+}
+
+func (c *checkpointer) start(cfg config) {
+	go c.saveEvery()
+}
+
+func (c *checkpointer) prime(found pipeline.Found[result]) {
+	c.mu.Lock()
+	c.found = append(c.found, checkpointPrime{Value: found.Value.Value, Worker: found.Worker, FoundAt: found.At})
+	c.last[found.Worker] = found.Value.Value
+	c.mu.Unlock()
+	c.doneWith(found.Value.Value)
+}
+
+// finish saves the final checkpoint
+func (c *checkpointer) finish(sum summary) error {
+	close(c.stop)
+	<-c.stopped
+	return c.save()
+}
+
+// saveEvery saves a checkpoint on every tick until finish is called. A failed save is logged, the next one may succeed
+func (c *checkpointer) saveEvery() {
+	defer close(c.stopped)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if err := c.save(); err != nil {
+				slog.Error("saving checkpoint", "err", err)
+			}
+		}
+	}
+}
+
+// snapshot returns the current state of the run.
+// The primes are read before the workers' counters, so every prime reported was drawn within the counts saved
+func (c *checkpointer) snapshot() checkpoint {
+	c.mu.Lock()
+	cp := checkpoint{
+		Primes:    c.cfg.numPrimes,
+		Range:     c.cfg.numRange,
+		From:      c.cfg.from,
+		Source:    c.cfg.source,
+		Mode:      c.cfg.search,
+		Predicate: c.cfg.predicate,
+		Found:     append([]checkpointPrime{}, c.found...),
+		Next:      c.next,
+		SavedAt:   time.Now(),
+	}
+	last := make(map[int]int64, len(c.last))
+	for worker, prime := range c.last {
+		last[worker] = prime
+	}
+	drawn := make(map[int]int64, len(c.offsets))
+	for worker, offset := range c.offsets {
+		drawn[worker] = offset
+	}
+	for worker, wheeled := range c.wheeled {
+		drawn[worker] += wheeled
+	}
+	c.mu.Unlock()
+
+	cp.Tested = c.rep.tested()
+	if c.resumed != nil {
+		cp.Tested += c.resumed.Tested
+	}
+	if c.cfg.seeded {
+		seed := c.cfg.seed
+		cp.Seed = &seed
+		stats := c.rep.workerStats()
+		cp.Workers = make([]workerCheckpoint, c.cfg.numWorkers)
+		for i := range cp.Workers {
+			cp.Workers[i].Drawn = drawn[i]
+			if i < len(stats) {
+				cp.Workers[i].Drawn += stats[i].Tested.Load()
+			}
+			if prime, ok := last[i]; ok {
+				cp.Workers[i].Last = &prime
+			}
+		}
+	}
+	return cp
+}
+
+// save writes the current state of the run
+func (c *checkpointer) save() error {
+	return c.write(c.snapshot())
+}
+
+// checkpointFile returns the function a checkpointer saves with to the checkpoint file at path. It writes a temporary file next to it,
+// which is then renamed over it, so a run killed while saving leaves the previous checkpoint in place
+func checkpointFile(path string) func(cp checkpoint) error {
+	return func(cp checkpoint) error {
+		data, err := json.MarshalIndent(cp, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+		if err != nil {
+			return fmt.Errorf("creating temporary checkpoint: %w", err)
+		}
+		defer os.Remove(tmp.Name()) // Fails once the file has been renamed
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return fmt.Errorf("saving checkpoint: %w", err)
+		}
+		slog.Debug("saved checkpoint", "path", path, "found", len(cp.Found))
+		return nil
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// testConfig returns the run flags parsed from args, as main parses them
+func testConfig(t *testing.T, args ...string) config {
+	t.Helper()
+	var cfg config
+	fs := flag.NewFlagSet(t.Name(), flag.ContinueOnError)
+	bindFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing flags %q: %v", args, err)
+	}
+	cfg.seeded = givenFlags(fs)["seed"]
+	return cfg
+}
+
+// recordOutput records the results of a run, and cancels it once stopAfter have been output (never if it's 0), as an interrupt would
+type recordOutput struct {
+	stopAfter int
+	cancel    context.CancelFunc
+
+	mu    sync.Mutex
+	found []pipeline.Found[result]
+}
+
+func (o *recordOutput) start(cfg config) {}
+
+func (o *recordOutput) prime(found pipeline.Found[result]) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.found = append(o.found, found)
+	if len(o.found) == o.stopAfter {
+		o.cancel()
+	}
+}
+
+func (o *recordOutput) finish(sum summary) error {
+	return nil
+}
+
+// runCheckpointed runs the stream strategy with cfg as run does with a checkpoint, resumed from resumed if it isn't nil and interrupted
+// once stopAfter results have been output (if it isn't 0). It returns every result output, those of the resumed run first, and the final checkpoint
+func runCheckpointed(t *testing.T, cfg config, resumed *checkpoint, stopAfter int) ([]pipeline.Found[result], checkpoint) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var rep report
+	var saved checkpoint
+	cfg.checkpoint = newCheckpointer(func(cp checkpoint) error { saved = cp; return nil }, time.Hour, cfg, &rep, resumed)
+	rec := &recordOutput{stopAfter: stopAfter, cancel: cancel}
+	out := multiOutput{rec, cfg.checkpoint}
+	out.start(cfg)
+	remaining := cfg
+	for _, prime := range cfg.checkpoint.resumedPrimes() {
+		out.prime(newResult(cfg, prime))
+		remaining.numPrimes--
+	}
+	if remaining.numPrimes > 0 {
+		if _, err := runStream(ctx, remaining, &rep, out); err != nil {
+			t.Fatalf("runStream: %v", err)
+		}
+	}
+	if err := cfg.checkpoint.finish(summary{}); err != nil {
+		t.Fatalf("saving checkpoint: %v", err)
+	}
+	return rec.found, saved
+}
+
+// values returns the numbers of a run's results
+func values(found []pipeline.Found[result]) []int64 {
+	nums := make([]int64, len(found))
+	for i, f := range found {
+		nums[i] = f.Value.Value
+	}
+	return nums
+}
+
+func TestResumeSequential(t *testing.T) {
+	cfg := testConfig(t, "-source=sequential", "-r=100000", "-p=30", "-n=1")
+	want, _ := runCheckpointed(t, cfg, nil, 0)
+
+	interrupted, cp := runCheckpointed(t, cfg, nil, 10)
+	if len(cp.Found) != len(interrupted) || len(interrupted) >= cfg.numPrimes {
+		t.Fatalf("checkpoint holds %d primes of the %d output, want them all and fewer than %d", len(cp.Found), len(interrupted), cfg.numPrimes)
+	}
+	// Every candidate below Next is done with, and none of the primes past the last one output is
+	last := interrupted[len(interrupted)-1].Value.Value
+	if cp.Next <= last {
+		t.Errorf("checkpoint's next candidate is %d, want past the last prime output, %d", cp.Next, last)
+	}
+	for num := last + 1; num < cp.Next; num++ {
+		if pipeline.DeterministicPrime(num) {
+			t.Errorf("checkpoint's next candidate is %d, past prime %d which wasn't output", cp.Next, num)
+		}
+	}
+
+	resumed, final := runCheckpointed(t, cp.apply(cfg), &cp, 0)
+	if got := values(resumed); !slices.Equal(got, values(want)) {
+		t.Fatalf("resumed run output %v, want %v as uninterrupted", got, values(want))
+	}
+	if final.Next <= want[len(want)-1].Value.Value {
+		t.Errorf("final checkpoint's next candidate is %d, want past the last prime, %d", final.Next, want[len(want)-1].Value.Value)
+	}
+}
+
+func TestResumeSeeded(t *testing.T) {
+	for _, args := range [][]string{
+		{"-seed=3", "-r=100000", "-p=30", "-n=3"},
+		// The wheel rejects candidates before the workers test them, they're drawn all the same
+		{"-seed=3", "-r=100000", "-p=30", "-n=3", "-wheel"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			cfg := testConfig(t, args...)
+			interrupted, cp := runCheckpointed(t, cfg, nil, 10)
+			if len(cp.Workers) != cfg.numWorkers {
+				t.Fatalf("checkpoint has %d workers, want %d", len(cp.Workers), cfg.numWorkers)
+			}
+			checkDrawn(t, cfg, cp)
+			checkNoneSkipped(t, cfg, cp, interrupted)
+
+			resumed, final := runCheckpointed(t, cp.apply(cfg), &cp, 0)
+			if len(resumed) != cfg.numPrimes {
+				t.Fatalf("resumed run output %d primes, want %d", len(resumed), cfg.numPrimes)
+			}
+			if !slices.Equal(values(resumed[:len(interrupted)]), values(interrupted)) {
+				t.Errorf("resumed run output %v first, want the primes found before it, %v", values(resumed[:len(interrupted)]), values(interrupted))
+			}
+			checkDrawn(t, cfg, final)
+			checkNoneSkipped(t, cfg, final, resumed)
+		})
+	}
+}
+
+// seededStream returns the first n candidates a seeded worker draws
+func seededStream(t *testing.T, cfg config, worker int, n int64) []int64 {
+	t.Helper()
+	getValue := pipeline.SeededRandValBetween(cfg.from, cfg.numRange, pipeline.SubSeed(cfg.seed, worker))
+	stream := make([]int64, n)
+	for i := range stream {
+		val, err := getValue()
+		if err != nil {
+			t.Fatalf("drawing candidate: %v", err)
+		}
+		stream[i] = val
+	}
+	return stream
+}
+
+// checkDrawn fails the test unless the last prime of each worker of a seeded checkpoint is among the candidates it counts as drawn,
+// so a resumed run fast-forwards the worker past it
+func checkDrawn(t *testing.T, cfg config, cp checkpoint) {
+	t.Helper()
+	for i, wc := range cp.Workers {
+		if wc.Last == nil {
+			continue
+		}
+		if !slices.Contains(seededStream(t, cfg, i, wc.Drawn), *wc.Last) {
+			t.Errorf("worker %d's last prime %d isn't among the %d candidates it drew", i, *wc.Last, wc.Drawn)
+		}
+	}
+}
+
+// checkNoneSkipped fails the test if a prime was output twice, or if a worker drew a prime ahead of the last one it output without it being output
+func checkNoneSkipped(t *testing.T, cfg config, cp checkpoint, found []pipeline.Found[result]) {
+	t.Helper()
+	output := make(map[int64]bool)
+	for _, f := range found {
+		if output[f.Value.Value] {
+			t.Errorf("prime %d output twice", f.Value.Value)
+		}
+		output[f.Value.Value] = true
+	}
+	for i, wc := range cp.Workers {
+		if wc.Last == nil {
+			continue
+		}
+		for _, num := range seededStream(t, cfg, i, wc.Drawn) {
+			if num == *wc.Last {
+				break
+			}
+			if pipeline.DeterministicPrime(num) && !output[num] {
+				t.Errorf("worker %d drew prime %d ahead of its last prime %d, but it wasn't output", i, num, *wc.Last)
+			}
+		}
+	}
+}
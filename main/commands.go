@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Commands, selected with the first argument. Without one (or with flags straight away) the program runs, finding primes locally and exiting
+const (
+	MODE_RUN         = "run"         // Find primes locally and exit
+	MODE_SERVE       = "serve"       // Run jobs over HTTP and gRPC
+	MODE_COORDINATOR = "coordinator" // Find primes with workers in worker mode, reached over NATS or gRPC
+	MODE_WORKER      = "worker"      // Test the candidates coordinators send over NATS or gRPC
+	MODE_BENCH       = "bench"       // Compare finding the same primes single-threaded, with the pipeline and with the sieve
+	MODE_VERIFY      = "verify"      // Re-check the numbers of a results file
+	MODE_PIPELINE    = "pipeline"    // Run a pipeline described in a YAML file
+	MODE_HELP        = "help"        // List the commands, or show the flags of one
+)
+
+// Transport flag values, how a coordinator and its workers reach each other
+const (
+	TRANSPORT_NATS = "nats" // Through the queue group of a NATS server
+	TRANSPORT_GRPC = "grpc" // The workers register with the coordinator's Coordinator gRPC service
+)
+
+// command is a subcommand of the CLI, with its own flag set and help text
+type command struct {
+	name         string
+	summary      string // Sentence describing the command in its help and the list of commands
+	args         string // Arguments after the flags in the usage line, such as file
+	nargs        int    // Number of arguments after the flags
+	runsPipeline bool   // Whether the command takes the run flags, checked before it's run (see checkRunFlags)
+	// bind defines the command's flags on fs, storing their values in cfg, and returns the function running the command with the arguments left after the flags
+	bind func(fs *flag.FlagSet, cfg *config) func(cfg config, args []string) error
+}
+
+// commands lists the commands in the order they're shown in the help, starting with the default
+var commands = []command{
+	{
+		name:         MODE_RUN,
+		summary:      "Find P primes locally and print them, the default without a command",
+		runsPipeline: true,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			bindFlags(fs, cfg)
+			fs.StringVar(&cfg.checkpointPath, "checkpoint", "", "Path of a file the state of the run is saved to as it goes, for resume to continue from (disabled if empty)")
+			fs.DurationVar(&cfg.checkpointEvery, "checkpoint-every", DEFAULT_CHECKPOINT_EVERY, "How often the checkpoint file is saved")
+			fs.StringVar(&cfg.cpuProfile, "cpuprofile", "", "Path of a file a CPU profile of the run is written to, for go tool pprof (disabled if empty)")
+			fs.StringVar(&cfg.memProfile, "memprofile", "", "Path of a file a heap profile is written to once the run has finished, for go tool pprof (disabled if empty)")
+			fs.BoolVar(&cfg.tui, "tui", false, "Draw a live dashboard of the run on the terminal, with the results printed once it's finished (ignored when stdout isn't a terminal)")
+			fs.StringVar(&cfg.resumePath, "resume", "", "Path of a checkpoint file to continue a stopped run from, with its flags. The run keeps checkpointing to it unless checkpoint is set")
+			return func(cfg config, args []string) error { return run(cfg) }
+		},
+	},
+	{
+		name:         MODE_SERVE,
+		summary:      "Serve a REST API and a dashboard running the pipeline as jobs, and optionally the PrimeFinder gRPC service. The run flags set the defaults of every job",
+		runsPipeline: true,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			bindFlags(fs, cfg)
+			var addr, grpcAddr, storePath string
+			var maxJobs int
+			fs.StringVar(&addr, "addr", DEFAULT_SERVE_ADDR, "Address the job API listens on")
+			fs.StringVar(&grpcAddr, "grpc-addr", "", "Address the PrimeFinder gRPC service listens on, such as :9000 (disabled if empty)")
+			fs.IntVar(&maxJobs, "max-jobs", 0, "Jobs running at once, the jobs created past that are queued until one finishes (0 for no limit)")
+			fs.StringVar(&storePath, "store", "", "Path of a bbolt database the jobs are saved to, so a restarted server lists them and resumes those it hadn't finished (disabled if empty)")
+			fs.DurationVar(&cfg.checkpointEvery, "checkpoint-every", DEFAULT_CHECKPOINT_EVERY, "How often the progress of a running job is saved to the store")
+			return func(cfg config, args []string) error { return runServer(addr, grpcAddr, maxJobs, storePath, cfg) }
+		},
+	},
+	{
+		name:         MODE_COORDINATOR,
+		summary:      "Find P primes with the workers of worker instances, sending them batches of candidates over NATS or gRPC",
+		runsPipeline: true,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			bindFlags(fs, cfg)
+			fs.StringVar(&cfg.transport, "transport", TRANSPORT_NATS, "How the workers are reached, nats (through nats-url) or grpc (they register with the coordinator at listen)")
+			fs.StringVar(&cfg.natsURL, "nats-url", nats.DefaultURL, "NATS server the coordinator sends batches of candidates to workers over, n is the number of batches in flight")
+			fs.StringVar(&cfg.coordinatorAddr, "listen", DEFAULT_COORDINATOR_ADDR, "Address the coordinator serves the Coordinator gRPC service on for the workers to register with, with the grpc transport")
+			fs.DurationVar(&cfg.workerTimeout, "worker-timeout", DEFAULT_WORKER_TIMEOUT, "How long a gRPC worker can go without being heard from before it's dropped and its batches are handed to the other workers")
+			fs.IntVar(&cfg.partitions, "partitions", DEFAULT_PARTITIONS, "Partitions the gRPC coordinator splits the candidates into, each owned by one worker and moved to another as workers register and leave")
+			return func(cfg config, args []string) error { return run(cfg) }
+		},
+	},
+	{
+		name:         MODE_WORKER,
+		summary:      "Test the batches of candidates coordinators send over NATS or gRPC, until stopped",
+		runsPipeline: true,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			bindFlags(fs, cfg)
+			fs.StringVar(&cfg.transport, "transport", TRANSPORT_NATS, "How the coordinators are reached, nats (through nats-url) or grpc (registering with the coordinator at coordinator)")
+			fs.StringVar(&cfg.natsURL, "nats-url", nats.DefaultURL, "NATS server the worker receives batches of candidates from, n is the number of batches tested at once")
+			fs.StringVar(&cfg.coordinatorAddr, "coordinator", DEFAULT_COORDINATOR_DIAL, "Address of the coordinator the worker registers with over gRPC, with the grpc transport, n is the number of batches tested at once")
+			return func(cfg config, args []string) error {
+				if cfg.transport == TRANSPORT_GRPC {
+					return runGRPCWorker(cfg)
+				}
+				return runNATSWorker(cfg)
+			}
+		},
+	},
+	{
+		name:         MODE_BENCH,
+		summary:      "Compare finding the same P primes single-threaded, with the pipeline at several worker counts and with the sieve",
+		runsPipeline: true,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			bindFlags(fs, cfg)
+			var benchWorkers string
+			fs.StringVar(&benchWorkers, "bench-workers", defaultBenchWorkers(), "Comma separated worker counts the pipeline is benchmarked with, n is the sieve's")
+			return func(cfg config, args []string) error { return runBench(cfg, benchWorkers) }
+		},
+	},
+	{
+		name:    MODE_VERIFY,
+		summary: "Re-check every number of a results file written with the out or csv flag, reporting those failing the test of the predicate and mode flags or found twice",
+		args:    "file",
+		nargs:   1,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			bindTestFlags(fs, cfg)
+			return func(cfg config, args []string) error { return runVerify(cfg, args[0]) }
+		},
+	},
+	{
+		name:    MODE_PIPELINE,
+		summary: "Run the pipeline described in a YAML file, its source, stages (filters on workers, take, distinct, rate and joins) and sinks, until the sinks are done",
+		args:    "file",
+		nargs:   1,
+		bind: func(fs *flag.FlagSet, cfg *config) func(config, []string) error {
+			// The stages of the file pick their tests with their filters, the certainty flags only tune the prime test
+			cfg.predicate, cfg.search = PREDICATE_PRIME, SEARCH_PRIMES
+			fs.IntVar(&cfg.certainty, "certainty", DEFAULT_CERTAINTY, "Number of Miller-Rabin rounds used by the prime filters")
+			fs.BoolVar(&cfg.deterministic, "deterministic", false, "Use a primality test that is proven correct for int64 in the prime filters instead of a probabilistic one")
+			fs.BoolVar(&cfg.trialDivision, "trial-division", true, "Divide each number by the primes below 1000 before the primality test of the prime filters")
+			bindLogFlags(fs, cfg)
+			return func(cfg config, args []string) error { return runDefinition(cfg, args[0]) }
+		},
+	},
+	{
+		name:    MODE_HELP,
+		summary: "List the commands, or show the flags of the command given",
+		args:    "[command]",
+	},
+}
+
+// lookupCommand returns the command with the given name
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// programName is the name the program was started with, for the usage lines
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// commandUsage returns the help of a command: its usage line, what it does and its flags. The run command's also lists the other commands
+func commandUsage(fs *flag.FlagSet, cmd command) func() {
+	return func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s\n\n%s.\n\n", strings.TrimSpace(fmt.Sprintf("%s %s [flags] %s", programName(), cmd.name, cmd.args)), cmd.summary)
+		if cmd.name == MODE_RUN {
+			printCommandList(w)
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Every flag can also be set with an environment variable, such as %s for log-level.\n", envName("log-level"))
+		fmt.Fprint(w, "Flags given on the command line take precedence over the config file, which takes precedence over the environment.\n\n")
+		fmt.Fprintln(w, "Flags:")
+		fs.PrintDefaults()
+	}
+}
+
+// printCommands writes the usage of the program to w, with the list of commands
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\n", programName())
+	printCommandList(w)
+}
+
+// printCommandList writes the list of commands to w
+func printCommandList(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun %s help <command> for the flags of a command.\n", programName())
+}
+
+// runHelp prints the list of commands, or the help of the command named in args, to stdout. It returns the exit status
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printCommands(os.Stdout)
+		return 0
+	}
+	cmd, ok := lookupCommand(args[0])
+	if !ok || cmd.bind == nil {
+		fmt.Fprintf(os.Stderr, "no help for %q\n\n", args[0])
+		printCommands(os.Stderr)
+		return EXIT_USAGE
+	}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	bindConfigFlag(fs)
+	cmd.bind(fs, new(config))
+	commandUsage(fs, cmd)()
+	return 0
+}
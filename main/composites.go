@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// compositeSink writes the candidates the workers reject to the composites flag's file, one per line (see pipeline.SinkToFile).
+// The workers send them on stream as a second output (see pipeline.PartitionWorker), which is the sink's to close once they've all stopped
+type compositeSink struct {
+	stream chan int64
+	errc   <-chan error
+	cancel context.CancelFunc
+}
+
+// checkComposites returns an error if the composites flag is set for a run whose workers don't report the candidates they reject
+func checkComposites(cfg config) error {
+	switch {
+	case cfg.compositesPath == "":
+		return nil
+	case bigRange(cfg) || cfg.search == SEARCH_FACTOR || cfg.source == SOURCE_KAFKA || enveloped(cfg):
+		return fmt.Errorf("the composites file can't be written for a range beyond int64, the %s mode, the %s source, a traced run or latency tracking", SEARCH_FACTOR, SOURCE_KAFKA)
+	case cfg.engine == ENGINE_ERRGROUP || cfg.autoscale || cfg.ordered || cfg.pool == POOL_SEMAPHORE || cfg.pool == POOL_STEALING:
+		return fmt.Errorf("the composites file can't be written by the %s engine, autoscaled or ordered workers or the %s and %s pools", ENGINE_ERRGROUP, POOL_SEMAPHORE, POOL_STEALING)
+	case cfg.transport != "" || cfg.redisAddr != "":
+		// Remote workers don't send their composites back, and a candidate claimed by another instance isn't a composite
+		return fmt.Errorf("the composites file can't be combined with a coordinator or Redis")
+	}
+	return nil
+}
+
+// newCompositeSink starts the sink writing to path, with the stream between the workers and the sink holding buffer candidates
+func newCompositeSink(ctx context.Context, path string, buffer int) *compositeSink {
+	ctx, cancel := context.WithCancel(ctx)
+	stream := make(chan int64, buffer)
+	return &compositeSink{stream: stream, errc: pipeline.SinkToFile(ctx, stream, path), cancel: cancel}
+}
+
+// close ends the sink's input and waits for it to finish, keeping the file if keep is set and discarding it otherwise (such as for a failed run).
+// Every worker sending on the stream must have exited already
+func (s *compositeSink) close(keep bool) error {
+	defer s.cancel()
+	if !keep {
+		s.cancel()
+	}
+	close(s.stream)
+	if err := <-s.errc; err != nil {
+		return fmt.Errorf("writing composites file: %w", err)
+	}
+	return nil
+}
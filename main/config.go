@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ENV_PREFIX starts the names of the environment variables setting flags, such as PRIMES_LOG_LEVEL for log-level
+const ENV_PREFIX = "PRIMES_"
+
+// bindConfigFlag defines the config flag on fs, naming the file loadConfigFile reads
+func bindConfigFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "Path of a YAML (.yaml, .yml) or TOML (.toml) file setting flags by name, those given on the command line take precedence (disabled if empty)")
+}
+
+// givenFlags returns the names of the flags given on the command line
+func givenFlags(fs *flag.FlagSet) map[string]bool {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	return given
+}
+
+// envName returns the environment variable setting a flag, its name in upper case with dashes as underscores after ENV_PREFIX
+func envName(flagName string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadEnv sets the flags of fs to the values of their environment variables (see envName), except for those given on the command line.
+// It's applied before the config file, which the environment can name with PRIMES_CONFIG, so the file takes precedence over the environment.
+// Variables that don't name a flag of the command are ignored, as they may be meant for another one
+func loadEnv(fs *flag.FlagSet, given map[string]bool, lookup func(string) (string, bool)) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		val, ok := lookup(envName(f.Name))
+		if !ok || given[f.Name] || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, val); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", val, envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// loadConfigFile sets the flags of fs to the values in the config file at path, except for those given on the command line, which take precedence.
+// The file is YAML or TOML, by its extension (.yaml, .yml or .toml), mapping flag names to values such as p: 100 or n = 8.
+// Lists are joined with commas, for the flags taking comma separated values (such as brokers or bench-workers)
+func loadConfigFile(fs *flag.FlagSet, path string, given map[string]bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	values := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s isn't YAML (.yaml or .yml) or TOML (.toml)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("decoding config file %s: %w", path, err)
+	}
+
+	// Sorted so a file with several bad values always reports the same one
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		switch {
+		case name == "config":
+			return fmt.Errorf("config file %s can't name another config file", path)
+		case fs.Lookup(name) == nil:
+			return fmt.Errorf("config file %s: unknown flag %q", path, name)
+		case given[name]:
+			continue
+		}
+		val, err := configValue(values[name])
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+		if err := fs.Set(name, val); err != nil {
+			return fmt.Errorf("config file %s: invalid value %q for %s: %w", path, val, name, err)
+		}
+	}
+	return nil
+}
+
+// configValue returns a value of the config file as the flag would be given it on the command line
+func configValue(val any) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		// Integers beyond int64 (such as a bound of a big range) come out as floats and lose precision, they have to be quoted
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v, flags take a string, number, boolean or list", val)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const CSV_FLUSH_INTERVAL = time.Second // Rows written since the last flush are lost if the process is killed
+
+// csvOutput streams each prime found to a CSV file as a prime,worker_id,found_at,attempt_count row
+// (prime,twin,worker_id,... in twin mode, number,factors,worker_id,... in factor mode with the factors separated by spaces, and exponent,worker_id,... in mersenne mode).
+// Rows are buffered and flushed to the file every CSV_FLUSH_INTERVAL, so a run that is killed keeps nearly all of its results
+type csvOutput struct {
+	mu     sync.Mutex
+	file   *os.File
+	w      *csv.Writer
+	search string // Mode flag, deciding the columns of the result
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// newCSVOutput creates (or truncates) the CSV file at path and starts flushing it in the background
+func newCSVOutput(path string) (*csvOutput, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating CSV file: %w", err)
+	}
+	o := &csvOutput{
+		file: file,
+		w:    csv.NewWriter(file),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go o.flushEvery(CSV_FLUSH_INTERVAL)
+	return o, nil
+}
+
+// flushEvery flushes the buffered rows on every tick until finish is called
+func (o *csvOutput) flushEvery(interval time.Duration) {
+	defer close(o.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+			o.mu.Lock()
+			o.w.Flush()
+			o.mu.Unlock()
+		}
+	}
+}
+
+func (o *csvOutput) start(cfg config) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.search = cfg.search
+	header := []string{"prime", "worker_id", "found_at", "attempt_count"}
+	switch o.search {
+	case SEARCH_TWIN:
+		header = slices.Insert(header, 1, "twin")
+	case SEARCH_FACTOR:
+		header = slices.Replace(header, 0, 1, "number", "factors")
+	case SEARCH_MERSENNE:
+		header[0] = "exponent"
+	}
+	o.w.Write(header)
+}
+
+func (o *csvOutput) prime(found pipeline.Found[result]) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	number := strconv.FormatInt(found.Value.Value, 10)
+	if found.Value.Big != nil {
+		number = found.Value.Big.String()
+	}
+	row := []string{
+		number,
+		strconv.Itoa(found.Worker),
+		found.At.Format(time.RFC3339Nano),
+		strconv.FormatInt(found.Attempts, 10),
+	}
+	switch o.search {
+	case SEARCH_TWIN:
+		row = slices.Insert(row, 1, strconv.FormatInt(found.Value.Twin, 10))
+	case SEARCH_FACTOR:
+		row = slices.Insert(row, 1, formatFactors(found.Value.Factors, " "))
+	}
+	o.w.Write(row)
+}
+
+// finish stops the background flushes, then flushes the last rows and closes the file.
+// A failed write is kept by the csv.Writer until the next flush, so it's reported here
+func (o *csvOutput) finish(sum summary) error {
+	close(o.stop)
+	<-o.done
+	o.w.Flush()
+	if err := errors.Join(o.w.Error(), o.file.Close()); err != nil {
+		return fmt.Errorf("writing CSV file: %w", err)
+	}
+	return nil
+}
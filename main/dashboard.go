@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	DASHBOARD_REFRESH   = 250 * time.Millisecond // How often the dashboard is redrawn
+	DASHBOARD_BAR_WIDTH = 40                     // Characters in the bar of the fastest worker, and of the progress bar
+)
+
+// Terminal escape sequences the dashboard is drawn with
+const (
+	ANSI_ENTER_SCREEN = "\x1b[?1049h\x1b[?25l" // Switch to the alternate screen and hide the cursor
+	ANSI_LEAVE_SCREEN = "\x1b[?25h\x1b[?1049l" // Show the cursor and switch back to the main screen
+	ANSI_HOME         = "\x1b[H"               // Move the cursor to the top left corner
+	ANSI_CLEAR_LINE   = "\x1b[K"               // Clear the rest of the line
+	ANSI_CLEAR_BELOW  = "\x1b[J"               // Clear the rest of the screen
+)
+
+// isTerminal returns whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// dashboardOutput draws a live view of the run on the terminal's alternate screen, redrawn in place every refresh: the numbers found against
+// the number requested with the elapsed time and an ETA, each worker's test rate as a bar, and whether each stage is waiting on its channels
+// (starved waiting for input, or saturated waiting for the next stage to take its output).
+// The output it wraps writes to the same terminal, so the results it's given are held back until the run is finished and the main screen is back
+type dashboardOutput struct {
+	output
+	w       io.Writer
+	rep     *report
+	refresh time.Duration
+	noun    string
+	goal    int           // Numbers requested, 0 for a continuous run
+	runFor  time.Duration // Duration of a continuous run
+	gate    *pipeline.Gate
+	found   atomic.Int64
+	held    []pipeline.Found[result]
+	started bool
+	closing sync.Once
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newDashboardOutput returns the dashboard drawn on w, wrapping out
+func newDashboardOutput(out output, w io.Writer, rep *report, refresh time.Duration) *dashboardOutput {
+	return &dashboardOutput{output: out, w: w, rep: rep, refresh: refresh, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// start lets the wrapped output write its header on the main screen before switching to the dashboard
+func (o *dashboardOutput) start(cfg config) {
+	o.output.start(cfg)
+	o.noun, o.goal, o.runFor, o.gate = searchNoun(cfg), cfg.numPrimes, cfg.duration, cfg.gate
+	fmt.Fprint(o.w, ANSI_ENTER_SCREEN)
+	o.started = true
+	go o.drawEvery()
+}
+
+func (o *dashboardOutput) prime(found pipeline.Found[result]) {
+	o.found.Add(1)
+	o.held = append(o.held, found)
+}
+
+// finish switches back to the main screen, then passes the results held back to the wrapped output
+func (o *dashboardOutput) finish(sum summary) error {
+	o.close()
+	for _, found := range o.held {
+		o.output.prime(found)
+	}
+	return o.output.finish(sum)
+}
+
+// close stops drawing the dashboard and switches back to the main screen. It's deferred by run as well, so a run that fails doesn't leave the terminal
+// on the alternate screen, and does nothing after the first call or if the dashboard wasn't started
+func (o *dashboardOutput) close() {
+	if !o.started {
+		return
+	}
+	o.closing.Do(func() {
+		close(o.stop)
+		<-o.done
+		fmt.Fprint(o.w, ANSI_LEAVE_SCREEN)
+	})
+}
+
+// drawEvery redraws the dashboard on every tick until close is called
+func (o *dashboardOutput) drawEvery() {
+	defer close(o.done)
+	ticker := time.NewTicker(o.refresh)
+	defer ticker.Stop()
+	start := time.Now()
+	last := dashboardSample{at: start}
+	for {
+		var now time.Time
+		select {
+		case <-o.stop:
+			return
+		case now = <-ticker.C:
+		}
+		next := o.sample(now)
+		fmt.Fprint(o.w, o.frame(start, last, next))
+		last = next
+	}
+}
+
+// dashboardSample is what the workers had tested at some point of the run, the rates drawn are the difference between two samples
+type dashboardSample struct {
+	at     time.Time
+	tested []int64 // Per worker, in the order they were started
+}
+
+func (o *dashboardOutput) sample(now time.Time) dashboardSample {
+	stats := o.rep.workerStats()
+	s := dashboardSample{at: now, tested: make([]int64, len(stats))}
+	for i, worker := range stats {
+		s.tested[i] = worker.Tested.Load()
+	}
+	return s
+}
+
+// frame returns the escape sequences and text drawing the dashboard over the previous frame, with the rates from last to now
+func (o *dashboardOutput) frame(start time.Time, last, now dashboardSample) string {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString(ANSI_CLEAR_LINE + "\n")
+	}
+	b.WriteString(ANSI_HOME)
+
+	found, elapsed := o.found.Load(), now.at.Sub(start)
+	left := eta(found, int64(o.goal), elapsed)
+	if o.runFor > 0 {
+		left = max(o.runFor-elapsed, 0).Round(time.Second).String()
+		line("Found    %d %s", found, o.noun)
+		line("Elapsed  %v, %s left", elapsed.Round(time.Second), left)
+	} else {
+		line("Found    %s %d of %d %s", bar(found, int64(o.goal), DASHBOARD_BAR_WIDTH), found, o.goal, o.noun)
+		line("Elapsed  %v, ETA %s", elapsed.Round(time.Second), left)
+	}
+	if o.gate != nil && o.gate.Paused() {
+		line("Paused   %s", PAUSE_HINT)
+	}
+
+	rates := make([]float64, len(now.tested))
+	var tested int64
+	var total, fastest float64
+	for i := range now.tested {
+		var before int64
+		if i < len(last.tested) {
+			before = last.tested[i]
+		}
+		rates[i] = float64(now.tested[i]-before) / now.at.Sub(last.at).Seconds()
+		tested += now.tested[i]
+		total += rates[i]
+		fastest = max(fastest, rates[i])
+	}
+	line("Tested   %d (%.0f/s)", tested, total)
+	line("")
+	line("Workers  %d active", o.rep.activeWorkers())
+	for i, rate := range rates {
+		line("  %3d  %s %8.0f/s", i, bar(int64(rate), int64(fastest), DASHBOARD_BAR_WIDTH), rate)
+	}
+
+	if stages := o.rep.stageFlows(); len(stages) > 0 {
+		line("")
+		line("Stages")
+		for _, stage := range stages {
+			line("  %-10s  %s", stage.name, stageState(stage.stats, now.at))
+		}
+	}
+	b.WriteString(ANSI_CLEAR_BELOW)
+	return b.String()
+}
+
+// stageState describes whether a stage's channels are backed up as of now: starved while it waits for input, saturated while it waits for the next stage.
+// With several goroutines sharing the stats (such as the workers), it's the wait that started last
+func stageState(stats *pipeline.FlowStats, now time.Time) string {
+	recv, send := stats.Waiting(now)
+	switch {
+	case send > 0:
+		return fmt.Sprintf("saturated for %v", send.Round(time.Millisecond))
+	case recv > 0:
+		return fmt.Sprintf("starved for %v", recv.Round(time.Millisecond))
+	}
+	return "flowing"
+}
+
+// bar draws value as a bar of width characters, full at total
+func bar(value, total int64, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = int(min(max(value, 0), total) * int64(width) / total)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
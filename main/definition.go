@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// Sinks of a pipeline definition, selected with a sink's type
+const (
+	SINK_STDOUT = "stdout" // Print each number, one per line
+	SINK_FILE   = "file"   // Write each number to the sink's path, one per line, once the pipeline has finished (see pipeline.SinkToFile)
+	SINK_COUNT  = "count"  // Count the numbers, printed once the pipeline has finished
+)
+
+// SOURCE_NODE is the name of the source's node in a pipeline definition, the input of the first stage by default
+const SOURCE_NODE = "source"
+
+// pipelineDefinition is a pipeline described in a YAML file for the pipeline command: a source of candidates, the stages they go through
+// and the sinks of the numbers coming out, wired as a pipeline.Graph. A stage or sink reads the stage before it by default,
+// its input names another (or the source) instead, so the stages can split the stream and the sinks take it from any stage. See examples/pipeline.yaml
+type pipelineDefinition struct {
+	Buffer int               `yaml:"buffer"` // Capacity of the channels between the stages
+	Source sourceDefinition  `yaml:"source"`
+	Stages []stageDefinition `yaml:"stages"`
+	Sinks  []sinkDefinition  `yaml:"sinks"`
+}
+
+// sourceDefinition is the source of a pipeline definition, with the settings of the run flags of the same names
+type sourceDefinition struct {
+	Type  string `yaml:"type"` // As the source flag, random by default
+	From  int64  `yaml:"from"`
+	To    int64  `yaml:"to"`    // DEFAULT_NUM_RANGE if 0
+	Max   int64  `yaml:"max"`   // Most candidates generated, 0 for no cap
+	Seed  *int64 `yaml:"seed"`  // Seed of the random source, unseeded if not set
+	Input string `yaml:"input"` // File the file source reads, stdin by default
+	Arg   string `yaml:"arg"`   // Argument of a registered source
+}
+
+// stageDefinition is a stage of a pipeline definition. It does one of: test the numbers with a filter on workers fanned in,
+// take the first ones, drop duplicates, limit their rate or join several stages
+type stageDefinition struct {
+	Name     string   `yaml:"name"`
+	Input    string   `yaml:"input"`    // Stage (or source) read, the one before by default
+	Filter   string   `yaml:"filter"`   // A predicate (prime, perfect-square or palindrome) or twin or mersenne, keeping the numbers passing it
+	Workers  int      `yaml:"workers"`  // Workers running the filter, 1 by default
+	Ordered  bool     `yaml:"ordered"`  // Whether the filter's workers keep the order of the numbers, see pipeline.OrderedWorkers
+	Take     int      `yaml:"take"`     // Number of numbers to keep, after which the stage closes its output
+	Distinct bool     `yaml:"distinct"` // Drop the numbers seen before
+	Rate     float64  `yaml:"rate"`     // Most numbers per second
+	Join     []string `yaml:"join"`     // Stages merged into one stream, instead of the input
+}
+
+// sinkDefinition is a sink of a pipeline definition
+type sinkDefinition struct {
+	Type  string `yaml:"type"`  // stdout, file or count
+	Input string `yaml:"input"` // Stage read, the last one by default
+	Path  string `yaml:"path"`  // File of the file sink
+}
+
+// loadDefinition reads the pipeline definition of the YAML file at path, rejecting fields it doesn't know so a typo doesn't go unnoticed
+func loadDefinition(path string) (pipelineDefinition, error) {
+	var def pipelineDefinition
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return def, fmt.Errorf("reading pipeline definition: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil && !errors.Is(err, io.EOF) {
+		return def, fmt.Errorf("decoding pipeline definition %s: %w", path, err)
+	}
+	if len(def.Sinks) == 0 {
+		return def, fmt.Errorf("pipeline definition %s has no sinks", path)
+	}
+	return def, nil
+}
+
+// runDefinition runs the pipeline described in the YAML file at path (see pipelineDefinition) until its sinks are done,
+// printing the counts of the count sinks once they are. The certainty flags select the test of the prime filters
+func runDefinition(cfg config, path string) error {
+	def, err := loadDefinition(path)
+	if err != nil {
+		return err
+	}
+	g, counts, err := buildDefinition(cfg, def)
+	if err != nil {
+		return fmt.Errorf("pipeline definition %s: %w", path, err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = g.Run(ctx)
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if err != nil {
+		return err
+	}
+	for _, count := range counts {
+		fmt.Printf("%s: %d\n", count.input, *count.n)
+	}
+	return nil
+}
+
+// definitionCount is where a count sink counts the numbers of its input
+type definitionCount struct {
+	input string
+	n     *int
+}
+
+// buildDefinition wires the graph of a pipeline definition, returning it with the counters of its count sinks
+func buildDefinition(cfg config, def pipelineDefinition) (*pipeline.Graph, []definitionCount, error) {
+	opts := []pipeline.Option{pipeline.WithBuffer(def.Buffer)}
+	g := pipeline.NewGraph(opts...)
+	edges := make(map[string]pipeline.Edge[int64])
+	// input returns the edge of a node, the previous one if name is empty
+	previous := SOURCE_NODE
+	input := func(name string) (pipeline.Edge[int64], error) {
+		if name == "" {
+			name = previous
+		}
+		edge, ok := edges[name]
+		if !ok {
+			return edge, fmt.Errorf("no stage named %q before it", name)
+		}
+		return edge, nil
+	}
+
+	getValue, err := definitionSource(cfg, def.Source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source: %w", err)
+	}
+	edges[SOURCE_NODE] = pipeline.NewEdge[int64](g, SOURCE_NODE)
+	pipeline.AddSource(g, SOURCE_NODE, edges[SOURCE_NODE], func(ctx context.Context) (<-chan int64, <-chan error) {
+		return pipeline.CreateValueStream(ctx, getValue, opts...)
+	})
+
+	for i, stage := range def.Stages {
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage %d", i+1)
+		}
+		if _, ok := edges[stage.Name]; ok {
+			return nil, nil, fmt.Errorf("two stages are named %q", stage.Name)
+		}
+		out := pipeline.NewEdge[int64](g, stage.Name)
+		if err := addDefinitionStage(g, cfg, stage, input, out, opts); err != nil {
+			return nil, nil, fmt.Errorf("stage %q: %w", stage.Name, err)
+		}
+		edges[stage.Name] = out
+		previous = stage.Name
+	}
+
+	var counts []definitionCount
+	for i, sink := range def.Sinks {
+		in, err := input(sink.Input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sink %d: %w", i+1, err)
+		}
+		name := fmt.Sprintf("%s sink %d", sink.Type, i+1)
+		switch sink.Type {
+		case SINK_STDOUT:
+			pipeline.AddSink(g, name, in, func(ctx context.Context, in <-chan int64) <-chan error {
+				return forEachNumber(in, func(num int64) { fmt.Println(num) })
+			})
+		case SINK_FILE:
+			if sink.Path == "" {
+				return nil, nil, fmt.Errorf("sink %d: the %s sink needs a path", i+1, SINK_FILE)
+			}
+			pipeline.AddSink(g, name, in, func(ctx context.Context, in <-chan int64) <-chan error {
+				return pipeline.SinkToFile(ctx, in, sink.Path, opts...)
+			})
+		case SINK_COUNT:
+			count := definitionCount{input: cmp.Or(sink.Input, previous), n: new(int)}
+			pipeline.AddSink(g, name, in, func(ctx context.Context, in <-chan int64) <-chan error {
+				return forEachNumber(in, func(int64) { *count.n++ })
+			})
+			counts = append(counts, count)
+		default:
+			return nil, nil, fmt.Errorf("sink %d: unknown type %q, not %s, %s or %s", i+1, sink.Type, SINK_STDOUT, SINK_FILE, SINK_COUNT)
+		}
+	}
+	return g, counts, nil
+}
+
+// definitionSource returns the getter of a definition's source, as valueSource does for the run flags
+func definitionSource(cfg config, source sourceDefinition) (func() (int64, error), error) {
+	cfg.source, cfg.from, cfg.numRange = cmp.Or(source.Type, SOURCE_RANDOM), source.From, source.To
+	cfg.inputPath, cfg.sourceArg = cmp.Or(source.Input, STDIN_INPUT), source.Arg
+	if cfg.numRange == 0 {
+		cfg.numRange = DEFAULT_NUM_RANGE
+	}
+	if rangeSource(cfg) {
+		if err := checkRange(cfg); err != nil {
+			return nil, err
+		}
+	}
+	getValue, err := valueSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if source.Seed != nil {
+		if cfg.source != SOURCE_RANDOM {
+			return nil, fmt.Errorf("a seed can only be used with the %s source", SOURCE_RANDOM)
+		}
+		getValue = pipeline.SeededRandValBetween(cfg.from, cfg.numRange, *source.Seed)
+	}
+	return countValues(new(report), source.Max, getValue), nil
+}
+
+// addDefinitionStage adds a stage of a definition to the graph, writing out
+func addDefinitionStage(g *pipeline.Graph, cfg config, stage stageDefinition, input func(string) (pipeline.Edge[int64], error), out pipeline.Edge[int64], opts []pipeline.Option) error {
+	kinds := 0
+	for _, set := range []bool{stage.Filter != "", stage.Take > 0, stage.Distinct, stage.Rate > 0, stage.Join != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errors.New("a stage sets one of filter, take, distinct, rate or join")
+	}
+	if stage.Join != nil {
+		if stage.Input != "" {
+			return errors.New("a join reads the stages it joins, it has no input")
+		}
+		ins := make([]pipeline.Edge[int64], len(stage.Join))
+		for i, name := range stage.Join {
+			var err error
+			if ins[i], err = input(name); err != nil {
+				return err
+			}
+		}
+		pipeline.AddJoin(g, stage.Name, ins, out)
+		return nil
+	}
+	in, err := input(stage.Input)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stage.Filter != "":
+		switch stage.Filter {
+		case PREDICATE_PRIME, PREDICATE_SQUARE, PREDICATE_PALINDROME:
+			cfg.predicate = stage.Filter
+		case SEARCH_TWIN, SEARCH_MERSENNE:
+			cfg.search = stage.Filter
+		default:
+			return fmt.Errorf("unknown filter %q", stage.Filter)
+		}
+		test := candidateTest(cfg)
+		keep := func(num int64) (bool, error) {
+			if num < 0 {
+				return false, fmt.Errorf("%w: negative number %d", pipeline.ErrInvalidInput, num)
+			}
+			return test(num), nil
+		}
+		pipeline.AddStage(g, stage.Name, in, out, func(ctx context.Context, in <-chan int64) (<-chan int64, <-chan error) {
+			if stage.Ordered {
+				stats := make([]*pipeline.Stats, max(stage.Workers, 1))
+				for i := range stats {
+					stats[i] = new(pipeline.Stats)
+				}
+				work := func(num int64) (int64, bool, error) {
+					keep, err := keep(num)
+					return num, keep, err
+				}
+				found, errc := pipeline.OrderedWorkers(ctx, in, work, stats, DEFAULT_REORDER_WINDOW, opts...)
+				return pipeline.Map(ctx, found, func(found pipeline.Found[int64]) int64 { return found.Value }, opts...), errc
+			}
+			workers := make([]<-chan int64, max(stage.Workers, 1))
+			errcs := make([]<-chan error, len(workers))
+			for i := range workers {
+				workers[i], errcs[i] = pipeline.FilterWorker(ctx, in, keep, nil, opts...)
+			}
+			return pipeline.ReduceWorkers(ctx, workers, opts...), pipeline.MergeErrors(errcs...)
+		})
+	case stage.Take > 0:
+		pipeline.AddStage(g, stage.Name, in, out, func(ctx context.Context, in <-chan int64) (<-chan int64, <-chan error) {
+			return takeFirst(ctx, in, stage.Take), nil
+		})
+	case stage.Distinct:
+		pipeline.AddStage(g, stage.Name, in, out, func(ctx context.Context, in <-chan int64) (<-chan int64, <-chan error) {
+			return pipeline.Distinct(ctx, in, 0, opts...), nil
+		})
+	case stage.Rate > 0:
+		pipeline.AddStage(g, stage.Name, in, out, func(ctx context.Context, in <-chan int64) (<-chan int64, <-chan error) {
+			return pipeline.Throttle(ctx, in, stage.Rate, 1, opts...), nil
+		})
+	}
+	return nil
+}
+
+// takeFirst forwards the first n numbers of a stream and closes its output, like pipeline.Take, but then drains the rest of the stream:
+// a stage that stopped reading its input would hold up the Tee feeding it when its input is split between several stages
+func takeFirst(ctx context.Context, numbers <-chan int64, n int) <-chan int64 {
+	taken := make(chan int64)
+	go func() {
+		defer func() {
+			for range numbers {
+			}
+		}()
+		defer close(taken)
+		for range n {
+			select {
+			case <-ctx.Done():
+				return
+			case num, ok := <-numbers:
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case taken <- num:
+				}
+			}
+		}
+	}()
+	return taken
+}
+
+// forEachNumber calls fn with each number of a stream, returning an error channel that is closed once the stream is
+func forEachNumber(numbers <-chan int64, fn func(int64)) <-chan error {
+	errc := make(chan error)
+	go func() {
+		defer close(errc)
+		for num := range numbers {
+			fn(num)
+		}
+	}()
+	return errc
+}
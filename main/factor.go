@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// runFactor factorizes the candidates drawn from the source with local workers, printing each factorization as it's found.
+// Every candidate is a result, so the run is bound by the cost of factorizing rather than by how rare primes are.
+// It returns how many numbers were factorized and the first error reported by any stage
+func runFactor(ctx context.Context, cancel context.CancelFunc, cfg config, rep *report, out output) (int, error) {
+	switch {
+	case cfg.source == SOURCE_KAFKA || cfg.transport != "" || enveloped(cfg) || cfg.redisAddr != "":
+		return 0, fmt.Errorf("the %s mode only runs local workers, it can't be combined with the %s source, a coordinator, tracing, latency tracking or Redis", SEARCH_FACTOR, SOURCE_KAFKA)
+	case cfg.autoscale || cfg.batchSize > 1 || cfg.priorityAbove > 0:
+		return 0, fmt.Errorf("the %s mode can't be combined with autoscaling, batching or priorities", SEARCH_FACTOR)
+	}
+
+	var factorStream <-chan pipeline.Found[pipeline.Factorization]
+	var errcs []<-chan error
+	if cfg.seeded {
+		var err error
+		factorStream, errcs, err = seededWorkers(ctx, cfg, rep, factorWorker)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		intStream, sourceErrs, err := producerStream(ctx, cfg, rep)
+		if err != nil {
+			return 0, err
+		}
+		var workers []<-chan pipeline.Found[pipeline.Factorization]
+		errcs = sourceErrs
+		switch cfg.pool {
+		case POOL_STEALING:
+			// Factorizations vary the most in cost, a number with two large prime factors takes far longer than one with small ones
+			var workerErrs <-chan error
+			workers, workerErrs = stealingWorkers(ctx, cfg, intStream, rep, func(num int64) (pipeline.Factorization, bool, error) {
+				f, err := pipeline.Factor(num)
+				return f, true, err
+			})
+			errcs = append(errcs, workerErrs)
+		case POOL_WORKERS:
+			var workerErrs []<-chan error
+			workers, workerErrs = startWorkers(ctx, cfg, intStream, cfg.numWorkers, rep, factorWorker)
+			errcs = append(errcs, workerErrs...)
+		default:
+			return 0, fmt.Errorf("the %s mode runs the %s or %s pool, not %s", SEARCH_FACTOR, POOL_WORKERS, POOL_STEALING, cfg.pool)
+		}
+		factorStream = pipeline.ReduceWorkers(ctx, workers, stageOptions(cfg, rep, "worker fan-in")...)
+	}
+
+	// As with primes, a number drawn again is only output once
+	distinctStream := pipeline.DistinctBy(ctx, factorStream, func(f pipeline.Found[pipeline.Factorization]) int64 { return f.Value.Num }, cfg.dedupLimit, distinctOptions(cfg, rep)...)
+	resultStream := pipeline.Take(ctx, distinctStream, cfg.numPrimes, stageOptions(cfg, rep, "result")...)
+	return collectResults(cancel, resultStream, pipeline.MergeErrors(errcs...), func(found pipeline.Found[pipeline.Factorization]) {
+		out.prime(factorResult(found))
+	})
+}
+
+// factorWorker starts a worker factorizing each candidate. Batching is rejected by runFactor, so it only reads intStream
+func factorWorker(ctx context.Context, intStream <-chan int64, batchStream <-chan []int64, stats *pipeline.Stats, opts ...pipeline.Option) (<-chan pipeline.Factorization, <-chan error) {
+	return pipeline.FactorWorker(ctx, intStream, stats, opts...)
+}
+
+// factorResult turns a factorization found by the workers into the result of the run
+func factorResult(found pipeline.Found[pipeline.Factorization]) pipeline.Found[result] {
+	// Factors is never nil on a factorization result, even when the number has none
+	r := result{Value: found.Value.Num, Factors: append([]int64{}, found.Value.Factors...)}
+	return pipeline.Found[result]{Value: r, Worker: found.Worker, At: found.At, Attempts: found.Attempts}
+}
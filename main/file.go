@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// fileOutput writes the primes found, one per line, to a file that only appears once the run has finished successfully (see pipeline.SinkToFile).
+// An interrupted or failed run leaves an existing file at the path untouched
+type fileOutput struct {
+	ctx    context.Context
+	primes chan result
+	errc   <-chan error
+}
+
+// newFileOutput starts the sink writing to path. Cancelling the context discards what was written so far
+func newFileOutput(ctx context.Context, path string) *fileOutput {
+	primes := make(chan result)
+	return &fileOutput{ctx: ctx, primes: primes, errc: pipeline.SinkToFile(ctx, primes, path)}
+}
+
+func (o *fileOutput) start(cfg config) {}
+
+func (o *fileOutput) prime(found pipeline.Found[result]) {
+	select {
+	case <-o.ctx.Done():
+	case o.primes <- found.Value:
+	}
+}
+
+// finish ends the sink's input stream and waits for the file to be renamed into place
+func (o *fileOutput) finish(sum summary) error {
+	close(o.primes)
+	if err := <-o.errc; err != nil {
+		return fmt.Errorf("writing results file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// gapSummary is the gap statistics of the numbers a run found, see pipeline.GapStats
+type gapSummary struct {
+	Gaps     int64   `json:"gaps"`
+	Min      int64   `json:"min"`
+	Max      int64   `json:"max"`
+	Mean     float64 `json:"mean"`
+	MaxAfter int64   `json:"max_after"`
+}
+
+// gapCollector is the output keeping the numbers found, so the gaps between them can be measured once the run is over.
+// Results that aren't a single int64 (factorizations and numbers beyond int64) have no gaps worth measuring and are left out
+type gapCollector struct {
+	mu      sync.Mutex
+	numbers []int64
+}
+
+func (c *gapCollector) start(cfg config) {}
+
+func (c *gapCollector) prime(found pipeline.Found[result]) {
+	if found.Value.Factors != nil || found.Value.Big != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.numbers = append(c.numbers, found.Value.Value)
+}
+
+func (c *gapCollector) finish(sum summary) error {
+	return nil
+}
+
+// gaps sorts the numbers found and runs them through the pipeline.Gaps stage, returning nil if there were fewer than two.
+// It's called once the run's context may be done, so it runs on a context of its own
+func (c *gapCollector) gaps() *gapSummary {
+	c.mu.Lock()
+	numbers := slices.Clone(c.numbers)
+	c.mu.Unlock()
+	slices.Sort(numbers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := 0
+	sortedStream, _ := pipeline.CreateValueStream(ctx, func() (int64, error) {
+		if next == len(numbers) {
+			return 0, pipeline.ErrExhausted
+		}
+		next++
+		return numbers[next-1], nil
+	})
+	// The numbers were sorted above, so the stage can't fail
+	statsStream, _ := pipeline.Gaps(ctx, sortedStream)
+	stats, ok := <-statsStream
+	if !ok {
+		return nil
+	}
+	return &gapSummary{Gaps: stats.Gaps, Min: stats.Min, Max: stats.Max, Mean: stats.Mean, MaxAfter: stats.MaxAfter}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// Implementations of the stream strategy, selected with the engine flag
+const (
+	ENGINE_CHANNELS = "channels" // Stages connected by channels, each failing stage reporting on its own error channel
+	ENGINE_ERRGROUP = "errgroup" // The producer and workers run in an errgroup, see pipeline.FilterGroup
+)
+
+// Pools of local workers of the channels engine, selected with the pool flag
+const (
+	POOL_WORKERS   = "workers"   // n workers with their own output streams, fanned in
+	POOL_SEMAPHORE = "semaphore" // One dispatcher starting a goroutine per candidate, up to n at once, see pipeline.SemaphoreWorker
+	POOL_STEALING  = "stealing"  // n workers with their own deques of candidates, stealing from each other, see pipeline.StealingWorkers
+	POOL_SHARDED   = "sharded"   // n workers each getting the candidates of one shard (see pipeline.Shard), deduped per shard
+)
+
+// checkEngine returns an error if the engine or pool flag isn't one of the known ones
+func checkEngine(cfg config) error {
+	if cfg.engine != ENGINE_CHANNELS && cfg.engine != ENGINE_ERRGROUP {
+		return fmt.Errorf("unknown engine %q", cfg.engine)
+	}
+	switch cfg.pool {
+	case POOL_WORKERS, POOL_SEMAPHORE, POOL_STEALING, POOL_SHARDED:
+		return nil
+	}
+	return fmt.Errorf("unknown pool %q", cfg.pool)
+}
+
+// runGroup is the stream strategy run with pipeline.FilterGroup: one producer and n workers in an errgroup, the first error cancelling them all.
+// The results are deduped and counted as they're emitted, and the group is stopped once P have been output.
+// It runs the local workers of the range sources, and returns how many results were found
+func runGroup(ctx context.Context, cfg config, rep *report, out output) (int, error) {
+	switch {
+	case cfg.seeded || cfg.autoscale || cfg.batchSize > 1 || cfg.rate > 0:
+		return 0, fmt.Errorf("the %s engine can't be combined with a seed, autoscaling, batching or a rate", ENGINE_ERRGROUP)
+	case cfg.transport != "" || enveloped(cfg) || cfg.checkpoint != nil:
+		return 0, fmt.Errorf("the %s engine can't be combined with a coordinator, tracing, latency tracking or checkpointing", ENGINE_ERRGROUP)
+	case cfg.numWorkers < 1:
+		return 0, fmt.Errorf("need at least one worker, got %d", cfg.numWorkers)
+	}
+	getValue, err := valueSource(cfg)
+	if err != nil {
+		return 0, err
+	}
+	// There's no source stage to tear down alone either, the workers stop drawing candidates once generation is over
+	sourceCtx := sourceContext(ctx, cfg)
+	if cfg.generation != nil {
+		draw := getValue
+		getValue = func() (int64, error) {
+			if sourceCtx.Err() != nil {
+				return 0, pipeline.ErrExhausted
+			}
+			return draw()
+		}
+	}
+	if gate := cfg.gate; gate != nil {
+		// There's no stream between the source and the workers to gate, the workers wait on the gate before drawing a candidate instead
+		draw := getValue
+		getValue = func() (int64, error) {
+			if !gate.Wait(sourceCtx) {
+				return 0, pipeline.ErrExhausted
+			}
+			return draw()
+		}
+	}
+	keep, err := workerTest(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	stats := make([]*pipeline.Stats, cfg.numWorkers)
+	for i := range stats {
+		_, stats[i] = rep.addWorker()
+	}
+
+	// Values are drawn with replacement, so duplicates are dropped before counting towards the result
+	seen := make(map[int64]bool)
+	isNew := func(num int64) bool {
+		if seen[num] {
+			return false
+		}
+		seen[num] = true
+		return true
+	}
+	if cfg.dedupLimit > 0 {
+		recent := newSeenCache(cfg.dedupLimit)
+		isNew = func(num int64) bool {
+			if recent.seen(num) {
+				return false
+			}
+			recent.add(num)
+			return true
+		}
+	}
+	found := 0
+	err = pipeline.FilterGroup(ctx, countValues(rep, cfg.maxCandidates, getValue), keep, stats, func(prime pipeline.Found[int64]) bool {
+		if !isNew(prime.Value) {
+			rep.discarded.Add(1)
+			return true
+		}
+		out.prime(newResult(cfg, prime))
+		found++
+		return found < cfg.numPrimes
+	}, stageOptions(cfg, rep, "group")...)
+	return found, err
+}
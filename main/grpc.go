@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/primefinderpb"
+)
+
+// primeFinderServer implements the PrimeFinder gRPC service, running the stream strategy for each call with the server's flags as defaults
+type primeFinderServer struct {
+	primefinderpb.UnimplementedPrimeFinderServer
+	jobs *jobServer
+}
+
+// FindPrimes runs the pipeline on the call's context, so it is cancelled when the client cancels the call or disconnects
+func (s *primeFinderServer) FindPrimes(req *primefinderpb.FindPrimesRequest, stream grpc.ServerStreamingServer[primefinderpb.Prime]) error {
+	cfg, err := s.jobs.jobConfig(jobRequest{Primes: int(req.Primes), Range: req.Range, Workers: int(req.Workers)})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	out := &grpcOutput{stream: stream, cancel: cancel}
+	var rep report
+	slog.Debug("FindPrimes call started", "primes", cfg.numPrimes, "range", cfg.numRange, "workers", cfg.numWorkers)
+	found, err := runStream(ctx, cfg, &rep, out)
+	switch {
+	case err != nil:
+		return status.Error(codes.Internal, err.Error())
+	case out.err != nil:
+		return out.err
+	case stream.Context().Err() != nil:
+		slog.Debug("FindPrimes call cancelled by the client", "found", found)
+		return status.FromContextError(stream.Context().Err()).Err()
+	}
+	return nil
+}
+
+// grpcOutput sends each prime of a FindPrimes call on its stream. A failed send cancels the pipeline
+type grpcOutput struct {
+	stream grpc.ServerStreamingServer[primefinderpb.Prime]
+	cancel context.CancelFunc
+	err    error
+}
+
+func (o *grpcOutput) start(cfg config) {}
+
+func (o *grpcOutput) prime(found pipeline.Found[result]) {
+	if o.err != nil {
+		return
+	}
+	o.err = o.stream.Send(&primefinderpb.Prime{
+		Value:    found.Value.Value,
+		Worker:   int32(found.Worker),
+		FoundAt:  timestamppb.New(found.At),
+		Attempts: found.Attempts,
+	})
+	if o.err != nil {
+		o.cancel()
+	}
+}
+
+func (o *grpcOutput) finish(sum summary) error {
+	return nil
+}
+
+// serveGRPC starts a gRPC server on addr serving the PrimeFinder service. It returns the server, to be stopped on shutdown, and a channel carrying the error it stops with
+func serveGRPC(addr string, jobs *jobServer) (*grpc.Server, <-chan error, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	server := grpc.NewServer()
+	primefinderpb.RegisterPrimeFinderServer(server, &primeFinderServer{jobs: jobs})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(lis)
+	}()
+	slog.Info("serving PrimeFinder gRPC service", "addr", addr)
+	return server, errc, nil
+}
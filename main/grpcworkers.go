@@ -0,0 +1,568 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/primefinderpb"
+)
+
+const (
+	DEFAULT_COORDINATOR_ADDR = ":9100"
+	DEFAULT_COORDINATOR_DIAL = "localhost:9100"
+	DEFAULT_WORKER_TIMEOUT   = 10 * time.Second
+	GRPC_HEARTBEATS          = 3                // Heartbeats a worker sends per worker-timeout, so one lost or late doesn't drop it
+	GRPC_REGISTER_TIMEOUT    = 10 * time.Second // Longest the coordinator waits for a new call's Register
+	GRPC_RECONNECT_DELAY     = time.Second      // Wait before a worker calls the coordinator again, doubling with each failed call in a row
+	GRPC_RECONNECT_MAX       = 5 * time.Second
+)
+
+// grpcBatch is a batch of candidates handed out to the remote workers, with the ID their results name it by
+type grpcBatch struct {
+	id         uint64
+	partition  int
+	candidates []int64
+}
+
+// grpcAssignment is a batch in flight to a worker
+type grpcAssignment struct {
+	batch grpcBatch
+	sent  time.Time
+}
+
+// grpcCoordinator serves the Coordinator gRPC service, handing out the batches of a run to the workers that register with it, up to each one's capacity.
+// The candidates are split into partitions (see partitionTracker), which a consistent hash ring of the workers' names assigns to the workers:
+// each batch holds the candidates of one partition and goes to its owner, so a worker registering or leaving only moves the partitions
+// the ring gives it or takes from it. A worker keeps the batches it was sent until it answers them: those of a worker whose call ends,
+// or which hasn't been heard from for worker-timeout, go to the new owners of their partitions. The run waits for a worker to register
+// if there's none, and its candidates are only done with once every batch has been answered
+type grpcCoordinator struct {
+	primefinderpb.UnimplementedCoordinatorServer
+	ctx     context.Context
+	rep     *report
+	timeout time.Duration
+	parts   *partitionTracker
+	primes  chan pipeline.Found[int64]
+	errc    chan error // Buffered, holds the first error failing the run
+
+	mu          sync.Mutex
+	ring        *pipeline.HashRing
+	peers       map[string]chan grpcBatch // The batches sent to each registered worker by name, received by its call while it has room
+	owners      []string                  // Owner of each partition, empty while no worker is registered
+	changed     chan struct{}             // Closed and replaced whenever a worker registers or leaves, so the batches waiting on an owner are routed again
+	outstanding int                       // Batches fed and not answered yet
+	exhausted   bool                      // Set once every batch has been fed
+	finished    chan struct{}             // Closed once every batch has been answered
+}
+
+// grpcWorkers serves the Coordinator service on the listen flag's address and returns the primes the remote workers registered with it find.
+// Like remoteWorkers the coordinator then fans in and dedups the results as it does for local workers. n isn't used: each worker's capacity,
+// the n of its own flags, bounds the batches in flight to it
+func grpcWorkers(ctx context.Context, cfg config, intStream <-chan int64, _ int, rep *report) ([]<-chan pipeline.Found[int64], []<-chan error, error) {
+	if cfg.workerTimeout <= 0 {
+		return nil, nil, fmt.Errorf("worker-timeout flag: must be positive, got %s", cfg.workerTimeout)
+	}
+	lis, err := net.Listen("tcp", cfg.coordinatorAddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listening for gRPC workers: %w", err)
+	}
+	parts := rep.partitioned()
+	c := &grpcCoordinator{
+		ctx:      ctx,
+		rep:      rep,
+		timeout:  cfg.workerTimeout,
+		parts:    parts,
+		primes:   make(chan pipeline.Found[int64]),
+		errc:     make(chan error, 1),
+		ring:     pipeline.NewHashRing(RING_REPLICAS),
+		peers:    make(map[string]chan grpcBatch),
+		owners:   make([]string, parts.count()),
+		changed:  make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	// Stop waits for the calls' handlers to return, so none is left sending primes once the channel is closed.
+	// Keepalive pings close the connection of a worker that's gone without a word, failing a send to it that would otherwise hang
+	server := grpc.NewServer(grpc.WaitForHandlers(true), grpc.KeepaliveParams(keepalive.ServerParameters{Time: cfg.workerTimeout, Timeout: cfg.workerTimeout}))
+	primefinderpb.RegisterCoordinatorServer(server, c)
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := server.Serve(lis); err != nil {
+			c.fail(fmt.Errorf("serving gRPC workers: %w", err))
+		}
+	}()
+
+	go c.feed(intStream, remoteBatch(cfg), cfg.batchWait)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.finished:
+		}
+		server.Stop()
+		<-served
+		close(c.primes)
+		close(c.errc)
+	}()
+	slog.Info("waiting for gRPC workers to register", "addr", lis.Addr().String(), "partitions", parts.count())
+	return []<-chan pipeline.Found[int64]{c.primes}, []<-chan error{c.errc}, nil
+}
+
+// partitionRun sets up the partitions of a gRPC coordinator's run (see partitionTracker) in the report, which grpcWorkers then assigns to the workers
+func partitionRun(cfg config, rep *report) error {
+	if cfg.partitions < 1 {
+		return fmt.Errorf("partitions flag: must be positive, got %d", cfg.partitions)
+	}
+	rep.setPartitions(newPartitionTracker(cfg, cfg.partitions, remoteBatch(cfg)))
+	return nil
+}
+
+// remoteBatch returns the candidates in a batch sent to a remote worker: the batch flag's size, or DEFAULT_REMOTE_BATCH if it isn't set
+func remoteBatch(cfg config) int {
+	if cfg.batchSize <= 1 {
+		return DEFAULT_REMOTE_BATCH
+	}
+	return cfg.batchSize
+}
+
+// feed batches the candidates by partition, a partition's batch going to its owner once it holds size candidates, or once wait has passed
+// if it's above 0, until the candidates run out
+func (c *grpcCoordinator) feed(intStream <-chan int64, size int, wait time.Duration) {
+	pending := make([][]int64, c.parts.count())
+	var id uint64
+	flush := func(p int) bool {
+		if len(pending[p]) == 0 {
+			return true
+		}
+		id++
+		c.mu.Lock()
+		c.outstanding++
+		c.mu.Unlock()
+		b := grpcBatch{id: id, partition: p, candidates: pending[p]}
+		pending[p] = nil
+		return c.dispatch(b)
+	}
+	var tick <-chan time.Time
+	if wait > 0 {
+		ticker := time.NewTicker(wait)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-tick:
+			for p := range pending {
+				if !flush(p) {
+					return
+				}
+			}
+		case num, ok := <-intStream:
+			if !ok {
+				for p := range pending {
+					if !flush(p) {
+						return
+					}
+				}
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				c.exhausted = true
+				if c.outstanding == 0 {
+					close(c.finished)
+				}
+				return
+			}
+			p := c.parts.partition(num)
+			pending[p] = append(pending[p], num)
+			if len(pending[p]) >= size && !flush(p) {
+				return
+			}
+		}
+	}
+}
+
+// dispatch sends a batch to the owner of its partition, routing it again if the owner changes before taking it, and waiting for a worker
+// to register if there's none. It returns false if the run is over first
+func (c *grpcCoordinator) dispatch(b grpcBatch) bool {
+	for {
+		c.mu.Lock()
+		// Nil while no worker is registered, which blocks the send until one is
+		owner, changed := c.peers[c.owners[b.partition]], c.changed
+		c.mu.Unlock()
+		select {
+		case <-c.ctx.Done():
+			return false
+		case owner <- b:
+			return true
+		case <-changed:
+		}
+	}
+}
+
+// join registers a worker, putting it on the ring, and returns the channel its batches are sent on. It returns false if a worker
+// of the same name is registered already, as the ring tells workers apart by name
+func (c *grpcCoordinator) join(name string) (chan grpcBatch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.peers[name]; ok {
+		return nil, false
+	}
+	batches := make(chan grpcBatch)
+	c.peers[name] = batches
+	c.ring.Add(name)
+	c.reassign()
+	return batches, true
+}
+
+// leave takes a worker off the ring, so its partitions go to the others
+func (c *grpcCoordinator) leave(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.peers, name)
+	c.ring.Remove(name)
+	c.reassign()
+}
+
+// reassign looks up the owner of every partition after the ring changed, and wakes the batches waiting on their owner. c.mu must be held
+func (c *grpcCoordinator) reassign() {
+	for p := range c.owners {
+		c.owners[p], _ = c.ring.Owner("partition-" + strconv.Itoa(p))
+	}
+	// The workers' calls end with the run, which isn't a move of their partitions
+	if !c.over() {
+		c.parts.assign(c.owners)
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// answered records that a batch has been answered, once its primes have been sent on
+func (c *grpcCoordinator) answered() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outstanding--
+	if c.exhausted && c.outstanding == 0 {
+		close(c.finished)
+	}
+}
+
+// requeue hands a batch of a lost worker to the new owner of its partition
+func (c *grpcCoordinator) requeue(b grpcBatch) {
+	go c.dispatch(b)
+}
+
+// over returns whether the run is over, its context cancelled or every batch answered, which ends the calls of the workers too
+func (c *grpcCoordinator) over() bool {
+	select {
+	case <-c.ctx.Done():
+		return true
+	case <-c.finished:
+		return true
+	default:
+		return false
+	}
+}
+
+// fail fails the run with err, unless it has already failed
+func (c *grpcCoordinator) fail(err error) {
+	select {
+	case c.errc <- err:
+	default:
+	}
+}
+
+// Work registers the calling worker and sends it batches while it has room for them, sending on the primes of the batches it answers
+func (c *grpcCoordinator) Work(stream grpc.BidiStreamingServer[primefinderpb.WorkerMessage, primefinderpb.CoordinatorMessage]) error {
+	ctx := stream.Context()
+	msgs := make(chan *primefinderpb.WorkerMessage)
+	recvErr := make(chan error, 1)
+	// The stream's context is cancelled once Work returns, which ends the Recv
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case msgs <- msg:
+			}
+		}
+	}()
+
+	var reg *primefinderpb.Register
+	select {
+	case <-c.ctx.Done():
+		return status.Error(codes.Unavailable, "the run is over")
+	case err := <-recvErr:
+		return err
+	case <-time.After(GRPC_REGISTER_TIMEOUT):
+		return status.Errorf(codes.DeadlineExceeded, "no Register within %s", GRPC_REGISTER_TIMEOUT)
+	case msg := <-msgs:
+		if reg = msg.GetRegister(); reg == nil {
+			return status.Error(codes.InvalidArgument, "the first message of a worker must be a Register")
+		}
+	}
+	name := reg.Name
+	if p, ok := peer.FromContext(ctx); ok && name == "" {
+		name = p.Addr.String()
+	}
+	capacity := max(int(reg.Capacity), 1)
+	batches, ok := c.join(name)
+	if !ok {
+		return status.Errorf(codes.AlreadyExists, "a worker named %q is registered already", name)
+	}
+	// Deferred first so it runs last, once the batches in flight have been requeued: they're routed again when the ring changes
+	defer c.leave(name)
+	index, stats := c.rep.addWorker()
+	err := stream.Send(&primefinderpb.CoordinatorMessage{Message: &primefinderpb.CoordinatorMessage_Registered{Registered: &primefinderpb.Registered{
+		Worker:            int32(index),
+		HeartbeatInterval: durationpb.New(c.timeout / GRPC_HEARTBEATS),
+	}}})
+	if err != nil {
+		return err
+	}
+	slog.Info("gRPC worker registered", "worker", index, "name", name, "capacity", capacity)
+
+	inflight := make(map[uint64]grpcAssignment)
+	// However the call ends, the batches the worker hasn't answered go to the others, unless the run is over
+	defer func() {
+		if c.over() {
+			return
+		}
+		for _, a := range inflight {
+			c.requeue(a.batch)
+		}
+		if len(inflight) > 0 {
+			slog.Info("reassigning the batches of a gRPC worker", "worker", index, "name", name, "batches", len(inflight))
+		}
+	}()
+	lastSeen := time.Now()
+	check := time.NewTicker(c.timeout / GRPC_HEARTBEATS)
+	defer check.Stop()
+	for {
+		// A worker with as many batches as it tests at once isn't given another one
+		work := batches
+		if len(inflight) >= capacity {
+			work = nil
+		}
+		var next grpcBatch
+		select {
+		case <-c.ctx.Done():
+			return status.Error(codes.Unavailable, "the run is over")
+		case next = <-work:
+		case msg := <-msgs:
+			lastSeen = time.Now()
+			if result := msg.GetResult(); result != nil {
+				if err := c.answer(result, inflight, index, stats); err != nil {
+					c.fail(fmt.Errorf("gRPC worker %s: %w", name, err))
+					return status.Error(codes.Aborted, err.Error())
+				}
+			}
+			continue
+		case err := <-recvErr:
+			if c.over() {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				slog.Info("gRPC worker left", "worker", index, "name", name)
+				return nil
+			}
+			slog.Warn("gRPC worker lost", "worker", index, "name", name, "err", err)
+			return err
+		case <-check.C:
+			if silent := time.Since(lastSeen); silent > c.timeout {
+				slog.Warn("gRPC worker timed out", "worker", index, "name", name, "silent", silent.Round(time.Millisecond))
+				return status.Errorf(codes.DeadlineExceeded, "no message from the worker for %s", silent.Round(time.Millisecond))
+			}
+			continue
+		}
+		// Recorded before it's sent, so a batch whose send fails is reassigned too
+		inflight[next.id] = grpcAssignment{batch: next, sent: time.Now()}
+		err := stream.Send(&primefinderpb.CoordinatorMessage{Message: &primefinderpb.CoordinatorMessage_Batch{Batch: &primefinderpb.Batch{Id: next.id, Candidates: next.candidates}}})
+		if err != nil {
+			if !c.over() {
+				slog.Warn("gRPC worker lost", "worker", index, "name", name, "err", err)
+			}
+			return err
+		}
+	}
+}
+
+// answer sends on the primes of a batch a worker answered. It returns the error the worker answered with, if it did
+func (c *grpcCoordinator) answer(result *primefinderpb.BatchResult, inflight map[uint64]grpcAssignment, index int, stats *pipeline.Stats) error {
+	if result.Error != "" {
+		return errors.New(result.Error)
+	}
+	a, ok := inflight[result.Batch]
+	if !ok {
+		return fmt.Errorf("answered batch %d, which it wasn't sent", result.Batch)
+	}
+	delete(inflight, result.Batch)
+	stats.TestTime.Add(int64(time.Since(a.sent)))
+	stats.Tested.Add(int64(len(a.batch.candidates)))
+	stats.Found.Add(int64(len(result.Primes)))
+	c.parts.record(a.batch.partition, int64(len(a.batch.candidates)))
+	for _, prime := range result.Primes {
+		sendStart := time.Now()
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case c.primes <- pipeline.Found[int64]{Value: prime, Worker: index, At: time.Now(), Attempts: int64(len(a.batch.candidates))}:
+		}
+		stats.SendBlocked.Add(int64(time.Since(sendStart)))
+	}
+	c.answered()
+	return nil
+}
+
+// runGRPCWorker registers with the coordinator at the coordinator flag's address and tests the batches it sends with n goroutines, until SIGINT/SIGTERM.
+// It calls the coordinator again whenever a call ends, backing off while it can't be reached, so a worker can be started before the coordinator
+// and serves one run after another. The batches it hasn't answered when it stops are reassigned by the coordinator
+func runGRPCWorker(cfg config) error {
+	if cfg.search == SEARCH_FACTOR {
+		return fmt.Errorf("workers only test candidates, they can't run in the %s mode", cfg.search)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	conn, err := grpc.NewClient(cfg.coordinatorAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("connecting to the coordinator: %w", err)
+	}
+	defer conn.Close()
+	client := primefinderpb.NewCoordinatorClient(conn)
+	// The coordinator tells workers apart by name, and several may run on one host
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%s-%d", host, os.Getpid())
+	workers := max(cfg.numWorkers, 1)
+	isPrime := candidateTest(cfg)
+	slog.Info("gRPC worker started", "coordinator", cfg.coordinatorAddr, "name", name, "workers", workers)
+
+	delay := GRPC_RECONNECT_DELAY
+	for {
+		registered, err := workFor(ctx, client, name, workers, isPrime)
+		if ctx.Err() != nil {
+			break
+		}
+		if registered {
+			// The call of a worker ends with the coordinator's run
+			delay = GRPC_RECONNECT_DELAY
+			slog.Info("coordinator call ended, calling again", "coordinator", cfg.coordinatorAddr, "retry_in", delay, "err", err)
+		} else {
+			slog.Warn("coordinator unreachable, calling again", "coordinator", cfg.coordinatorAddr, "retry_in", delay, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, GRPC_RECONNECT_MAX)
+	}
+	slog.Info("gRPC worker stopping")
+	return nil
+}
+
+// workFor registers with the coordinator and tests the batches it sends with n goroutines until the call ends, returning whether the worker got registered
+func workFor(ctx context.Context, client primefinderpb.CoordinatorClient, name string, n int, isPrime pipeline.PrimalityTest) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Work(ctx)
+	if err != nil {
+		return false, err
+	}
+	err = stream.Send(&primefinderpb.WorkerMessage{Message: &primefinderpb.WorkerMessage_Register{Register: &primefinderpb.Register{Name: name, Capacity: int32(n)}}})
+	if err != nil {
+		return false, err
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		return false, err
+	}
+	reg := msg.GetRegistered()
+	if reg == nil {
+		return false, errors.New("the coordinator didn't answer the Register")
+	}
+	interval := reg.HeartbeatInterval.AsDuration()
+	if interval <= 0 {
+		interval = DEFAULT_WORKER_TIMEOUT / GRPC_HEARTBEATS
+	}
+	slog.Info("registered with the coordinator", "worker", reg.Worker, "heartbeat", interval)
+
+	batches := make(chan *primefinderpb.Batch, n)
+	replies := make(chan *primefinderpb.WorkerMessage)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				result := &primefinderpb.BatchResult{Batch: b.Id}
+				primes, err := testCandidates(b.Candidates, isPrime)
+				result.Primes = primes
+				if err != nil {
+					result.Error = err.Error()
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case replies <- &primefinderpb.WorkerMessage{Message: &primefinderpb.WorkerMessage_Result{Result: result}}:
+				}
+			}
+		}()
+	}
+	// Every message goes through one goroutine, as a stream's Send mustn't be called concurrently. Heartbeats are sent even while testing,
+	// as a batch can take longer than the coordinator waits to hear from a worker
+	go func() {
+		heartbeat := time.NewTicker(interval)
+		defer heartbeat.Stop()
+		for {
+			var msg *primefinderpb.WorkerMessage
+			select {
+			case <-ctx.Done():
+				return
+			case msg = <-replies:
+			case <-heartbeat.C:
+				msg = &primefinderpb.WorkerMessage{Message: &primefinderpb.WorkerMessage_Heartbeat{Heartbeat: &primefinderpb.Heartbeat{}}}
+			}
+			if err := stream.Send(msg); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	// The coordinator sends at most n batches before they're answered, so the buffer always has room for the next one
+	for {
+		msg, err = stream.Recv()
+		if err != nil {
+			break
+		}
+		if b := msg.GetBatch(); b != nil {
+			batches <- b
+		}
+	}
+	close(batches)
+	cancel()
+	wg.Wait()
+	return true, err
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const HISTOGRAM_WIDTH = 50 // Characters in the bar of the fullest bucket
+
+// histogramBucket is one bin of the histogram, the numbers found from low up to high (excluded)
+type histogramBucket struct {
+	Low   *big.Int `json:"low"`
+	High  *big.Int `json:"high"`
+	Count int      `json:"count"`
+}
+
+// histogramOutput bins the numbers a run finds by value into equal buckets spanning the range, and adds the histogram to the summary.
+// The results it's given are put on a stream that is teed (see pipeline.Tee) to the output it wraps and to an aggregator goroutine counting them,
+// so the counting happens off the path of the results. Numbers outside the range (read by the file or kafka source) are counted in the first or last bucket
+type histogramOutput struct {
+	output
+	resultStream chan pipeline.Found[result]
+	wg           sync.WaitGroup
+	low, width   *big.Int
+	buckets      []histogramBucket
+}
+
+// newHistogramOutput returns the output wrapping out with a histogram of n buckets, which is started once start is called
+func newHistogramOutput(out output, n int) *histogramOutput {
+	return &histogramOutput{output: out, resultStream: make(chan pipeline.Found[result]), buckets: make([]histogramBucket, n)}
+}
+
+// start divides the range into the buckets and starts the goroutines reading the tee, the wrapped output's first.
+// The tee runs until finish closes its input: cancelling it with the run would drop the results in flight
+func (o *histogramOutput) start(cfg config) {
+	low, high := rangeBounds(cfg)
+	n := big.NewInt(int64(len(o.buckets)))
+	// Rounded up so the last bucket ends at or above the top of the range, and at least 1 for a range smaller than the number of buckets
+	o.low = low
+	o.width = new(big.Int).Sub(high, low)
+	o.width.Add(o.width, n).Sub(o.width, big.NewInt(1)).Quo(o.width, n)
+	for i := range o.buckets {
+		o.buckets[i].Low = new(big.Int).Add(low, new(big.Int).Mul(o.width, big.NewInt(int64(i))))
+		o.buckets[i].High = new(big.Int).Add(o.buckets[i].Low, o.width)
+	}
+
+	o.output.start(cfg)
+	streams := pipeline.Tee(context.Background(), o.resultStream, 2)
+	o.wg.Add(2)
+	go func() {
+		defer o.wg.Done()
+		for found := range streams[0] {
+			o.output.prime(found)
+		}
+	}()
+	go func() {
+		defer o.wg.Done()
+		o.aggregate(streams[1])
+	}()
+}
+
+func (o *histogramOutput) prime(found pipeline.Found[result]) {
+	o.resultStream <- found
+}
+
+// finish waits for the goroutines to be done with the results, then adds the histogram to the summary the wrapped output finishes with
+func (o *histogramOutput) finish(sum summary) error {
+	close(o.resultStream)
+	o.wg.Wait()
+	sum.Histogram = o.buckets
+	return o.output.finish(sum)
+}
+
+// aggregate counts each number of the stream in its bucket, until the stream closes
+func (o *histogramOutput) aggregate(resultStream <-chan pipeline.Found[result]) {
+	num, index := new(big.Int), new(big.Int)
+	last := int64(len(o.buckets) - 1)
+	for found := range resultStream {
+		if found.Value.Big != nil {
+			num.Set(found.Value.Big)
+		} else {
+			num.SetInt64(found.Value.Value)
+		}
+		index.Sub(num, o.low).Div(index, o.width)
+		i := last
+		if index.IsInt64() {
+			i = min(max(index.Int64(), 0), last)
+		} else if index.Sign() < 0 {
+			i = 0
+		}
+		o.buckets[i].Count++
+	}
+}
+
+// printHistogram writes the histogram as a table with a bar for each bucket, scaled to the fullest one
+func printHistogram(w io.Writer, buckets []histogramBucket) {
+	fullest := 0
+	for _, bucket := range buckets {
+		fullest = max(fullest, bucket.Count)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tw, "Histogram:")
+	for _, bucket := range buckets {
+		bar := 0
+		if fullest > 0 {
+			bar = bucket.Count * HISTOGRAM_WIDTH / fullest
+		}
+		fmt.Fprintf(tw, "[%v,\t%v)\t%s %d\n", bucket.Low, bucket.High, strings.Repeat("#", bar), bucket.Count)
+	}
+	tw.Flush()
+}
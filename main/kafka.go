@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	SOURCE_KAFKA          = "kafka" // Consume values from a Kafka topic, see runKafka
+	DEFAULT_KAFKA_GROUP   = "go-concurrency-sample"
+	KAFKA_COMMIT_INTERVAL = time.Second
+	KAFKA_COMMIT_TIMEOUT  = 5 * time.Second
+	DEFAULT_RETRIES       = 5
+	DEFAULT_RETRY_BACKOFF = 100 * time.Millisecond
+	RETRY_MAX_BACKOFF     = 10 * time.Second
+	RETRY_JITTER          = 0.5 // Producers retrying together are spread over the second half of each backoff
+)
+
+// kafkaCandidate is a candidate number along with the message it was read from, so its offset can be committed once it's tested
+type kafkaCandidate struct {
+	Value int64
+	msg   kafka.Message
+}
+
+// runKafka is the stream strategy with candidates consumed from a Kafka topic by a consumer group, acting as a long-running stream processor.
+// A message's offset is only committed once the candidate in it has been tested, so a run that is killed picks up from the first untested candidate.
+// Workers test candidates out of order, so offsets are committed up to the first candidate of each partition that is still being tested (see offsetTracker).
+// A message that isn't an integer is logged and skipped, rather than stopping the processor on every restart.
+// A producer that fails to fetch from the brokers is restarted with an exponential backoff (see pipeline.Retry), up to the retries flag's times in a row
+func runKafka(ctx context.Context, cancel context.CancelFunc, cfg config, rep *report, out output) (int, error) {
+	if cfg.seeded || cfg.autoscale || cfg.batchSize > 1 || enveloped(cfg) {
+		return 0, fmt.Errorf("the %s source can't be combined with a seed, autoscaling, batching, tracing or latency tracking", SOURCE_KAFKA)
+	}
+	if cfg.kafkaBrokers == "" || cfg.kafkaTopic == "" {
+		return 0, fmt.Errorf("the %s source needs the brokers and topic flags", SOURCE_KAFKA)
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: strings.Split(cfg.kafkaBrokers, ","),
+		Topic:   cfg.kafkaTopic,
+		GroupID: cfg.kafkaGroup,
+	})
+	defer reader.Close()
+	offsets := newOffsetTracker(reader)
+	defer offsets.stop()
+
+	// Producers share the reader, which is safe for concurrent use. Fetching stops with the producers
+	sourceCtx := sourceContext(ctx, cfg)
+	getCandidate := func() (kafkaCandidate, error) {
+		for {
+			if !rep.reserve(cfg.maxCandidates) {
+				return kafkaCandidate{}, pipeline.ErrExhausted
+			}
+			msg, err := reader.FetchMessage(sourceCtx)
+			if err != nil {
+				rep.generated.Add(-1)
+				if sourceCtx.Err() != nil {
+					return kafkaCandidate{}, pipeline.ErrExhausted
+				}
+				return kafkaCandidate{}, fmt.Errorf("reading from Kafka: %w", err)
+			}
+			offsets.fetched(msg)
+			num, err := strconv.ParseInt(strings.TrimSpace(string(msg.Value)), 10, 64)
+			if err != nil {
+				slog.Warn("skipping Kafka message that isn't an integer", "partition", msg.Partition, "offset", msg.Offset, "value", string(msg.Value))
+				offsets.tested(msg)
+				rep.generated.Add(-1)
+				continue
+			}
+			return kafkaCandidate{Value: num, msg: msg}, nil
+		}
+	}
+	var errcs []<-chan error
+	producers := make([]<-chan kafkaCandidate, max(cfg.numProducers, 1))
+	sourceOpts := stageOptions(cfg, rep, "source")
+	policy := pipeline.RetryPolicy{MaxRetries: cfg.retries, Initial: cfg.retryBackoff, Max: RETRY_MAX_BACKOFF, Jitter: RETRY_JITTER}
+	for i := range producers {
+		var sourceErrs <-chan error
+		producers[i], sourceErrs = pipeline.Retry(sourceCtx, func() (<-chan kafkaCandidate, <-chan error) {
+			return pipeline.CreateValueStream(sourceCtx, getCandidate, sourceOpts...)
+		}, policy, stageOptions(cfg, rep, "retry")...)
+		errcs = append(errcs, sourceErrs)
+	}
+	candidateStream := producers[0]
+	if len(producers) > 1 {
+		candidateStream = pipeline.ReduceWorkers(ctx, producers, stageOptions(cfg, rep, "producer fan-in")...)
+	}
+	candidateStream = throttle(ctx, cfg, rep, candidateStream, 1)
+
+	// Workers mark each candidate tested as soon as the test returns, prime or not
+	isPrime := candidateTest(cfg)
+	keep := func(c kafkaCandidate) (bool, error) {
+		if c.Value < 0 {
+			return false, fmt.Errorf("%w: negative candidate %d", pipeline.ErrInvalidInput, c.Value)
+		}
+		prime := isPrime(c.Value)
+		offsets.tested(c.msg)
+		return prime, nil
+	}
+	workers := make([]<-chan pipeline.Found[kafkaCandidate], cfg.numWorkers)
+	workerOpts, annotateOpts := stageOptions(cfg, rep, "worker"), stageOptions(cfg, rep, "annotate")
+	for i := range workers {
+		index, stats := rep.addWorker()
+		worker, workerErrs := pipeline.FilterWorker(ctx, candidateStream, keep, stats, workerOpts...)
+		workers[i] = pipeline.Annotate(ctx, worker, index, stats, annotateOpts...)
+		errcs = append(errcs, workerErrs)
+	}
+
+	reducedStream := pipeline.ReduceWorkers(ctx, workers, fanInOptions(cfg, rep)...)
+	distinctStream := pipeline.DistinctBy(ctx, reducedStream, func(found pipeline.Found[kafkaCandidate]) int64 { return found.Value.Value }, cfg.dedupLimit, distinctOptions(cfg, rep)...)
+	resultStream := pipeline.Take(ctx, distinctStream, cfg.numPrimes, stageOptions(cfg, rep, "result")...)
+	return collectResults(cancel, resultStream, pipeline.MergeErrors(errcs...), func(found pipeline.Found[kafkaCandidate]) {
+		out.prime(newResult(cfg, pipeline.Found[int64]{Value: found.Value.Value, Worker: found.Worker, At: found.At, Attempts: found.Attempts}))
+	})
+}
+
+// offsetTracker commits the offsets of a consumer group's messages once they've been tested, which happens out of order across workers.
+// For each partition it keeps the offsets fetched but not yet committed, in order, and only moves the committed offset past a run of tested ones.
+// Commits are sent every KAFKA_COMMIT_INTERVAL rather than once per message
+type offsetTracker struct {
+	reader *kafka.Reader
+
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+	commits    map[int]kafka.Message // Latest message to commit per partition, since the last commit
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+type partitionOffsets struct {
+	pending []kafka.Message // Fetched and not committed yet, in offset order
+	tested  map[int64]bool
+}
+
+func newOffsetTracker(reader *kafka.Reader) *offsetTracker {
+	t := &offsetTracker{
+		reader:     reader,
+		partitions: make(map[int]*partitionOffsets),
+		commits:    make(map[int]kafka.Message),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go t.commitEvery(KAFKA_COMMIT_INTERVAL)
+	return t
+}
+
+// fetched records a message read from the topic. Messages of a partition are fetched in offset order
+func (t *offsetTracker) fetched(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.partitions[msg.Partition]
+	if p == nil {
+		p = &partitionOffsets{tested: make(map[int64]bool)}
+		t.partitions[msg.Partition] = p
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// tested marks a message as done, queuing a commit up to the first message of its partition that is still being tested
+func (t *offsetTracker) tested(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.partitions[msg.Partition]
+	p.tested[msg.Offset] = true
+	for len(p.pending) > 0 && p.tested[p.pending[0].Offset] {
+		t.commits[msg.Partition] = p.pending[0]
+		delete(p.tested, p.pending[0].Offset)
+		p.pending = p.pending[1:]
+	}
+}
+
+// commitEvery sends the queued commits on every tick, and once more when the tracker is stopped
+func (t *offsetTracker) commitEvery(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.quit:
+			t.commit()
+			return
+		case <-ticker.C:
+			t.commit()
+		}
+	}
+}
+
+// commit sends the queued commits. The run's context may be cancelled already, so a fresh one bounds the commit
+func (t *offsetTracker) commit() {
+	t.mu.Lock()
+	msgs := make([]kafka.Message, 0, len(t.commits))
+	for _, msg := range t.commits {
+		msgs = append(msgs, msg)
+	}
+	clear(t.commits)
+	t.mu.Unlock()
+	if len(msgs) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), KAFKA_COMMIT_TIMEOUT)
+	defer cancel()
+	if err := t.reader.CommitMessages(ctx, msgs...); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("committing Kafka offsets", "err", err)
+	}
+}
+
+// stop sends the last commits and stops the tracker
+func (t *offsetTracker) stop() {
+	close(t.quit)
+	<-t.done
+}
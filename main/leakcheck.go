@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const LEAK_CHECK_TIMEOUT = time.Second // How long the pipeline's goroutines get to exit once the run is over
+
+// checkLeaks fails a run whose pipeline left goroutines running once the run is over, logging the stack of each one.
+// goroutines is the snapshot taken before the pipeline was built, so the outputs and listeners started before it aren't counted
+func checkLeaks(goroutines pipeline.Goroutines) error {
+	leaked := goroutines.Leaked(LEAK_CHECK_TIMEOUT)
+	for _, stack := range leaked {
+		slog.Error("goroutine leaked", "stack", stack)
+	}
+	if len(leaked) > 0 {
+		return fmt.Errorf("leak check: %d goroutines still running %v after the run", len(leaked), LEAK_CHECK_TIMEOUT)
+	}
+	slog.Info("leak check passed, every goroutine of the pipeline has exited")
+	return nil
+}
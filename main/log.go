@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Log formats, selected with the -log-format flag
+const (
+	LOG_FORMAT_TEXT = "text" // key=value pairs
+	LOG_FORMAT_JSON = "json" // One JSON object per line
+)
+
+// newLogger returns a logger writing records of the given level (debug, info, warn or error) and above to w in the given format
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case LOG_FORMAT_TEXT:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case LOG_FORMAT_JSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
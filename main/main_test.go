@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCheckRunFlagsSegmented(t *testing.T) {
+	for _, args := range [][]string{
+		{"-source=random"},
+		{"-source=sequential", "-seed=3"},
+		{"-source=sequential", "-autoscale"},
+		{"-source=sequential", "-batch=8"},
+		{"-source=sequential", "-producers=2"},
+	} {
+		cfg := testConfig(t, append(args, "-strategy=segmented", "-r=1000")...)
+		if err := checkRunFlags(cfg); err == nil {
+			t.Errorf("checkRunFlags accepted the %s strategy with %q", STRATEGY_SEGMENTED, args)
+		}
+	}
+	if err := checkRunFlags(testConfig(t, "-strategy=segmented", "-source=sequential", "-r=1000", "-n=4")); err != nil {
+		t.Errorf("checkRunFlags rejected the %s strategy with the %s source: %v", STRATEGY_SEGMENTED, SOURCE_SEQUENTIAL, err)
+	}
+}
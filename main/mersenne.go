@@ -0,0 +1,88 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// mersenneTests tracks the Lucas-Lehmer tests the workers are running in mersenne mode, so the progress output can show how far each one got.
+// A test of a large exponent takes seconds, where the tests of the other modes take microseconds and are only worth counting once done
+type mersenneTests struct {
+	mu      sync.Mutex
+	running map[*mersenneTest]struct{}
+}
+
+// mersenneTest is a Lucas-Lehmer test in flight. Its steps are updated by the worker running it and read by the progress output
+type mersenneTest struct {
+	exponent int64
+	started  time.Time
+	done     atomic.Int64
+	total    atomic.Int64
+	stepped  atomic.Int64 // Unix nanoseconds of the last step, 0 before the first
+}
+
+// mersenneStatus is how far a test in flight got
+type mersenneStatus struct {
+	exponent    int64
+	done, total int64
+	elapsed     time.Duration
+}
+
+func newMersenneTests() *mersenneTests {
+	return &mersenneTests{running: make(map[*mersenneTest]struct{})}
+}
+
+// test returns the workers' test of an exponent, tracked while it runs. It stops with the context's error once the context is cancelled,
+// so an interrupted run doesn't wait for the tests in flight to finish
+func (t *mersenneTests) test(ctx context.Context) func(int64) (bool, error) {
+	return func(p int64) (bool, error) {
+		test := &mersenneTest{exponent: p, started: time.Now()}
+		t.mu.Lock()
+		t.running[test] = struct{}{}
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.running, test)
+			t.mu.Unlock()
+		}()
+		return pipeline.LucasLehmer(ctx, p, func(done, total int64) {
+			test.done.Store(done)
+			test.total.Store(total)
+			test.stepped.Store(time.Now().UnixNano())
+		})
+	}
+}
+
+// status returns how far each test in flight got, by exponent. Tests that haven't done a step yet (most of them, rejected for their exponent) are left out
+func (t *mersenneTests) status(now time.Time) []mersenneStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var running []mersenneStatus
+	for test := range t.running {
+		if total := test.total.Load(); total > 0 {
+			running = append(running, mersenneStatus{test.exponent, test.done.Load(), total, now.Sub(test.started)})
+		}
+	}
+	slices.SortFunc(running, func(a, b mersenneStatus) int { return cmp.Compare(a.exponent, b.exponent) })
+	return running
+}
+
+// lastStep returns when a test of the exponent in flight last did a step, or false if none did yet.
+// A Lucas-Lehmer test takes long enough that the watchdog counts its steps as the worker's heartbeats
+func (t *mersenneTests) lastStep(exponent int64) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var last int64
+	for test := range t.running {
+		if test.exponent == exponent {
+			last = max(last, test.stepped.Load())
+		}
+	}
+	return time.Unix(0, last), last != 0
+}
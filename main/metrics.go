@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// pipelineDuration observes how long each run of the pipeline took
+var pipelineDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "primes_pipeline_duration_seconds",
+	Help:    "Duration of completed pipeline runs.",
+	Buckets: prometheus.ExponentialBuckets(0.001, 4, 12),
+})
+
+// latencyQuantiles are the quantiles of the result latency published as metrics
+var latencyQuantiles = []float64{0.5, 0.95, 0.99}
+
+// metricsCollector exposes the counters of a running pipeline. Values are read from the report when scraped, so the pipeline isn't slowed down updating metrics
+type metricsCollector struct {
+	rep   *report
+	start time.Time
+
+	generated   *prometheus.Desc
+	tested      *prometheus.Desc
+	found       *prometheus.Desc
+	testTime    *prometheus.Desc
+	sendBlocked *prometheus.Desc
+	workers     *prometheus.Desc
+	elapsed     *prometheus.Desc
+	stageRecv   *prometheus.Desc
+	stageSend   *prometheus.Desc
+	latency     *prometheus.Desc
+	breaker     *prometheus.Desc
+	trips       *prometheus.Desc
+	postFailed  *prometheus.Desc
+	dropped     *prometheus.Desc
+	cacheHits   *prometheus.Desc
+	cacheMisses *prometheus.Desc
+	cacheSize   *prometheus.Desc
+}
+
+func newMetricsCollector(rep *report, start time.Time) *metricsCollector {
+	return &metricsCollector{
+		rep:         rep,
+		start:       start,
+		generated:   prometheus.NewDesc("primes_candidates_generated_total", "Candidate numbers produced by the sources.", nil, nil),
+		tested:      prometheus.NewDesc("primes_candidates_tested_total", "Candidate numbers checked for primality, per worker.", []string{"worker"}, nil),
+		found:       prometheus.NewDesc("primes_found_total", "Prime numbers found, per worker. Includes duplicates dropped before the result stream.", []string{"worker"}, nil),
+		testTime:    prometheus.NewDesc("primes_worker_test_seconds_total", "Time workers spent running the primality test.", []string{"worker"}, nil),
+		sendBlocked: prometheus.NewDesc("primes_worker_send_blocked_seconds_total", "Time workers spent blocked sending prime numbers to the fan-in stage.", []string{"worker"}, nil),
+		workers:     prometheus.NewDesc("primes_workers", "Number of workers currently running.", nil, nil),
+		elapsed:     prometheus.NewDesc("primes_pipeline_elapsed_seconds", "Time since the running pipeline was started.", nil, nil),
+		stageRecv:   prometheus.NewDesc("primes_stage_recv_blocked_seconds_total", "Time stages spent waiting for input, per stage (summed over the goroutines of a stage, such as the workers).", []string{"stage"}, nil),
+		stageSend:   prometheus.NewDesc("primes_stage_send_blocked_seconds_total", "Time stages spent blocked sending to the next stage, per stage (summed over the goroutines of a stage, such as the workers).", []string{"stage"}, nil),
+		breaker:     prometheus.NewDesc("primes_webhook_breaker_state", "State of the webhook's circuit breaker: 0 closed, 1 open, 2 half-open.", nil, nil),
+		trips:       prometheus.NewDesc("primes_webhook_breaker_trips_total", "Times the webhook's circuit breaker opened.", nil, nil),
+		postFailed:  prometheus.NewDesc("primes_webhook_failed_total", "Posts to the webhook that failed.", nil, nil),
+		dropped:     prometheus.NewDesc("primes_webhook_dropped_total", "Primes never posted to the webhook, dropped while its circuit breaker was open.", nil, nil),
+		cacheHits:   prometheus.NewDesc("primes_cache_hits_total", "Candidates whose test result was found in the workers' cache, with the cache flag.", nil, nil),
+		cacheMisses: prometheus.NewDesc("primes_cache_misses_total", "Candidates tested and added to the workers' cache, with the cache flag.", nil, nil),
+		cacheSize:   prometheus.NewDesc("primes_cache_entries", "Candidates held in the workers' cache, with the cache flag.", nil, nil),
+		latency:     prometheus.NewDesc("primes_result_latency_seconds", "Time from a result's generation as a candidate to its emission, with the latency flag. Quantiles are estimated from a sample.", nil, nil),
+	}
+}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	workers := c.rep.workerStats()
+	ch <- prometheus.MustNewConstMetric(c.generated, prometheus.CounterValue, float64(c.rep.generated.Load()))
+	ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(c.rep.activeWorkers()))
+	ch <- prometheus.MustNewConstMetric(c.elapsed, prometheus.GaugeValue, time.Since(c.start).Seconds())
+	for i, stats := range workers {
+		worker := strconv.Itoa(i)
+		ch <- prometheus.MustNewConstMetric(c.tested, prometheus.CounterValue, float64(stats.Tested.Load()), worker)
+		ch <- prometheus.MustNewConstMetric(c.found, prometheus.CounterValue, float64(stats.Found.Load()), worker)
+		ch <- prometheus.MustNewConstMetric(c.testTime, prometheus.CounterValue, stats.TestDuration().Seconds(), worker)
+		ch <- prometheus.MustNewConstMetric(c.sendBlocked, prometheus.CounterValue, stats.SendBlockedTime().Seconds(), worker)
+	}
+	for _, stage := range c.rep.stageFlows() {
+		ch <- prometheus.MustNewConstMetric(c.stageRecv, prometheus.CounterValue, stage.stats.RecvBlockedTime().Seconds(), stage.name)
+		ch <- prometheus.MustNewConstMetric(c.stageSend, prometheus.CounterValue, stage.stats.SendBlockedTime().Seconds(), stage.name)
+	}
+	if breaker := c.rep.breaker; breaker != nil {
+		ch <- prometheus.MustNewConstMetric(c.breaker, prometheus.GaugeValue, float64(breaker.State()))
+		ch <- prometheus.MustNewConstMetric(c.trips, prometheus.CounterValue, float64(breaker.Trips.Load()))
+		ch <- prometheus.MustNewConstMetric(c.postFailed, prometheus.CounterValue, float64(breaker.Failed.Load()))
+		ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(breaker.Dropped.Load()))
+	}
+	if cache := c.rep.resultCache(); cache != nil {
+		ch <- prometheus.MustNewConstMetric(c.cacheHits, prometheus.CounterValue, float64(cache.Hits.Load()))
+		ch <- prometheus.MustNewConstMetric(c.cacheMisses, prometheus.CounterValue, float64(cache.Misses.Load()))
+		ch <- prometheus.MustNewConstMetric(c.cacheSize, prometheus.GaugeValue, float64(cache.Len()))
+	}
+	if count := c.rep.latency.Count(); count > 0 {
+		quantiles := c.rep.latency.Quantiles(latencyQuantiles...)
+		values := make(map[float64]float64, len(quantiles))
+		for i, q := range latencyQuantiles {
+			values[q] = quantiles[i].Seconds()
+		}
+		ch <- prometheus.MustNewConstSummary(c.latency, uint64(count), c.rep.latency.Sum().Seconds(), values)
+	}
+}
+
+// serveMetrics starts an HTTP listener on addr publishing the run's metrics at /metrics. A listener that fails is logged without stopping the run
+func serveMetrics(addr string, rep *report, start time.Time) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newMetricsCollector(rep, start), pipelineDuration)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("metrics listener stopped", "addr", addr, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	NATS_SUBJECT         = "primes.candidates" // Subject batches of candidates are sent to
+	NATS_QUEUE           = "primes-workers"    // Queue group workers join, so each batch goes to one worker
+	NATS_REQUEST_TIMEOUT = 30 * time.Second    // Longest a coordinator waits for a worker to test a batch
+	NATS_RETRY_DELAY     = time.Second         // Wait before retrying a batch when no worker is subscribed
+	DEFAULT_REMOTE_BATCH = 100                 // Candidates per message to remote workers when the batch flag isn't set, one per message would be dominated by round trips
+)
+
+// natsBatch is the body of a request a coordinator sends to the workers
+type natsBatch struct {
+	Candidates []int64 `json:"candidates"`
+}
+
+// natsResult is a worker's reply to a batch, with the primes it found in it
+type natsResult struct {
+	Primes []int64 `json:"primes"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// remoteWorkers fans out batches of candidates to workers running in worker mode, possibly on other machines, over NATS.
+// Each of the n remote workers is a goroutine sending one batch at a time as a request and waiting for the reply, so at most n batches are in flight
+// and a slow set of workers holds the coordinator back. The coordinator fans in and dedups the results as it does for local workers
+func remoteWorkers(ctx context.Context, cfg config, intStream <-chan int64, n int, rep *report) ([]<-chan pipeline.Found[int64], []<-chan error, error) {
+	nc, err := nats.Connect(cfg.natsURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to NATS: %w", err)
+	}
+	context.AfterFunc(ctx, nc.Close)
+
+	size := cfg.batchSize
+	if size <= 1 {
+		size = DEFAULT_REMOTE_BATCH
+	}
+	batchStream := pipeline.Batch(ctx, intStream, size, cfg.batchWait, stageOptions(cfg, rep, "batch")...)
+	workers := make([]<-chan pipeline.Found[int64], n)
+	errcs := make([]<-chan error, n)
+	for i := 0; i < n; i++ {
+		index, stats := rep.addWorker()
+		workers[i], errcs[i] = remoteWorker(ctx, nc, batchStream, index, stats)
+	}
+	return workers, errcs, nil
+}
+
+// remoteWorker sends each batch of candidates to a worker over NATS, and outputs the primes in the worker's reply
+func remoteWorker(ctx context.Context, nc *nats.Conn, batchStream <-chan []int64, index int, stats *pipeline.Stats) (<-chan pipeline.Found[int64], <-chan error) {
+	primeStream := make(chan pipeline.Found[int64])
+	errc := make(chan error, 1)
+	go func() {
+		defer close(primeStream)
+		defer close(errc)
+		for batch := range batchStream {
+			testStart := time.Now()
+			result, err := requestBatch(ctx, nc, batch)
+			if err != nil {
+				if ctx.Err() == nil {
+					errc <- err
+				}
+				return
+			}
+			stats.TestTime.Add(int64(time.Since(testStart)))
+			stats.Tested.Add(int64(len(batch)))
+			stats.Found.Add(int64(len(result.Primes)))
+			for _, prime := range result.Primes {
+				sendStart := time.Now()
+				select {
+				case <-ctx.Done():
+					return
+				case primeStream <- pipeline.Found[int64]{Value: prime, Worker: index, At: time.Now(), Attempts: int64(len(batch))}:
+				}
+				stats.SendBlocked.Add(int64(time.Since(sendStart)))
+			}
+		}
+	}()
+	return primeStream, errc
+}
+
+// requestBatch sends a batch to the workers' queue group and returns the reply, retrying while no worker is subscribed
+func requestBatch(ctx context.Context, nc *nats.Conn, batch []int64) (natsResult, error) {
+	body, err := json.Marshal(natsBatch{Candidates: batch})
+	if err != nil {
+		return natsResult{}, err
+	}
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, NATS_REQUEST_TIMEOUT)
+		msg, err := nc.RequestWithContext(reqCtx, NATS_SUBJECT, body)
+		cancel()
+		if errors.Is(err, nats.ErrNoResponders) {
+			slog.Warn("no NATS workers subscribed, retrying", "subject", NATS_SUBJECT)
+			select {
+			case <-ctx.Done():
+				return natsResult{}, ctx.Err()
+			case <-time.After(NATS_RETRY_DELAY):
+			}
+			continue
+		}
+		if err != nil {
+			return natsResult{}, fmt.Errorf("sending batch to NATS workers: %w", err)
+		}
+
+		var result natsResult
+		if err := json.Unmarshal(msg.Data, &result); err != nil {
+			return natsResult{}, fmt.Errorf("decoding NATS worker reply: %w", err)
+		}
+		if result.Error != "" {
+			return natsResult{}, fmt.Errorf("NATS worker: %s", result.Error)
+		}
+		return result, nil
+	}
+}
+
+// runNATSWorker joins the workers' queue group and tests the batches coordinators send with n goroutines, until SIGINT/SIGTERM.
+// It drains the subscription on the way out, so the batches it has already received are still answered
+func runNATSWorker(cfg config) error {
+	if cfg.search == SEARCH_FACTOR {
+		return fmt.Errorf("workers only test candidates, they can't run in the %s mode", cfg.search)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	nc, err := nats.Connect(cfg.natsURL)
+	if err != nil {
+		return fmt.Errorf("connecting to NATS: %w", err)
+	}
+	defer nc.Close()
+
+	msgs := make(chan *nats.Msg, cfg.numWorkers)
+	sub, err := nc.ChanQueueSubscribe(NATS_SUBJECT, NATS_QUEUE, msgs)
+	if err != nil {
+		return fmt.Errorf("subscribing to %s: %w", NATS_SUBJECT, err)
+	}
+	slog.Info("NATS worker started", "url", cfg.natsURL, "subject", NATS_SUBJECT, "workers", cfg.numWorkers)
+
+	var wg sync.WaitGroup
+	isPrime := candidateTest(cfg)
+	for i := 0; i < max(cfg.numWorkers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				msg.Respond(testBatch(msg.Data, isPrime))
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	slog.Info("NATS worker stopping")
+	err = sub.Drain()
+	// Wait for the subscription to stop delivering before closing the channel the goroutines read
+	for sub.IsValid() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(msgs)
+	wg.Wait()
+	return err
+}
+
+// testBatch tests the candidates of a coordinator's batch and returns the encoded reply
+func testBatch(data []byte, isPrime pipeline.PrimalityTest) []byte {
+	var batch natsBatch
+	var result natsResult
+	if err := json.Unmarshal(data, &batch); err != nil {
+		result.Error = fmt.Sprintf("decoding batch: %v", err)
+	}
+	primes, err := testCandidates(batch.Candidates, isPrime)
+	result.Primes = primes
+	if err != nil && result.Error == "" {
+		result.Error = err.Error()
+	}
+	reply, _ := json.Marshal(result)
+	return reply
+}
+
+// testCandidates returns the primes among a remote worker's batch of candidates, stopping at the first negative one
+func testCandidates(candidates []int64, isPrime pipeline.PrimalityTest) ([]int64, error) {
+	primes := []int64{}
+	for _, num := range candidates {
+		if num < 0 {
+			return primes, fmt.Errorf("%w: negative candidate %d", pipeline.ErrInvalidInput, num)
+		}
+		if isPrime(num) {
+			primes = append(primes, num)
+		}
+	}
+	return primes, nil
+}
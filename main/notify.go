@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	NOTIFY_TIMEOUT  = 10 * time.Second
+	NOTIFY_ATTEMPTS = 4           // Posts tried in all before the notification is given up on
+	NOTIFY_BACKOFF  = time.Second // Wait before the first retry, doubling with each retry after it
+)
+
+// Outcomes of a run, as notified
+const (
+	RUN_DONE        = "done"
+	RUN_INTERRUPTED = "interrupted"
+	RUN_TIMED_OUT   = "timed_out"
+	RUN_FAILED      = "failed"
+)
+
+// runNotification is the body posted to the notify-url flag's URL once a run is over.
+// Text is a one line account of the run, which is what Slack's incoming webhooks show
+type runNotification struct {
+	Text            string            `json:"text"`
+	Status          string            `json:"status"`
+	Flags           map[string]string `json:"flags"`
+	Found           int               `json:"found"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Error           string            `json:"error,omitempty"`
+	Summary         *summary          `json:"summary,omitempty"` // nil if the run failed before it got to its summary
+}
+
+// notifyOutput posts the outcome of a run to the notify-url flag's URL once it's over, whether it finished, was stopped or failed,
+// so a long unattended run can report to a chat channel or a callback service. As an output it counts the results and keeps the summary,
+// and run calls notify with its error once it returns. A failed post is retried NOTIFY_ATTEMPTS times in all, backing off from NOTIFY_BACKOFF,
+// and a notification that can't be posted is logged rather than failing the run
+type notifyOutput struct {
+	url     string
+	started time.Time
+	found   int
+	sum     *summary
+}
+
+func newNotifyOutput(url string) *notifyOutput {
+	return &notifyOutput{url: url, started: time.Now()}
+}
+
+func (o *notifyOutput) start(cfg config) {}
+
+func (o *notifyOutput) prime(found pipeline.Found[result]) {
+	o.found++
+}
+
+func (o *notifyOutput) finish(sum summary) error {
+	o.sum = &sum
+	return nil
+}
+
+// notify posts the outcome of the run that returned runErr
+func (o *notifyOutput) notify(runErr error) {
+	n := runNotification{Status: RUN_DONE, Flags: usedFlags(), Found: o.found, DurationSeconds: time.Since(o.started).Seconds(), Summary: o.sum}
+	switch {
+	case errors.Is(runErr, errInterrupted):
+		n.Status = RUN_INTERRUPTED
+	case errors.Is(runErr, errDeadline):
+		n.Status = RUN_TIMED_OUT
+	case runErr != nil:
+		n.Status, n.Error = RUN_FAILED, runErr.Error()
+	}
+	if o.sum != nil {
+		n.Found, n.DurationSeconds = o.sum.Found, o.sum.DurationSeconds
+	}
+	n.Text = fmt.Sprintf("Prime run %s: found %d in %s", strings.ReplaceAll(n.Status, "_", " "), n.Found, (time.Duration(n.DurationSeconds * float64(time.Second))).Round(time.Millisecond))
+	if n.Error != "" {
+		n.Text += ": " + n.Error
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		slog.Error("encoding run notification", "err", err)
+		return
+	}
+	wait := NOTIFY_BACKOFF
+	for attempt := 1; ; attempt++ {
+		err := o.post(body)
+		if err == nil {
+			slog.Debug("run notification posted", "url", o.url, "status", n.Status)
+			return
+		}
+		if attempt == NOTIFY_ATTEMPTS {
+			slog.Error("posting run notification", "url", o.url, "attempts", attempt, "err", err)
+			return
+		}
+		slog.Warn("posting run notification, retrying", "url", o.url, "attempt", attempt, "retry_in", wait, "err", err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post makes one attempt at posting the notification
+func (o *notifyOutput) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), NOTIFY_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification endpoint responded %s", resp.Status)
+	}
+	return nil
+}
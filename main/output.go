@@ -0,0 +1,423 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// Output formats, selected with the -output flag
+const (
+	OUTPUT_TEXT    = "text"    // Human readable lines
+	OUTPUT_JSON    = "json"    // A single JSON document written at the end of the run
+	OUTPUT_JSONL   = "jsonl"   // One JSON object per line, written as the run goes
+	OUTPUT_PROTO   = "proto"   // Size-delimited protobuf messages, written as the run goes (see primefinderpb/results.proto)
+	OUTPUT_MSGPACK = "msgpack" // MessagePack maps one after the other, written as the run goes
+)
+
+// output writes the results of a run in one of the output formats. finish returns the first error hit while writing
+type output interface {
+	start(cfg config)
+	prime(found pipeline.Found[result])
+	finish(sum summary) error
+}
+
+// multiOutput writes a run to several outputs, such as the output format on stdout and a CSV file
+type multiOutput []output
+
+func (m multiOutput) start(cfg config) {
+	for _, o := range m {
+		o.start(cfg)
+	}
+}
+
+func (m multiOutput) prime(found pipeline.Found[result]) {
+	for _, o := range m {
+		o.prime(found)
+	}
+}
+
+func (m multiOutput) finish(sum summary) error {
+	var errs []error
+	for _, o := range m {
+		errs = append(errs, o.finish(sum))
+	}
+	return errors.Join(errs...)
+}
+
+// sortedOutput holds back the primes of a run until it's finished, then passes them to the output in ascending order
+type sortedOutput struct {
+	output
+	found []pipeline.Found[result]
+}
+
+func (o *sortedOutput) prime(found pipeline.Found[result]) {
+	o.found = append(o.found, found)
+}
+
+func (o *sortedOutput) finish(sum summary) error {
+	slices.SortFunc(o.found, func(a, b pipeline.Found[result]) int { return a.Value.compare(b.Value) })
+	for _, found := range o.found {
+		o.output.prime(found)
+	}
+	return o.output.finish(sum)
+}
+
+// summary describes a finished run
+type summary struct {
+	Requested       int               `json:"requested"`
+	Found           int               `json:"found"`
+	Interrupted     bool              `json:"interrupted"`
+	TimedOut        bool              `json:"timed_out"`
+	Capped          bool              `json:"capped"` // The max-candidates flag stopped the sources before every prime requested was found
+	Strategy        string            `json:"strategy"`
+	Generated       int64             `json:"generated"` // Candidates produced by the sources, 0 for the sieve strategy
+	Tested          int64             `json:"tested"`
+	Duplicates      int64             `json:"duplicates"`  // Results found again and dropped by the dedup stage
+	Prefiltered     int64             `json:"prefiltered"` // Candidates the wheel pre-filter rejected before the workers, with the wheel flag
+	Workers         []workerSummary   `json:"workers"`
+	Scaling         []scaleSummary    `json:"scaling,omitempty"`
+	Partitions      *partitionSummary `json:"partitions,omitempty"` // Nil unless a gRPC coordinator ran the range across its workers
+	Cache           *cacheSummary     `json:"cache,omitempty"`      // Nil without the cache flag
+	Gaps            *gapSummary       `json:"gaps,omitempty"`       // Set by run, nil with fewer than two numbers found
+	Latency         *latencySummary   `json:"latency,omitempty"`    // Nil without the latency flag or a result
+	Histogram       []histogramBucket `json:"histogram,omitempty"`  // Set by histogramOutput, nil without the histogram flag
+	Duration        time.Duration     `json:"-"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	TestedPerSecond float64           `json:"tested_per_second"`
+	FoundPerSecond  float64           `json:"found_per_second"`
+	Speedup         float64           `json:"speedup"` // Time the workers spent testing over the run's duration, an estimate of what running them in parallel gained
+}
+
+type workerSummary struct {
+	Worker             int     `json:"worker"`
+	Tested             int64   `json:"tested"`
+	Found              int64   `json:"found"`
+	TestSeconds        float64 `json:"test_seconds"`
+	SendBlockedSeconds float64 `json:"send_blocked_seconds"`
+	Restarts           int64   `json:"restarts,omitempty"` // Times the worker was restarted after a panic
+	Stolen             int64   `json:"stolen,omitempty"`   // Candidates the worker stole from another's deque, with the stealing pool
+}
+
+type scaleSummary struct {
+	AtSeconds float64 `json:"at_seconds"`
+	Workers   int     `json:"workers"`
+	Reason    string  `json:"reason,omitempty"` // Why the controller changed the worker count, empty for the starting count
+}
+
+// latencySummary is how long the results took from their candidate's generation to their emission, the percentiles estimated from a sample
+type latencySummary struct {
+	Results    int64   `json:"results"`
+	P50Seconds float64 `json:"p50_seconds"`
+	P95Seconds float64 `json:"p95_seconds"`
+	P99Seconds float64 `json:"p99_seconds"`
+	MaxSeconds float64 `json:"max_seconds"`
+}
+
+// cacheSummary is what the workers' cache of test results saved them, counting only the candidates trial division left to the test
+type cacheSummary struct {
+	Hits      int64   `json:"hits"`   // Tests skipped, the candidate's result was cached
+	Misses    int64   `json:"misses"` // Candidates tested, then cached
+	HitRate   float64 `json:"hit_rate"`
+	Entries   int     `json:"entries"`   // Candidates cached once the run was over
+	Evictions int64   `json:"evictions"` // Candidates dropped from the full cache for others
+}
+
+// newLatencySummary returns the latencies observed, or nil if there were none
+func newLatencySummary(latency *pipeline.Latencies) *latencySummary {
+	count := latency.Count()
+	if count == 0 {
+		return nil
+	}
+	quantiles := latency.Quantiles(latencyQuantiles...)
+	return &latencySummary{
+		Results:    count,
+		P50Seconds: quantiles[0].Seconds(),
+		P95Seconds: quantiles[1].Seconds(),
+		P99Seconds: quantiles[2].Seconds(),
+		MaxSeconds: latency.Max().Seconds(),
+	}
+}
+
+// newSummary collects the summary of a run from its report
+func newSummary(cfg config, rep *report, found int, interrupted, timedOut bool, duration time.Duration) summary {
+	sum := summary{
+		Requested:       cfg.numPrimes,
+		Found:           found,
+		Interrupted:     interrupted,
+		TimedOut:        timedOut,
+		Capped:          rep.capped.Load() && (cfg.numPrimes == 0 || found < cfg.numPrimes),
+		Strategy:        cfg.strategy,
+		Generated:       rep.generated.Load(),
+		Tested:          rep.tested(),
+		Duplicates:      rep.discarded.Load(),
+		Prefiltered:     rep.wheeled.Load(),
+		Duration:        duration,
+		DurationSeconds: duration.Seconds(),
+	}
+	if duration > 0 {
+		sum.TestedPerSecond = float64(sum.Tested) / duration.Seconds()
+		sum.FoundPerSecond = float64(found) / duration.Seconds()
+		sum.Speedup = rep.testTime().Seconds() / duration.Seconds()
+	}
+	sum.Workers = workerSummaries(rep)
+	sum.Latency = newLatencySummary(&rep.latency)
+	if cache := rep.resultCache(); cache != nil {
+		sum.Cache = &cacheSummary{Hits: cache.Hits.Load(), Misses: cache.Misses.Load(), Evictions: cache.Evictions.Load(), Entries: cache.Len()}
+		if lookups := sum.Cache.Hits + sum.Cache.Misses; lookups > 0 {
+			sum.Cache.HitRate = float64(sum.Cache.Hits) / float64(lookups)
+		}
+	}
+	if pool := rep.autoscaled(); pool != nil {
+		for _, event := range pool.History() {
+			sum.Scaling = append(sum.Scaling, scaleSummary{AtSeconds: event.At.Seconds(), Workers: event.Workers, Reason: event.Reason})
+		}
+	}
+	if parts := rep.partitioned(); parts != nil {
+		sum.Partitions = parts.summary()
+	}
+	return sum
+}
+
+// workerSummaries returns the counters of every worker in the report, in the order they were started
+func workerSummaries(rep *report) []workerSummary {
+	var workers []workerSummary
+	for i, stats := range rep.workerStats() {
+		workers = append(workers, workerSummary{
+			Worker:             i,
+			Tested:             stats.Tested.Load(),
+			Found:              stats.Found.Load(),
+			TestSeconds:        stats.TestDuration().Seconds(),
+			SendBlockedSeconds: stats.SendBlockedTime().Seconds(),
+			Restarts:           stats.Restarts.Load(),
+			Stolen:             stats.Stolen.Load(),
+		})
+	}
+	return workers
+}
+
+// newOutput returns the writer for the given output format
+func newOutput(format string, w io.Writer) (output, error) {
+	switch format {
+	case OUTPUT_TEXT:
+		return &textOutput{w: w}, nil
+	case OUTPUT_JSON:
+		return &jsonOutput{enc: json.NewEncoder(w)}, nil
+	case OUTPUT_JSONL:
+		return &jsonlOutput{enc: json.NewEncoder(w)}, nil
+	case OUTPUT_PROTO:
+		return &protoOutput{w: w}, nil
+	case OUTPUT_MSGPACK:
+		return newMsgpackOutput(w), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// usedFlags returns the value of every flag, as given on the command line or defaulted
+func usedFlags() map[string]string {
+	flags := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	return flags
+}
+
+// textOutput prints a run as human readable lines
+type textOutput struct {
+	w    io.Writer
+	noun string // What the numbers found are, as named by the predicate and mode flags
+}
+
+func (o *textOutput) start(cfg config) {
+	noun := searchNoun(cfg)
+	goal := fmt.Sprintf("%d %s", cfg.numPrimes, noun)
+	if cfg.duration > 0 {
+		goal = fmt.Sprintf("%s for %v", noun, cfg.duration)
+	}
+	low, high := rangeBounds(cfg)
+	switch {
+	case cfg.source == SOURCE_FILE && cfg.inputPath == STDIN_INPUT:
+		fmt.Fprintf(o.w, "Generating %s from the numbers on stdin...\n", goal)
+	case cfg.source == SOURCE_FILE:
+		fmt.Fprintf(o.w, "Generating %s from the numbers in %s...\n", goal, cfg.inputPath)
+	case cfg.source == SOURCE_KAFKA:
+		fmt.Fprintf(o.w, "Generating %s from the numbers on Kafka topic %s...\n", goal, cfg.kafkaTopic)
+	case !rangeSource(cfg):
+		fmt.Fprintf(o.w, "Generating %s from the numbers of the %s source...\n", goal, cfg.source)
+	case cfg.search == SEARCH_MERSENNE:
+		fmt.Fprintf(o.w, "Generating %s from exponents within range %v-%v from a %s source...\n", goal, low, high, cfg.source)
+	default:
+		fmt.Fprintf(o.w, "Generating %s within range %v-%v from a %s source...\n", goal, low, high, cfg.source)
+	}
+	fmt.Fprintf(o.w, "Creating %d workers...\n", cfg.numWorkers)
+	fmt.Fprintf(o.w, "%s generated:\n", strings.ToUpper(noun[:1])+noun[1:])
+	o.noun = noun
+}
+
+func (o *textOutput) prime(found pipeline.Found[result]) {
+	fmt.Fprintln(o.w, found.Value)
+}
+
+func (o *textOutput) finish(sum summary) error {
+	// A continuous run has no number of primes requested
+	progress := fmt.Sprintf("found %d of %d %s", sum.Found, sum.Requested, o.noun)
+	if sum.Requested == 0 {
+		progress = fmt.Sprintf("found %d %s", sum.Found, o.noun)
+	}
+	switch {
+	case sum.Interrupted:
+		fmt.Fprintln(o.w, "Run interrupted")
+	case sum.TimedOut:
+		fmt.Fprintln(o.w, "Run timed out")
+	case sum.Capped:
+		fmt.Fprintln(o.w, "Run stopped at the candidate cap")
+	}
+	fmt.Fprintf(o.w, "Strategy: %s\n", sum.Strategy)
+	// The sieve tests the whole range without generating candidates
+	if sum.Generated > 0 {
+		fmt.Fprintf(o.w, "Candidates generated: %d\n", sum.Generated)
+	}
+	fmt.Fprintf(o.w, "Numbers tested: %d\n", sum.Tested)
+	if sum.Prefiltered > 0 {
+		fmt.Fprintf(o.w, "Candidates pre-filtered: %d (multiples of 2, 3, 5 or 7)\n", sum.Prefiltered)
+	}
+	fmt.Fprintf(o.w, "%s\n", strings.ToUpper(progress[:1])+progress[1:])
+	fmt.Fprintf(o.w, "Duplicates discarded: %d\n", sum.Duplicates)
+	fmt.Fprintf(o.w, "Throughput: %.0f numbers tested/s, %.1f %s found/s\n", sum.TestedPerSecond, sum.FoundPerSecond, o.noun)
+	if sum.Cache != nil {
+		fmt.Fprintf(o.w, "Result cache: %d hits, %d misses (%.1f%% hit rate), %d cached, %d evicted\n", sum.Cache.Hits, sum.Cache.Misses, sum.Cache.HitRate*100, sum.Cache.Entries, sum.Cache.Evictions)
+	}
+	if sum.Speedup > 0 {
+		fmt.Fprintf(o.w, "Parallel speedup: %.1fx (time the workers spent testing over the duration)\n", sum.Speedup)
+	}
+	printWorkerStats(o.w, sum.Workers)
+	var restarts int64
+	for _, worker := range sum.Workers {
+		restarts += worker.Restarts
+	}
+	if restarts > 0 {
+		fmt.Fprintf(o.w, "Worker restarts after a panic: %d\n", restarts)
+	}
+	if len(sum.Scaling) > 0 {
+		fmt.Fprintf(o.w, "Worker count trajectory: %s\n", formatScaling(sum.Scaling))
+	}
+	if parts := sum.Partitions; parts != nil {
+		if parts.Sequential {
+			fmt.Fprintf(o.w, "Partitions: %d of %d complete, %d moved between workers\n", parts.Complete, parts.Partitions, parts.Moves)
+		} else {
+			fmt.Fprintf(o.w, "Partitions: %d, %d moved between workers\n", parts.Partitions, parts.Moves)
+		}
+	}
+	if gaps := sum.Gaps; gaps != nil {
+		fmt.Fprintf(o.w, "Gaps: %d, min %d, mean %.2f, max %d (after %d)\n", gaps.Gaps, gaps.Min, gaps.Mean, gaps.Max, gaps.MaxAfter)
+	}
+	if latency := sum.Latency; latency != nil {
+		fmt.Fprintf(o.w, "Latency: p50 %s, p95 %s, p99 %s, max %s (generation to emission, %d results)\n", roundSeconds(latency.P50Seconds), roundSeconds(latency.P95Seconds), roundSeconds(latency.P99Seconds), roundSeconds(latency.MaxSeconds), latency.Results)
+	}
+	if len(sum.Histogram) > 0 {
+		printHistogram(o.w, sum.Histogram)
+	}
+	_, err := fmt.Fprintf(o.w, "Duration: %v\n", sum.Duration)
+	return err
+}
+
+// printWorkerStats writes a table of each worker's counters, showing whether the fan-out kept the workers evenly busy.
+// A column of the candidates each worker stole is added when any were, with the stealing pool
+func printWorkerStats(w io.Writer, workers []workerSummary) {
+	stealing := slices.ContainsFunc(workers, func(worker workerSummary) bool { return worker.Stolen > 0 })
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "Worker\tTested\tFound\tTest time\tSend blocked\t"
+	if stealing {
+		header += "Stolen\t"
+	}
+	fmt.Fprintln(tw, header)
+	for _, worker := range workers {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%v\t%v\t", worker.Worker, worker.Tested, worker.Found,
+			roundSeconds(worker.TestSeconds), roundSeconds(worker.SendBlockedSeconds))
+		if stealing {
+			fmt.Fprintf(tw, "%d\t", worker.Stolen)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// formatScaling describes the changes to the worker count of an autoscaled run, such as "8 (0s) -> 9 (500ms, busy)"
+func formatScaling(scaling []scaleSummary) string {
+	steps := make([]string, len(scaling))
+	for i, event := range scaling {
+		steps[i] = fmt.Sprintf("%d (%v)", event.Workers, roundSeconds(event.AtSeconds))
+		if event.Reason != "" {
+			steps[i] = fmt.Sprintf("%d (%v, %s)", event.Workers, roundSeconds(event.AtSeconds), event.Reason)
+		}
+	}
+	return strings.Join(steps, " -> ")
+}
+
+// roundSeconds converts seconds to a duration rounded to the microsecond, for printing
+func roundSeconds(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Microsecond)
+}
+
+// jsonOutput collects a run into a single JSON document, written once the run is finished
+type jsonOutput struct {
+	enc *json.Encoder
+	doc jsonDocument
+}
+
+type jsonDocument struct {
+	Flags  map[string]string `json:"flags"`
+	Primes []result          `json:"primes"`
+	summary
+}
+
+func (o *jsonOutput) start(cfg config) {
+	o.doc.Flags = usedFlags()
+	o.doc.Primes = []result{}
+}
+
+func (o *jsonOutput) prime(found pipeline.Found[result]) {
+	o.doc.Primes = append(o.doc.Primes, found.Value)
+}
+
+func (o *jsonOutput) finish(sum summary) error {
+	o.doc.summary = sum
+	return o.enc.Encode(o.doc)
+}
+
+// jsonlOutput streams a run as one JSON object per line: the flags, then each prime as it is found, then the summary
+type jsonlOutput struct {
+	enc *json.Encoder
+}
+
+func (o *jsonlOutput) start(cfg config) {
+	o.enc.Encode(struct {
+		Type  string            `json:"type"`
+		Flags map[string]string `json:"flags"`
+	}{"start", usedFlags()})
+}
+
+func (o *jsonlOutput) prime(found pipeline.Found[result]) {
+	o.enc.Encode(struct {
+		Type    string    `json:"type"`
+		Prime   result    `json:"prime"`
+		Worker  int       `json:"worker"`
+		FoundAt time.Time `json:"found_at"`
+	}{"prime", found.Value, found.Worker, found.At})
+}
+
+func (o *jsonlOutput) finish(sum summary) error {
+	return o.enc.Encode(struct {
+		Type string `json:"type"`
+		summary
+	}{"summary", sum})
+}
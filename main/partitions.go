@@ -0,0 +1,122 @@
+package main
+
+import "sync"
+
+const (
+	DEFAULT_PARTITIONS = 64
+	RING_REPLICAS      = 64 // Points each gRPC worker takes on the ring the partitions are assigned with
+)
+
+// partitionSummary describes the partitions of a gRPC coordinator's run
+type partitionSummary struct {
+	Partitions int               `json:"partitions"`
+	Sequential bool              `json:"sequential"` // The candidates were the range walked in order, so the partitions' completion is tracked
+	Complete   int               `json:"complete"`   // Partitions whose every number was tested, only counted for the sequential source
+	Covered    bool              `json:"covered"`    // Every partition is complete, so every number of the range was tested
+	Moves      int64             `json:"moves"`      // Times a partition was given to another worker as workers registered and left
+	Detail     []partitionStatus `json:"detail"`
+}
+
+type partitionStatus struct {
+	Partition int    `json:"partition"`
+	Owner     string `json:"owner,omitempty"`    // Name of the worker that owned it last, empty if no worker ever registered
+	Tested    int64  `json:"tested"`             // Candidates of the partition tested, or rejected by the wheel pre-filter
+	Expected  int64  `json:"expected,omitempty"` // Numbers of the range in the partition, for the sequential source
+	Complete  bool   `json:"complete,omitempty"`
+}
+
+// partitionTracker splits the candidates of a run into a fixed number of partitions and counts the candidates of each that have been tested.
+// The range is cut into blocks of size numbers from its lower bound, dealt to the partitions in turn: block k goes to partition k mod the count.
+// Each partition owns blocks all over the range, so the sequential source, walking it in order, keeps every partition's owner busy at once,
+// and the numbers of the range in each partition are known up front, which is how a partition is proven complete
+type partitionTracker struct {
+	from, to   int64 // The range, to excluded
+	size       int64
+	sequential bool // The candidates are the range walked in order, each number once
+
+	mu     sync.Mutex
+	tested []int64
+	owners []string
+	moves  int64
+}
+
+func newPartitionTracker(cfg config, count, size int) *partitionTracker {
+	return &partitionTracker{
+		from:       cfg.from,
+		to:         cfg.numRange,
+		size:       int64(max(size, 1)),
+		sequential: cfg.source == SOURCE_SEQUENTIAL,
+		tested:     make([]int64, count),
+		owners:     make([]string, count),
+	}
+}
+
+// count returns the number of partitions
+func (t *partitionTracker) count() int {
+	return len(t.tested)
+}
+
+// partition returns the partition a candidate belongs to. A candidate below the range, which only a source other than the sequential one
+// can produce, still belongs to one
+func (t *partitionTracker) partition(num int64) int {
+	block := uint64(num-t.from) / uint64(t.size)
+	return int(block % uint64(len(t.tested)))
+}
+
+// expected returns the numbers of the range in partition p: the full blocks dealt to it, and the last block if it's short and falls to p
+func (t *partitionTracker) expected(p int) int64 {
+	count := int64(len(t.tested))
+	length := max(t.to-t.from, 0)
+	blocks, rest := length/t.size, length%t.size
+	expected := blocks / count * t.size
+	if int64(p) < blocks%count {
+		expected += t.size
+	}
+	if rest > 0 && blocks%count == int64(p) {
+		expected += rest
+	}
+	return expected
+}
+
+// record counts n candidates of partition p as tested
+func (t *partitionTracker) record(p int, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tested[p] += n
+}
+
+// assign records the owner of each partition after a worker registered or left, counting the partitions moved to another worker.
+// An empty owner, with no worker registered, leaves the partition with its last one
+func (t *partitionTracker) assign(owners []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for p, owner := range owners {
+		if owner == "" || owner == t.owners[p] {
+			continue
+		}
+		if t.owners[p] != "" {
+			t.moves++
+		}
+		t.owners[p] = owner
+	}
+}
+
+// summary returns the state of every partition
+func (t *partitionTracker) summary() *partitionSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sum := &partitionSummary{Partitions: len(t.tested), Sequential: t.sequential, Moves: t.moves, Detail: make([]partitionStatus, len(t.tested))}
+	for p, tested := range t.tested {
+		status := partitionStatus{Partition: p, Owner: t.owners[p], Tested: tested}
+		if t.sequential {
+			status.Expected = t.expected(p)
+			status.Complete = tested == status.Expected
+			if status.Complete {
+				sum.Complete++
+			}
+		}
+		sum.Detail[p] = status
+	}
+	sum.Covered = t.sequential && sum.Complete == sum.Partitions
+	return sum
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// PAUSE_HINT tells how to resume a paused run, never shown as nothing pauses one here
+const PAUSE_HINT = ""
+
+// pauseOnSignal does nothing where there's no SIGTSTP, a run can't be paused
+func pauseOnSignal(ctx context.Context, gate *pipeline.Gate) {}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// PAUSE_HINT tells how to resume a paused run, in the log and on the dashboard
+const PAUSE_HINT = "press Ctrl-Z again or send SIGCONT to resume"
+
+// pauseOnSignal pauses and resumes the run's candidate generation on SIGTSTP (Ctrl-Z), which no longer suspends the process, until ctx is done.
+// The workers finish the candidates in flight and then wait. SIGCONT also resumes it
+func pauseOnSignal(ctx context.Context, gate *pipeline.Gate) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTSTP, syscall.SIGCONT)
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigs:
+				switch {
+				case sig == syscall.SIGTSTP && gate.Pause():
+					slog.Info("paused candidate generation, " + PAUSE_HINT)
+				case gate.Resume():
+					slog.Info("resumed candidate generation")
+				}
+			}
+		}
+	}()
+}
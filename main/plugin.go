@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"plugin"
+	"slices"
+	"strings"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// builtinSources are the sources the source flag selects without a plugin, which a plugin can't register
+var builtinSources = []string{SOURCE_RANDOM, SOURCE_SEQUENTIAL, SOURCE_CRYPTO, SOURCE_FILE, SOURCE_KAFKA}
+
+// loadSourcePlugins opens the comma separated Go plugins in paths, whose init functions register their sources with pipeline.RegisterSource.
+// A plugin has to be built with the same Go version and the same versions of the packages it shares with the program, pipeline among them,
+// otherwise it fails to open. Plugins are only supported on Linux, FreeBSD and macOS
+func loadSourcePlugins(paths string) error {
+	if paths == "" {
+		return nil
+	}
+	for path := range strings.SplitSeq(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("opening plugin: %w", err)
+		}
+		slog.Debug("loaded source plugin", "path", path)
+	}
+	for _, name := range pipeline.Sources() {
+		if slices.Contains(builtinSources, name) {
+			return fmt.Errorf("a plugin registered the %s source, which is built in", name)
+		}
+	}
+	slog.Debug("registered sources", "sources", pipeline.Sources())
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	DEFAULT_PG_TABLE  = "primes"
+	DEFAULT_PG_BATCH  = 500
+	PG_FLUSH_INTERVAL = time.Second      // Longest a partial batch waits before it's inserted, so a long run's table keeps up with it
+	PG_TIMEOUT        = 10 * time.Second // Bounds connecting and each batch's insert
+)
+
+// On-conflict flag values, deciding what inserting a number the table has already does
+const (
+	PG_CONFLICT_IGNORE = "ignore" // Keep the row already in the table
+	PG_CONFLICT_UPDATE = "update" // Replace the row with the new one
+	PG_CONFLICT_ERROR  = "error"  // Fail the batch, and with it the run
+)
+
+// postgresOutput inserts each result into the pg-table flag's table of the PostgreSQL database at the pg-dsn flag, so long runs build a durable dataset.
+// The table is created if it doesn't exist, with a row per number: its value (the exponent in mersenne mode), twin and factors (NULL in the other modes),
+// the worker that found it, when and after how many attempts. The results are grouped by pipeline.Batch into batches of the pg-batch flag's size,
+// each inserted in one round trip and one transaction, and a partial batch is inserted after PG_FLUSH_INTERVAL.
+// The first batch that fails to insert fails the run once it's finished, the results after it aren't inserted
+type postgresOutput struct {
+	ctx     context.Context
+	conn    *pgx.Conn
+	insert  string
+	results chan pipeline.Found[result]
+	done    chan struct{}
+	err     error // First failed insert, read once done is closed
+	written int64
+}
+
+// newPostgresOutput connects to the database and creates the table if it doesn't exist
+func newPostgresOutput(ctx context.Context, cfg config) (*postgresOutput, error) {
+	connectCtx, cancel := context.WithTimeout(ctx, PG_TIMEOUT)
+	defer cancel()
+	conn, err := pgx.Connect(connectCtx, cfg.pgDSN)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to PostgreSQL: %w", err)
+	}
+	// The table can be qualified by its schema, such as results.primes
+	table := pgx.Identifier(strings.Split(cfg.pgTable, ".")).Sanitize()
+	_, err = conn.Exec(connectCtx, `CREATE TABLE IF NOT EXISTS `+table+` (
+	value bigint PRIMARY KEY,
+	twin bigint,
+	factors bigint[],
+	worker integer NOT NULL,
+	found_at timestamptz NOT NULL,
+	attempts bigint NOT NULL
+)`)
+	if err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("creating table %s: %w", table, err)
+	}
+	insert := `INSERT INTO ` + table + ` (value, twin, factors, worker, found_at, attempts) VALUES ($1, $2, $3, $4, $5, $6)`
+	switch cfg.pgOnConflict {
+	case PG_CONFLICT_IGNORE:
+		insert += ` ON CONFLICT (value) DO NOTHING`
+	case PG_CONFLICT_UPDATE:
+		insert += ` ON CONFLICT (value) DO UPDATE SET twin = EXCLUDED.twin, factors = EXCLUDED.factors, worker = EXCLUDED.worker, found_at = EXCLUDED.found_at, attempts = EXCLUDED.attempts`
+	}
+	o := &postgresOutput{
+		ctx:     ctx,
+		conn:    conn,
+		insert:  insert,
+		results: make(chan pipeline.Found[result], cfg.buffer),
+		done:    make(chan struct{}),
+	}
+	// The batches outlive the run's context, so the results found before an interrupt are inserted too
+	go o.insertBatches(pipeline.Batch(context.Background(), o.results, cfg.pgBatch, PG_FLUSH_INTERVAL))
+	return o, nil
+}
+
+// insertBatches inserts each batch until the first that fails, then drains the rest
+func (o *postgresOutput) insertBatches(batches <-chan []pipeline.Found[result]) {
+	defer close(o.done)
+	for batch := range batches {
+		if o.err != nil {
+			continue
+		}
+		if err := o.insertBatch(batch); err != nil {
+			o.err = err
+			slog.Error("inserting primes into PostgreSQL, dropping the rest of the run's", "err", err)
+		}
+	}
+}
+
+// insertBatch sends the inserts of a batch in one round trip. pgx runs them in an implicit transaction, so a batch is inserted whole or not at all
+func (o *postgresOutput) insertBatch(batch []pipeline.Found[result]) error {
+	ctx, cancel := context.WithTimeout(context.Background(), PG_TIMEOUT)
+	defer cancel()
+	b := &pgx.Batch{}
+	for _, found := range batch {
+		var twin *int64
+		if found.Value.Twin != 0 {
+			twin = &found.Value.Twin
+		}
+		b.Queue(o.insert, found.Value.Value, twin, found.Value.Factors, found.Worker, found.At, found.Attempts)
+	}
+	if err := o.conn.SendBatch(ctx, b).Close(); err != nil {
+		return fmt.Errorf("inserting a batch of %d: %w", len(batch), err)
+	}
+	o.written += int64(len(batch))
+	return nil
+}
+
+func (o *postgresOutput) start(cfg config) {}
+
+func (o *postgresOutput) prime(found pipeline.Found[result]) {
+	select {
+	case <-o.ctx.Done():
+	case o.results <- found:
+	}
+}
+
+// finish inserts the last batch and closes the connection, returning the error of the batch that failed, if one did
+func (o *postgresOutput) finish(sum summary) error {
+	close(o.results)
+	<-o.done
+	o.conn.Close(context.Background())
+	if o.err != nil {
+		return fmt.Errorf("writing to PostgreSQL: %w", o.err)
+	}
+	slog.Debug("inserted primes into PostgreSQL", "rows", o.written)
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// Predicates, selected with the -predicate flag. They decide which candidates the workers keep
+const (
+	PREDICATE_PRIME      = "prime"          // Prime numbers, with the test selected by the certainty flags
+	PREDICATE_SQUARE     = "perfect-square" // Squares of an integer
+	PREDICATE_PALINDROME = "palindrome"     // Numbers that read the same backwards
+)
+
+// Searches, selected with the -mode flag. They decide what a result is, where the modes selected with the first argument decide where the pipeline runs
+const (
+	SEARCH_PRIMES   = "primes"   // Numbers matching the predicate flag, prime numbers by default
+	SEARCH_TWIN     = "twin"     // Twin primes, pairs of primes p and p+2, tested by the workers from p
+	SEARCH_FACTOR   = "factor"   // Prime factorizations of every candidate, found by the workers with trial division and Pollard's rho
+	SEARCH_MERSENNE = "mersenne" // Mersenne primes 2^p-1, with the candidates as exponents p tested by the workers with Lucas-Lehmer
+)
+
+// predicates holds the tests of the predicates other than prime, which depends on more flags than its name
+var predicates = map[string]pipeline.PrimalityTest{
+	PREDICATE_SQUARE:     pipeline.PerfectSquare,
+	PREDICATE_PALINDROME: pipeline.Palindrome,
+}
+
+// predicateNouns names the numbers each predicate finds, for the text output
+var predicateNouns = map[string]string{
+	PREDICATE_PRIME:      "prime numbers",
+	PREDICATE_SQUARE:     "perfect squares",
+	PREDICATE_PALINDROME: "palindromes",
+}
+
+// checkSearch returns an error if the predicate or mode flag doesn't name one, or they can't be combined (with each other or with a filter module)
+func checkSearch(cfg config) error {
+	if _, ok := predicateNouns[cfg.predicate]; !ok {
+		return fmt.Errorf("unknown predicate %q", cfg.predicate)
+	}
+	switch {
+	case cfg.filterWasm != "" && cfg.predicate != PREDICATE_PRIME:
+		return fmt.Errorf("a filter module replaces the predicate's test, it can't be combined with the %s predicate", cfg.predicate)
+	case cfg.filterWasm != "" && cfg.search != SEARCH_PRIMES:
+		return fmt.Errorf("a filter module replaces the predicate's test, it can't be combined with the %s mode", cfg.search)
+	}
+	switch cfg.search {
+	case SEARCH_PRIMES:
+	case SEARCH_TWIN, SEARCH_FACTOR, SEARCH_MERSENNE:
+		if cfg.predicate != PREDICATE_PRIME {
+			return fmt.Errorf("the %s mode finds primes, it can't be combined with the %s predicate", cfg.search, cfg.predicate)
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", cfg.search)
+	}
+	return nil
+}
+
+// searchNoun names the results of a run, for the text output
+func searchNoun(cfg config) string {
+	switch cfg.search {
+	case SEARCH_TWIN:
+		return "twin prime pairs"
+	case SEARCH_FACTOR:
+		return "factorizations"
+	case SEARCH_MERSENNE:
+		return "Mersenne primes"
+	}
+	if cfg.filterWasm != "" {
+		return "accepted numbers"
+	}
+	return predicateNouns[cfg.predicate]
+}
+
+// cachedTest returns test answering from the cache flag's cache of results, which records its whole outcome (trial division included), or test as is without one
+func cachedTest(cfg config, test pipeline.PrimalityTest) pipeline.PrimalityTest {
+	if cfg.resultCache == nil {
+		return test
+	}
+	return cfg.resultCache.Test(test)
+}
+
+// candidateTest returns the test workers use to check numbers, as selected by the predicate and mode flags (and the certainty and trial division flags for primes) and the cache flag,
+// or the filter module's when there's one
+func candidateTest(cfg config) pipeline.PrimalityTest {
+	if cfg.wasmFilter != nil {
+		return cfg.wasmFilter.accept
+	}
+	if test, ok := predicates[cfg.predicate]; ok {
+		return cachedTest(cfg, test)
+	}
+	isPrime := pipeline.ProbablyPrime(cfg.certainty)
+	if cfg.deterministic {
+		isPrime = pipeline.DeterministicPrime
+	}
+	if cfg.trialDivision {
+		isPrime = pipeline.TrialDivision(isPrime)
+	}
+	isPrime = cachedTest(cfg, isPrime)
+	switch cfg.search {
+	case SEARCH_TWIN:
+		return pipeline.TwinPrime(isPrime)
+	case SEARCH_MERSENNE:
+		return pipeline.MersennePrime
+	}
+	return isPrime
+}
+
+// result is a number found by a run, in the shape of the mode flag's search: a twin prime pair also carries its upper prime,
+// a factorized number its prime factors, and a Mersenne prime is found by its exponent
+type result struct {
+	Value    int64    // Number found, the lower prime of a twin pair, the number factorized or the exponent of a Mersenne prime
+	Twin     int64    // Upper prime of a twin pair, 0 in the other modes
+	Factors  []int64  // Prime factors of the number in factor mode (empty for 0 and 1), nil in the other modes
+	Mersenne bool     // Whether Value is the exponent of a Mersenne prime
+	Big      *big.Int // Prime found in a range beyond int64 (Value is 0 then), nil otherwise
+}
+
+// newResult turns a number found by the workers into the result of the run's search
+func newResult(cfg config, found pipeline.Found[int64]) pipeline.Found[result] {
+	r := result{Value: found.Value}
+	switch cfg.search {
+	case SEARCH_TWIN:
+		r.Twin = found.Value + 2
+	case SEARCH_MERSENNE:
+		r.Mersenne = true
+	}
+	return pipeline.Found[result]{Value: r, Worker: found.Worker, At: found.At, Attempts: found.Attempts}
+}
+
+// String formats the result for the text and file outputs, such as "11", "11 13" for a twin pair, "12 = 2 x 2 x 3" for a factorization
+// or "2^13-1" for a Mersenne prime
+func (r result) String() string {
+	switch {
+	case r.Big != nil:
+		return r.Big.String()
+	case r.Mersenne:
+		return fmt.Sprintf("2^%d-1", r.Value)
+	case r.Twin != 0:
+		return fmt.Sprintf("%d %d", r.Value, r.Twin)
+	case len(r.Factors) > 0:
+		return fmt.Sprintf("%d = %s", r.Value, formatFactors(r.Factors, " x "))
+	}
+	// 0 and 1 have no prime factors, they're written as themselves
+	return strconv.FormatInt(r.Value, 10)
+}
+
+// MarshalJSON writes the result as a number, a twin pair as an array of its two primes, a factorization as an object with the number and its factors,
+// or a Mersenne prime as an object with its exponent (the number itself has thousands of digits for the larger ones)
+func (r result) MarshalJSON() ([]byte, error) {
+	switch {
+	case r.Big != nil:
+		return json.Marshal(r.Big) // A JSON number, which has no limit on its size (though many decoders read it as a float)
+	case r.Mersenne:
+		return json.Marshal(struct {
+			Exponent int64 `json:"exponent"`
+		}{r.Value})
+	case r.Twin != 0:
+		return json.Marshal([2]int64{r.Value, r.Twin})
+	case r.Factors != nil:
+		return json.Marshal(struct {
+			Num     int64   `json:"n"`
+			Factors []int64 `json:"factors"`
+		}{r.Value, r.Factors})
+	}
+	return json.Marshal(r.Value)
+}
+
+// compare orders results by their number, for the sort flag
+func (r result) compare(other result) int {
+	if r.Big != nil && other.Big != nil {
+		return r.Big.Cmp(other.Big)
+	}
+	return cmp.Compare(r.Value, other.Value)
+}
+
+// formatFactors joins prime factors with sep
+func formatFactors(factors []int64, sep string) string {
+	parts := make([]string, len(factors))
+	for i, factor := range factors {
+		parts[i] = strconv.FormatInt(factor, 10)
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// Sampling rates for the block and mutex profiles while the pprof server is running
+const (
+	PPROF_BLOCK_RATE     = 10000 // Sample one blocking event per 10µs spent blocked, see runtime.SetBlockProfileRate
+	PPROF_MUTEX_FRACTION = 100   // Sample 1 in 100 mutex contention events, see runtime.SetMutexProfileFraction
+)
+
+// servePprof starts an HTTP listener on addr serving net/http/pprof under /debug/pprof/.
+// Block and mutex profiling are switched on, the block profile shows where stages wait on channels (such as the fan-in in ReduceWorkers).
+// A listener that fails is reported on stderr without stopping the run
+func servePprof(addr string) {
+	runtime.SetBlockProfileRate(PPROF_BLOCK_RATE)
+	runtime.SetMutexProfileFraction(PPROF_MUTEX_FRACTION)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("pprof listener stopped", "addr", addr, "err", err)
+		}
+	}()
+}
+
+// profiles writes the CPU and heap profiles of a single run to files, for go tool pprof, without a pprof server
+type profiles struct {
+	cpu     *os.File // CPU profile being written, nil if disabled
+	memPath string   // Path the heap profile is written to when stopping, disabled if empty
+	stopped bool
+}
+
+// startProfiles starts writing a CPU profile to cpuPath, with the heap profile written to memPath once stopped. Either path may be empty to skip that profile
+func startProfiles(cpuPath, memPath string) (*profiles, error) {
+	p := &profiles{memPath: memPath}
+	if cpuPath == "" {
+		return p, nil
+	}
+	f, err := os.Create(cpuPath)
+	if err != nil {
+		return nil, fmt.Errorf("creating CPU profile: %w", err)
+	}
+	if err := rpprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("starting CPU profile: %w", err)
+	}
+	p.cpu = f
+	return p, nil
+}
+
+// stop stops the CPU profile and writes the heap profile. It's called once the pipeline has stopped, so the profiles cover the run and not the summary.
+// Calling it again does nothing, so it can also be deferred for the runs that fail
+func (p *profiles) stop() error {
+	if p.stopped {
+		return nil
+	}
+	p.stopped = true
+	var errs []error
+	if p.cpu != nil {
+		rpprof.StopCPUProfile()
+		if err := p.cpu.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("writing CPU profile: %w", err))
+		}
+	}
+	if p.memPath != "" {
+		errs = append(errs, writeHeapProfile(p.memPath))
+	}
+	return errors.Join(errs...)
+}
+
+// writeHeapProfile writes the heap profile to path, after a garbage collection so it's up to date with the memory still in use
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating heap profile: %w", err)
+	}
+	runtime.GC()
+	if err := rpprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing heap profile: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing heap profile: %w", err)
+	}
+	return nil
+}
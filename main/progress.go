@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// progressOutput logs a progress message every interval while the run goes: the primes found so far, the candidates tested, the current test rate
+// and an estimate of the time left to find all P primes, along with how far each Lucas-Lehmer test in flight got in mersenne mode.
+// Logs go to stderr, so they don't mix with the results on stdout
+type progressOutput struct {
+	rep      *report
+	interval time.Duration
+	mersenne *mersenneTests // Tests in flight in mersenne mode, nil otherwise
+	found    atomic.Int64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newProgressOutput(rep *report, interval time.Duration) *progressOutput {
+	return &progressOutput{rep: rep, interval: interval, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (o *progressOutput) start(cfg config) {
+	o.mersenne = cfg.mersenneTests
+	go o.logEvery(cfg.numPrimes, cfg.duration)
+}
+
+func (o *progressOutput) prime(found pipeline.Found[result]) {
+	o.found.Add(1)
+}
+
+func (o *progressOutput) finish(sum summary) error {
+	close(o.stop)
+	<-o.done
+	return nil
+}
+
+// logEvery logs the progress on every tick until finish is called. The ETA of a continuous run is the rest of its duration
+func (o *progressOutput) logEvery(numPrimes int, duration time.Duration) {
+	defer close(o.done)
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	start := time.Now()
+	lastTested, lastTick := int64(0), start
+	for {
+		var now time.Time
+		select {
+		case <-o.stop:
+			return
+		case now = <-ticker.C:
+		}
+		found, tested := o.found.Load(), o.rep.tested()
+		rate := float64(tested-lastTested) / now.Sub(lastTick).Seconds()
+		lastTested, lastTick = tested, now
+		left := eta(found, int64(numPrimes), now.Sub(start))
+		if duration > 0 {
+			left = max(duration-now.Sub(start), 0).Round(time.Second).String()
+		}
+		slog.Info("progress", "found", found, "requested", numPrimes, "tested", tested, "rate", math.Round(rate), "eta", left)
+		if o.mersenne != nil {
+			for _, test := range o.mersenne.status(now) {
+				slog.Info("testing exponent", "exponent", test.exponent, "steps", test.done, "of", test.total,
+					"percent", math.Round(float64(test.done)/float64(test.total)*100), "elapsed", test.elapsed.Round(time.Millisecond))
+			}
+		}
+	}
+}
+
+// eta estimates the time left to find all primes from the rate they have been found at so far.
+// Primes get rarer as numbers grow, but candidates are drawn from the whole range, so the rate stays about the same over a run (except for the sequential source)
+func eta(found, total int64, elapsed time.Duration) string {
+	if found == 0 {
+		return "unknown"
+	}
+	left := time.Duration(float64(elapsed) / float64(found) * float64(max(total-found, 0)))
+	return left.Round(time.Second).String()
+}
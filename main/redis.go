@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DEFAULT_REDIS_PREFIX = "primes"
+	DEFAULT_REDIS_CACHE  = 100000 // Candidates remembered locally as tested, saving a round trip when one is drawn again
+)
+
+// redisDedup shares the candidates tested and primes found between instances working the same range, in two Redis sets.
+// Adding a candidate to the tested set claims it, so only the instance that added it runs the primality test.
+// Adding a prime to the found set tells whether another instance reported it already
+type redisDedup struct {
+	client    *redis.Client
+	testedKey string
+	foundKey  string
+	cache     *seenCache
+}
+
+// newRedisDedup connects to the Redis server at addr, using the sets under the given key prefix
+func newRedisDedup(ctx context.Context, addr, prefix string, cacheSize int) (*redisDedup, error) {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis: %w", err)
+	}
+	return &redisDedup{
+		client:    client,
+		testedKey: prefix + ":tested",
+		foundKey:  prefix + ":found",
+		cache:     newSeenCache(cacheSize),
+	}, nil
+}
+
+// claim returns whether the candidate should be tested by this instance, false if any instance (this one included) has claimed it before.
+// A claimed candidate isn't tested again if the instance that claimed it is stopped before testing it
+func (d *redisDedup) claim(ctx context.Context, num int64) (bool, error) {
+	if d.cache.seen(num) {
+		return false, nil
+	}
+	added, err := d.client.SAdd(ctx, d.testedKey, strconv.FormatInt(num, 10)).Result()
+	if err != nil {
+		return false, fmt.Errorf("claiming candidate in Redis: %w", err)
+	}
+	d.cache.add(num)
+	return added == 1, nil
+}
+
+// report records a prime as found, returning false if another instance found it first
+func (d *redisDedup) report(ctx context.Context, num int64) (bool, error) {
+	added, err := d.client.SAdd(ctx, d.foundKey, strconv.FormatInt(num, 10)).Result()
+	if err != nil {
+		return false, fmt.Errorf("reporting prime to Redis: %w", err)
+	}
+	return added == 1, nil
+}
+
+// close closes the connection to Redis
+func (d *redisDedup) close() error {
+	return d.client.Close()
+}
+
+// seenCache remembers up to size numbers, forgetting the oldest once it is full (as the ring buffer of pipeline.Distinct does). It's safe for concurrent use
+type seenCache struct {
+	mu     sync.Mutex
+	seenAt map[int64]struct{}
+	window []int64
+	next   int
+	size   int
+}
+
+func newSeenCache(size int) *seenCache {
+	return &seenCache{seenAt: make(map[int64]struct{}), size: size}
+}
+
+// seen returns whether num is remembered
+func (c *seenCache) seen(num int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.seenAt[num]
+	return ok
+}
+
+// add remembers num
+func (c *seenCache) add(num int64) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seenAt[num]; ok {
+		return
+	}
+	c.seenAt[num] = struct{}{}
+	if len(c.window) < c.size {
+		c.window = append(c.window, num)
+		return
+	}
+	delete(c.seenAt, c.window[c.next])
+	c.window[c.next] = num
+	c.next = (c.next + 1) % c.size
+}
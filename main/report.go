@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// report collects what the pipeline did during a run, for the summary printed at the end and the metrics endpoint.
+// It is read while the pipeline is running, so it's safe for concurrent use
+type report struct {
+	generated atomic.Int64       // Candidates produced by the sources
+	capped    atomic.Bool        // Set once a source was stopped by the max-candidates flag
+	discarded atomic.Int64       // Results the dedup stages dropped as duplicates
+	wheeled   atomic.Int64       // Candidates the wheel pre-filter rejected before the workers
+	latency   pipeline.Latencies // From each result's generation to its emission, with the latency flag
+
+	mu      sync.Mutex
+	workers []*pipeline.Stats     // One per worker, in the order they were started
+	pool    *pipeline.Pool        // Set when the run was autoscaled, the pool keeps its own worker stats
+	parts   *partitionTracker     // Set when a gRPC coordinator partitions the run between its workers
+	cache   *pipeline.ResultCache // The workers' cache of test results, set when the run has one
+	stages  []stageFlow           // In the order the stages were started, when their hand-offs are timed (see stageOptions)
+
+	running sync.WaitGroup // Goroutines of the stages started with stageOptions
+
+	breaker *pipeline.Breaker // The webhook output's circuit breaker, nil without the webhook flag. Set before the run starts
+}
+
+// stageFlow is the time the stages of one kind (such as every worker) spent blocked on their channels
+type stageFlow struct {
+	name  string
+	stats *pipeline.FlowStats
+}
+
+// addWorker returns the index and counters for a newly started worker
+func (r *report) addWorker() (int, *pipeline.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := new(pipeline.Stats)
+	r.workers = append(r.workers, stats)
+	return len(r.workers) - 1, stats
+}
+
+// stageStats returns the flow stats of the stages with the given name, shared by every stage started with it
+func (r *report) stageStats(name string) *pipeline.FlowStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stage := range r.stages {
+		if stage.name == name {
+			return stage.stats
+		}
+	}
+	stats := new(pipeline.FlowStats)
+	r.stages = append(r.stages, stageFlow{name: name, stats: stats})
+	return stats
+}
+
+// stageFlows returns the flow stats of every stage started so far
+func (r *report) stageFlows() []stageFlow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]stageFlow(nil), r.stages...)
+}
+
+// setPool records the pool of an autoscaled run
+func (r *report) setPool(pool *pipeline.Pool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pool = pool
+}
+
+// autoscaled returns the pool of an autoscaled run, or nil
+func (r *report) autoscaled() *pipeline.Pool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pool
+}
+
+// setPartitions records the partitions of a gRPC coordinator's run
+func (r *report) setPartitions(parts *partitionTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parts = parts
+}
+
+// partitioned returns the partitions of a gRPC coordinator's run, or nil
+func (r *report) partitioned() *partitionTracker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.parts
+}
+
+// setCache records the workers' cache of test results
+func (r *report) setCache(cache *pipeline.ResultCache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = cache
+}
+
+// resultCache returns the workers' cache of test results, or nil
+func (r *report) resultCache() *pipeline.ResultCache {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cache
+}
+
+// workerStats returns the counters of every worker started so far
+func (r *report) workerStats() []*pipeline.Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := append([]*pipeline.Stats(nil), r.workers...)
+	if r.pool != nil {
+		stats = append(stats, r.pool.Stats()...)
+	}
+	return stats
+}
+
+// activeWorkers returns the number of workers currently started, which changes over an autoscaled run
+func (r *report) activeWorkers() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active := len(r.workers)
+	if r.pool != nil {
+		active += r.pool.Size()
+	}
+	return active
+}
+
+// tested returns the candidates checked by all workers
+func (r *report) tested() int64 {
+	var total int64
+	for _, stats := range r.workerStats() {
+		total += stats.Tested.Load()
+	}
+	return total
+}
+
+// testTime returns the time all workers spent testing candidates, more than the run took when they tested in parallel
+func (r *report) testTime() time.Duration {
+	var total time.Duration
+	for _, stats := range r.workerStats() {
+		total += stats.TestDuration()
+	}
+	return total
+}
+
+// countValues wraps a value getter so every value it produces is counted as generated in the report.
+// Once maxCandidates have been generated (when it's above 0) it returns ErrExhausted instead, which stops the sources as their end would
+func countValues[T any](r *report, maxCandidates int64, getValue func() (T, error)) func() (T, error) {
+	return func() (T, error) {
+		if !r.reserve(maxCandidates) {
+			var zero T
+			return zero, pipeline.ErrExhausted
+		}
+		val, err := getValue()
+		if err != nil {
+			r.generated.Add(-1)
+		}
+		return val, err
+	}
+}
+
+// reserve counts a candidate about to be generated, unless maxCandidates (when it's above 0) have been generated already.
+// The producers share the count, so reserving before generating keeps them from going over the cap between them.
+// A candidate that fails to be generated is taken back off the count
+func (r *report) reserve(maxCandidates int64) bool {
+	if r.generated.Add(1) <= maxCandidates || maxCandidates <= 0 {
+		return true
+	}
+	r.generated.Add(-1)
+	r.capped.Store(true)
+	return false
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	DEFAULT_S3_ENDPOINT = "s3.amazonaws.com"
+	S3_PART_SIZE        = 5 << 20 // The smallest part S3 takes, so at most this much of the stream is held in memory
+)
+
+// s3Output streams a run as the jsonl output does to an object of S3 or any S3 compatible store, given as an s3://bucket/key out flag.
+// The stream is uploaded in parts of S3_PART_SIZE as it's written (gzipped if the key ends in .gz), and the multipart upload is only completed
+// once the run has finished successfully: an interrupted or failed run aborts it, leaving an existing object at the key untouched.
+// The credentials are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables, ~/.aws/credentials or the instance's IAM role
+type s3Output struct {
+	jsonlOutput
+	ctx    context.Context
+	object string
+	pw     *io.PipeWriter
+	gz     *gzip.Writer // nil unless the key ends in .gz
+	stop   func() bool  // Stops aborting the upload when the context is cancelled
+	done   chan struct{}
+	err    error // Error of the upload, read once done is closed
+}
+
+// newS3Output starts the upload to the object named by the out flag, at the s3-endpoint flag's store
+func newS3Output(ctx context.Context, cfg config) (*s3Output, error) {
+	u, err := url.Parse(cfg.outPath)
+	if err != nil {
+		return nil, fmt.Errorf("out flag: %w", err)
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("out flag: %s doesn't name a bucket and a key, such as s3://bucket/primes.jsonl", cfg.outPath)
+	}
+	// Stores without TLS, such as a local MinIO, are given as http://host:port
+	endpoint, insecure := strings.CutPrefix(cfg.s3Endpoint, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewChainCredentials([]credentials.Provider{&credentials.EnvAWS{}, &credentials.FileAWSCredentials{}, &credentials.IAM{}}),
+		Secure: !insecure,
+		Region: cfg.s3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to S3: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	o := &s3Output{ctx: ctx, object: cfg.outPath, pw: pw, done: make(chan struct{})}
+	var w io.Writer = pw
+	opts := minio.PutObjectOptions{ContentType: "application/x-ndjson", PartSize: S3_PART_SIZE}
+	if strings.HasSuffix(key, ".gz") {
+		o.gz = gzip.NewWriter(pw)
+		w, opts.ContentEncoding = o.gz, "gzip"
+	}
+	o.jsonlOutput = jsonlOutput{enc: json.NewEncoder(w)}
+	// The upload runs on a context of its own, so a cancelled run can still abort it: breaking the pipe fails the upload, which then aborts it
+	o.stop = context.AfterFunc(ctx, func() {
+		pw.CloseWithError(context.Cause(ctx))
+	})
+	go func() {
+		defer close(o.done)
+		// A size of -1 streams the upload in parts until the pipe is closed
+		_, o.err = client.PutObject(context.Background(), bucket, key, pr, -1, opts)
+		// Unblocks the writes if the upload failed part way through
+		pr.CloseWithError(o.err)
+	}()
+	return o, nil
+}
+
+// finish writes the summary line and completes the upload, or aborts it if the run was interrupted
+func (o *s3Output) finish(sum summary) error {
+	// stop fails once the context is cancelled and the upload is being aborted
+	if !o.stop() {
+		<-o.done
+		return nil
+	}
+	err := o.jsonlOutput.finish(sum)
+	if o.gz != nil {
+		err = errors.Join(err, o.gz.Close())
+	}
+	if err != nil {
+		o.pw.CloseWithError(err)
+	} else {
+		o.pw.Close()
+	}
+	<-o.done
+	if o.err != nil {
+		return fmt.Errorf("uploading results to %s: %w", o.object, o.err)
+	}
+	return err
+}
+
+func (o *s3Output) prime(found pipeline.Found[result]) {
+	if o.ctx.Err() == nil {
+		o.jsonlOutput.prime(found)
+	}
+}
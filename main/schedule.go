@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduleRequest is the body of POST /schedules: a cron expression and the settings of the jobs it creates, as the body of POST /jobs
+type scheduleRequest struct {
+	Cron string `json:"cron"`
+	jobRequest
+	Enabled *bool `json:"enabled"` // true if left out
+}
+
+// storedSchedule is a schedule as it's saved in the store, keyed by its ID
+type storedSchedule struct {
+	ID   string `json:"id"`
+	Cron string `json:"cron"`
+	// The settings of its jobs, with the server's flags at the time the schedule was created filling in those the request left out
+	jobRequest
+	Enabled   bool       `json:"enabled"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastJob   string     `json:"last_job,omitempty"` // ID of the job it created last
+}
+
+// scheduleStatus is the body returned by the schedule endpoints
+type scheduleStatus struct {
+	storedSchedule
+	NextRun *time.Time `json:"next_run,omitempty"` // nil while the schedule is disabled
+}
+
+// schedule creates a job every time its cron expression comes up, while it's enabled. A run that comes up while the job the schedule created last
+// is still queued or running is skipped, so a job outlasting the schedule's interval doesn't pile up jobs behind it.
+// Its fields are guarded by the server's lock
+type schedule struct {
+	storedSchedule
+	spec  cron.Schedule
+	entry cron.EntryID // Its entry in the server's cron while it's enabled, 0 otherwise
+}
+
+// parseCron parses a schedule's cron expression: five fields (minute, hour, day of month, month and day of week),
+// optionally prefixed with CRON_TZ=<zone>, or a descriptor such as @hourly or @every 30m
+func parseCron(expr string) (cron.Schedule, error) {
+	spec, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return spec, nil
+}
+
+// scheduleStatus returns the schedule as the API reports it. The server's lock must be held
+func (s *jobServer) scheduleStatus(sc *schedule) scheduleStatus {
+	st := scheduleStatus{storedSchedule: sc.storedSchedule}
+	if sc.entry != 0 {
+		// An @every schedule's next run follows from its last one, which only the cron knows. It's unset until the cron has started
+		next := s.cron.Entry(sc.entry).Next
+		if next.IsZero() {
+			next = sc.spec.Next(time.Now())
+		}
+		st.NextRun = &next
+	}
+	return st
+}
+
+// setEnabled enables or disables the schedule, adding it to the server's cron or removing it. The server's lock must be held
+func (s *jobServer) setEnabled(sc *schedule, enabled bool) {
+	sc.Enabled = enabled
+	switch {
+	case enabled && sc.entry == 0:
+		sc.entry = s.cron.Schedule(sc.spec, cron.FuncJob(func() { s.fire(sc) }))
+	case !enabled && sc.entry != 0:
+		s.cron.Remove(sc.entry)
+		sc.entry = 0
+	}
+}
+
+// fire is called by the cron when the schedule comes up, creating its job unless the last one it created hasn't finished
+func (s *jobServer) fire(sc *schedule) {
+	s.mu.Lock()
+	if s.ctx.Err() != nil || s.schedules[sc.ID] != sc || !sc.Enabled {
+		s.mu.Unlock()
+		return
+	}
+	id, req, last := sc.ID, sc.jobRequest, s.jobs[sc.LastJob]
+	s.mu.Unlock()
+	if last != nil {
+		last.mu.Lock()
+		live := last.live()
+		last.mu.Unlock()
+		if live {
+			slog.Info("scheduled run skipped, the schedule's last job hasn't finished", "schedule", id, "job", last.id)
+			return
+		}
+	}
+	// The schedule's settings were checked when it was created, only a server started with other flags can reject them
+	cfg, err := s.jobConfig(req)
+	if err != nil {
+		slog.Error("scheduled run", "schedule", id, "err", err)
+		return
+	}
+	j := s.addJob(cfg, id)
+	slog.Info("scheduled job created", "schedule", id, "job", j.id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A schedule deleted meanwhile isn't saved again
+	if s.schedules[id] == sc {
+		now := time.Now()
+		sc.LastRun, sc.LastJob = &now, j.id
+		s.persistSchedule(sc)
+	}
+}
+
+// createSchedule handles POST /schedules, adding a schedule that creates a job with the request's settings every time its cron expression comes up.
+// The settings left out take the value of the server's flags, as they are when it's created
+func (s *jobServer) createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid schedule request: %v", err), http.StatusBadRequest)
+		return
+	}
+	spec, err := parseCron(req.Cron)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cfg, err := s.jobConfig(req.jobRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sc := &schedule{
+		storedSchedule: storedSchedule{
+			Cron:       req.Cron,
+			jobRequest: jobRequest{Primes: cfg.numPrimes, Range: cfg.numRange, Workers: cfg.numWorkers},
+			CreatedAt:  time.Now(),
+		},
+		spec: spec,
+	}
+	s.mu.Lock()
+	s.nextSchedule++
+	sc.ID = strconv.Itoa(s.nextSchedule)
+	s.schedules[sc.ID] = sc
+	s.setEnabled(sc, req.Enabled == nil || *req.Enabled)
+	s.persistSchedule(sc)
+	st := s.scheduleStatus(sc)
+	s.mu.Unlock()
+	slog.Info("schedule created", "schedule", sc.ID, "cron", sc.Cron, "enabled", st.Enabled)
+	w.Header().Set("Location", "/schedules/"+sc.ID)
+	writeJSON(w, http.StatusCreated, st)
+}
+
+// listSchedules handles GET /schedules, returning every schedule in the order they were created
+func (s *jobServer) listSchedules(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	statuses := make([]scheduleStatus, 0, len(s.schedules))
+	for id := 1; id <= s.nextSchedule; id++ {
+		if sc := s.schedules[strconv.Itoa(id)]; sc != nil {
+			statuses = append(statuses, s.scheduleStatus(sc))
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// getSchedule handles GET /schedules/{id}, returning the schedule with its next run
+func (s *jobServer) getSchedule(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sc := s.lookupSchedule(w, r)
+	if sc == nil {
+		s.mu.Unlock()
+		return
+	}
+	st := s.scheduleStatus(sc)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+// enableSchedule handles POST /schedules/{id}/enable, so the schedule creates its jobs again from its next run. Enabling an enabled schedule does nothing
+func (s *jobServer) enableSchedule(w http.ResponseWriter, r *http.Request) {
+	s.toggleSchedule(w, r, true)
+}
+
+// disableSchedule handles POST /schedules/{id}/disable, so the schedule stops creating jobs until it's enabled. The jobs it created are left running
+func (s *jobServer) disableSchedule(w http.ResponseWriter, r *http.Request) {
+	s.toggleSchedule(w, r, false)
+}
+
+// toggleSchedule enables or disables the schedule named in the request path, saves it and writes its status
+func (s *jobServer) toggleSchedule(w http.ResponseWriter, r *http.Request, enabled bool) {
+	s.mu.Lock()
+	sc := s.lookupSchedule(w, r)
+	if sc == nil {
+		s.mu.Unlock()
+		return
+	}
+	if sc.Enabled != enabled {
+		s.setEnabled(sc, enabled)
+		s.persistSchedule(sc)
+		slog.Info("schedule updated", "schedule", sc.ID, "enabled", enabled)
+	}
+	st := s.scheduleStatus(sc)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+// deleteSchedule handles DELETE /schedules/{id}, removing the schedule and returning it as it was. The jobs it created are kept
+func (s *jobServer) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sc := s.lookupSchedule(w, r)
+	if sc == nil {
+		s.mu.Unlock()
+		return
+	}
+	st := s.scheduleStatus(sc)
+	s.setEnabled(sc, false)
+	delete(s.schedules, sc.ID)
+	if s.store != nil {
+		if err := s.store.deleteSchedule(sc.ID); err != nil {
+			slog.Error("deleting schedule", "schedule", sc.ID, "err", err)
+		}
+	}
+	s.mu.Unlock()
+	slog.Info("schedule deleted", "schedule", sc.ID)
+	writeJSON(w, http.StatusOK, st)
+}
+
+// lookupSchedule returns the schedule named in the request path, writing a 404 and returning nil if there's no such schedule.
+// The server's lock must be held
+func (s *jobServer) lookupSchedule(w http.ResponseWriter, r *http.Request) *schedule {
+	sc := s.schedules[r.PathValue("id")]
+	if sc == nil {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+	}
+	return sc
+}
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/robfig/cron/v3"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	DEFAULT_SERVE_ADDR = ":8080"
+	MAX_JOB_WORKERS    = 1024 // Bounds the goroutines a single request can start
+	SHUTDOWN_TIMEOUT   = 5 * time.Second
+)
+
+// Job states, as reported by GET /jobs/{id}
+const (
+	JOB_QUEUED    = "queued" // Waiting for one of the running jobs to finish, when the server runs up to max-jobs at once
+	JOB_RUNNING   = "running"
+	JOB_PAUSED    = "paused" // Running with candidate generation paused by POST /jobs/{id}/pause, until POST /jobs/{id}/resume
+	JOB_DONE      = "done"
+	JOB_CANCELLED = "cancelled" // Cancelled with DELETE /jobs/{id} or by the server shutting down, the primes found so far are kept
+	JOB_FAILED    = "failed"
+)
+
+// jobRequest is the body of POST /jobs. Fields left out (or 0) take the value of the server's flags
+type jobRequest struct {
+	Primes  int   `json:"primes"`
+	Range   int64 `json:"range"`
+	Workers int   `json:"workers"`
+}
+
+// job is a run of the stream strategy started through the API
+type job struct {
+	id      string
+	cfg     config
+	cancel  context.CancelFunc
+	rep     report
+	done    chan struct{} // Closed once the job's pipeline has stopped, or once it's cancelled while queued
+	store   *jobStore     // Where the job is saved, nil if the server has no store
+	resumed *checkpoint   // Checkpoint the job was restored from when the server started, nil for a job created through the API
+	sched   string        // ID of the schedule that created the job, empty for a job created with POST /jobs
+
+	mu       sync.Mutex
+	state    string
+	found    []pipeline.Found[result]
+	updated  chan struct{} // Closed and replaced whenever a prime is found or the job finishes, waking up the streams following the job
+	err      error
+	queued   time.Time
+	started  time.Time // Zero while the job is queued
+	finished time.Time
+	saved    checkpoint // The job's last checkpoint, saved to the store along with its status
+}
+
+// jobSummary describes a job without its primes, as listed by GET /jobs
+type jobSummary struct {
+	ID              string          `json:"id"`
+	Status          string          `json:"status"`
+	Requested       int             `json:"requested"`
+	Found           int             `json:"found"`
+	Tested          int64           `json:"tested"`
+	Workers         []workerSummary `json:"workers"`
+	Error           string          `json:"error,omitempty"`
+	Schedule        string          `json:"schedule,omitempty"` // ID of the schedule that created the job
+	QueuedAt        time.Time       `json:"queued_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	DurationSeconds float64         `json:"duration_seconds"`
+}
+
+// jobStatus is the body returned by the job endpoints
+type jobStatus struct {
+	jobSummary
+	Primes []int64 `json:"primes"`
+}
+
+// status returns a snapshot of the job
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := jobStatus{jobSummary: j.snapshot(), Primes: make([]int64, len(j.found))}
+	for i, found := range j.found {
+		st.Primes[i] = found.Value.Value
+	}
+	return st
+}
+
+// summary returns a snapshot of the job without its primes
+func (j *job) summary() jobSummary {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.snapshot()
+}
+
+// snapshot returns the job's summary. The job's lock must be held
+func (j *job) snapshot() jobSummary {
+	sum := jobSummary{
+		ID:        j.id,
+		Status:    j.state,
+		Requested: j.cfg.numPrimes,
+		Schedule:  j.sched,
+		Found:     len(j.found),
+		Tested:    j.rep.tested(),
+		Workers:   workerSummaries(&j.rep),
+		QueuedAt:  j.queued,
+	}
+	if j.resumed != nil {
+		sum.Tested += j.resumed.Tested
+	}
+	if j.err != nil {
+		sum.Error = j.err.Error()
+	}
+	if j.state == JOB_RUNNING && j.cfg.gate.Paused() {
+		sum.Status = JOB_PAUSED
+	}
+	end := time.Now()
+	if !j.finished.IsZero() {
+		end = j.finished
+		sum.FinishedAt = &end
+	}
+	// A job cancelled while queued never started
+	if !j.started.IsZero() {
+		started := j.started
+		sum.StartedAt = &started
+		sum.DurationSeconds = end.Sub(j.started).Seconds()
+	}
+	return sum
+}
+
+// live returns whether the job is queued or running. The job's lock must be held
+func (j *job) live() bool {
+	return j.state == JOB_QUEUED || j.state == JOB_RUNNING
+}
+
+// run executes the job's pipeline until it finds every prime, fails or is cancelled, first waiting for one of slots if the job is queued.
+// The job's slot (if slots isn't nil) is released once the pipeline has stopped, starting the job queued after it.
+// A job stopped by the server shutting down is left in the store as it was, queued or running, so the next server resumes it
+func (j *job) run(ctx context.Context, slots *semaphore.Weighted) {
+	defer close(j.done)
+	j.mu.Lock()
+	queued := j.state == JOB_QUEUED
+	j.mu.Unlock()
+	if queued {
+		// The semaphore hands its slots out in the order they were asked for, so the jobs start in the order they were queued
+		if err := slots.Acquire(ctx, 1); err != nil {
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			defer j.notify()
+			j.state, j.finished = JOB_CANCELLED, time.Now()
+			slog.Info("job cancelled while queued", "job", j.id)
+			if !shutDown(ctx) {
+				j.persist()
+			}
+			return
+		}
+		j.mu.Lock()
+		j.state = JOB_RUNNING
+		if j.started.IsZero() {
+			j.started = time.Now()
+		}
+		j.notify()
+		j.persist()
+		j.mu.Unlock()
+		j.logStarted()
+	}
+	if slots != nil {
+		defer slots.Release(1)
+	}
+
+	cfg := j.cfg
+	var out output = jobOutput{j}
+	found := 0
+	if j.store != nil {
+		cfg.checkpoint = newCheckpointer(j.saveCheckpoint, cfg.checkpointEvery, cfg, &j.rep, j.resumed)
+		out = multiOutput{out, cfg.checkpoint}
+		cfg.checkpoint.start(cfg)
+		// The primes found before the server restarted are listed already, only the checkpoint needs them
+		for _, prime := range cfg.checkpoint.resumedPrimes() {
+			cfg.checkpoint.prime(newResult(cfg, prime))
+			found++
+		}
+	}
+	remaining := cfg
+	remaining.numPrimes -= found
+	var err error
+	if remaining.numPrimes > 0 {
+		_, err = runStream(ctx, remaining, &j.rep, out)
+	}
+	if cfg.checkpoint != nil {
+		if err := cfg.checkpoint.finish(summary{}); err != nil {
+			slog.Error("saving job", "job", j.id, "err", err)
+		}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	defer j.notify()
+	j.finished = time.Now()
+	switch {
+	case err != nil:
+		j.state, j.err = JOB_FAILED, err
+	case ctx.Err() != nil:
+		j.state = JOB_CANCELLED
+	default:
+		j.state = JOB_DONE
+	}
+	slog.Info("job finished", "job", j.id, "status", j.state, "found", len(j.found))
+	if j.state != JOB_CANCELLED || !shutDown(ctx) {
+		j.persist()
+	}
+}
+
+func (j *job) logStarted() {
+	slog.Info("job started", "job", j.id, "primes", j.cfg.numPrimes, "range", j.cfg.numRange, "workers", j.cfg.numWorkers)
+}
+
+// notify wakes up the streams waiting for the job to change. The job's lock must be held
+func (j *job) notify() {
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+// since returns the primes the job found after the first n, whether the job has finished, and a channel closed on the job's next change
+func (j *job) since(n int) ([]pipeline.Found[result], bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]pipeline.Found[result](nil), j.found[n:]...), !j.live(), j.updated
+}
+
+// jobOutput records the primes of a job as they are found
+type jobOutput struct {
+	j *job
+}
+
+func (o jobOutput) start(cfg config) {}
+
+func (o jobOutput) prime(found pipeline.Found[result]) {
+	o.j.mu.Lock()
+	defer o.j.mu.Unlock()
+	o.j.found = append(o.j.found, found)
+	o.j.notify()
+}
+
+func (o jobOutput) finish(sum summary) error {
+	return nil
+}
+
+// errJobCancelled is the cause of the context of a job cancelled with DELETE /jobs/{id}, telling it from one cancelled by the server shutting down
+var errJobCancelled = errors.New("job cancelled")
+
+// shutDown returns whether a job's context was cancelled by the server shutting down
+func shutDown(ctx context.Context) bool {
+	return ctx.Err() != nil && !errors.Is(context.Cause(ctx), errJobCancelled)
+}
+
+// jobServer runs the jobs started through the API. Jobs run on the server's context rather than the request's, so they outlive the POST that started them
+type jobServer struct {
+	ctx      context.Context
+	defaults config
+	slots    *semaphore.Weighted // Bounds the jobs running at once, the others wait for a slot queued. nil for no bound
+	store    *jobStore           // nil if the jobs aren't saved
+	cron     *cron.Cron          // Fires the enabled schedules
+
+	mu           sync.Mutex
+	jobs         map[string]*job
+	nextID       int
+	schedules    map[string]*schedule
+	nextSchedule int
+	wg           sync.WaitGroup // Running jobs, waited for on shutdown
+}
+
+// runServer serves the job API on addr, and the PrimeFinder gRPC service on grpcAddr if it's set, until SIGINT/SIGTERM.
+// Up to maxJobs jobs run at once (any number if it's 0), the jobs started past that are queued until one finishes.
+// With a storePath the jobs and schedules are saved to the store there, and those of the previous server are restored from it first (see jobStore).
+// It then stops firing the schedules, cancels the queued and running jobs and calls and shuts down
+func runServer(addr, grpcAddr string, maxJobs int, storePath string, defaults config) error {
+	// Jobs report the numbers they find as a list of int64s, which has no room for a twin pair or a big number
+	if defaults.search != SEARCH_PRIMES {
+		return fmt.Errorf("the job API can't run jobs in the %s mode", defaults.search)
+	}
+	if bigRange(defaults) {
+		return fmt.Errorf("the job API can't run jobs in a range beyond int64")
+	}
+	if maxJobs < 0 {
+		return fmt.Errorf("max-jobs must not be negative, got %d", maxJobs)
+	}
+	if storePath != "" {
+		// A job is resumed from its checkpoint, so it must be one that can be checkpointed
+		cfg := defaults
+		cfg.strategy = STRATEGY_STREAM
+		if err := checkCheckpointing(cfg); err != nil {
+			return fmt.Errorf("the jobs can't be stored: %w", err)
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := &jobServer{ctx: ctx, defaults: defaults, cron: cron.New(), jobs: make(map[string]*job), schedules: make(map[string]*schedule)}
+	if maxJobs > 0 {
+		s.slots = semaphore.NewWeighted(int64(maxJobs))
+	}
+	if storePath != "" {
+		store, err := openJobStore(storePath)
+		if err != nil {
+			return err
+		}
+		defer store.close()
+		s.store = store
+		if err := s.restore(); err != nil {
+			return err
+		}
+		if err := s.restoreSchedules(); err != nil {
+			return err
+		}
+	}
+	s.cron.Start()
+	defer s.cron.Stop()
+	mux := http.NewServeMux()
+	mux.Handle("GET /", dashboardHandler())
+	mux.HandleFunc("GET /jobs", s.listJobs)
+	mux.HandleFunc("POST /jobs", s.createJob)
+	mux.HandleFunc("GET /jobs/{id}", s.getJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.deleteJob)
+	mux.HandleFunc("GET /jobs/{id}/stream", s.streamJob)
+	mux.HandleFunc("POST /jobs/{id}/pause", s.pauseJob)
+	mux.HandleFunc("POST /jobs/{id}/resume", s.resumeJob)
+	mux.HandleFunc("GET /schedules", s.listSchedules)
+	mux.HandleFunc("POST /schedules", s.createSchedule)
+	mux.HandleFunc("GET /schedules/{id}", s.getSchedule)
+	mux.HandleFunc("DELETE /schedules/{id}", s.deleteSchedule)
+	mux.HandleFunc("POST /schedules/{id}/enable", s.enableSchedule)
+	mux.HandleFunc("POST /schedules/{id}/disable", s.disableSchedule)
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	slog.Info("serving job API", "addr", addr, "max_jobs", maxJobs)
+
+	var grpcErrc <-chan error // Stays nil when gRPC is disabled, so the select below ignores it
+	if grpcAddr != "" {
+		grpcServer, serveErrc, err := serveGRPC(grpcAddr, s)
+		if err != nil {
+			server.Close()
+			return err
+		}
+		// Stop cancels the calls still streaming, unlike GracefulStop which would wait for them to find all their primes
+		defer grpcServer.Stop()
+		grpcErrc = serveErrc
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	case err := <-grpcErrc:
+		server.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down job API")
+	// Waits for a schedule creating its job, which the jobs' context being cancelled then stops too
+	<-s.cron.Stop().Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	// The jobs' contexts are cancelled along with the server's, wait for their pipelines to stop
+	s.wg.Wait()
+	return nil
+}
+
+// createJob handles POST /jobs, starting a job (or queueing it, if max-jobs are running) and returning its status
+func (s *jobServer) createJob(w http.ResponseWriter, r *http.Request) {
+	var req jobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid job request: %v", err), http.StatusBadRequest)
+		return
+	}
+	cfg, err := s.jobConfig(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	j := s.addJob(cfg, "")
+	w.Header().Set("Location", "/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, j.status())
+}
+
+// addJob creates a job with the settings cfg and starts it, or queues it. sched is the ID of the schedule creating it, if one is
+func (s *jobServer) addJob(cfg config, sched string) *job {
+	s.mu.Lock()
+	s.nextID++
+	j, ctx := s.newJob(strconv.Itoa(s.nextID), cfg)
+	j.sched = sched
+	j.queued = time.Now()
+	j.saved = searchCheckpoint(cfg)
+	s.mu.Unlock()
+	s.start(ctx, j)
+	return j
+}
+
+// newJob adds a queued job to the server, returning it along with the context it's to run on, derived from the server's.
+// The server's lock must be held
+func (s *jobServer) newJob(id string, cfg config) (*job, context.Context) {
+	ctx, cancel := context.WithCancelCause(s.ctx)
+	cfg.gate = new(pipeline.Gate)
+	cfg.checkpointEvery = s.defaults.checkpointEvery
+	j := &job{id: id, cfg: cfg, cancel: func() { cancel(errJobCancelled) }, done: make(chan struct{}), updated: make(chan struct{}), state: JOB_QUEUED, store: s.store}
+	s.jobs[id] = j
+	return j, ctx
+}
+
+// start runs a queued job on ctx, straight away if a slot is free and once one is otherwise
+func (s *jobServer) start(ctx context.Context, j *job) {
+	j.mu.Lock()
+	// TryAcquire fails while other jobs wait for a slot, so a new job doesn't overtake them
+	if s.slots == nil || s.slots.TryAcquire(1) {
+		j.state = JOB_RUNNING
+		if j.started.IsZero() {
+			j.started = time.Now()
+		}
+	}
+	j.persist()
+	state := j.state
+	j.mu.Unlock()
+
+	if state == JOB_RUNNING {
+		j.logStarted()
+	} else {
+		slog.Info("job queued", "job", j.id)
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		j.run(ctx, s.slots)
+	}()
+}
+
+// jobConfig returns the settings of a job, the server's flags with the request's fields applied over them
+func (s *jobServer) jobConfig(req jobRequest) (config, error) {
+	cfg := s.defaults
+	cfg.strategy = STRATEGY_STREAM
+	if req.Primes != 0 {
+		cfg.numPrimes = req.Primes
+	}
+	if req.Range != 0 {
+		cfg.numRange = req.Range
+	}
+	if req.Workers != 0 {
+		cfg.numWorkers = req.Workers
+	}
+	switch {
+	case cfg.source == SOURCE_FILE:
+		return cfg, fmt.Errorf("jobs can't read candidates from the %s source", SOURCE_FILE)
+	case cfg.numPrimes < 1:
+		return cfg, fmt.Errorf("primes must be positive, got %d", cfg.numPrimes)
+	case cfg.numRange < 1:
+		return cfg, fmt.Errorf("range must be positive, got %d", cfg.numRange)
+	case cfg.from >= cfg.numRange:
+		return cfg, fmt.Errorf("range must be above the server's from flag %d, got %d", cfg.from, cfg.numRange)
+	case cfg.numWorkers < 1 || cfg.numWorkers > MAX_JOB_WORKERS:
+		return cfg, fmt.Errorf("workers must be between 1 and %d, got %d", MAX_JOB_WORKERS, cfg.numWorkers)
+	}
+	return cfg, nil
+}
+
+// listJobs handles GET /jobs, returning the summary of every job created, in the order they were.
+// GET /jobs?status=queued (or any other state) only returns the jobs in that state
+func (s *jobServer) listJobs(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", JOB_QUEUED, JOB_RUNNING, JOB_PAUSED, JOB_DONE, JOB_CANCELLED, JOB_FAILED:
+	default:
+		http.Error(w, fmt.Sprintf("unknown job status %q", status), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for id := 1; id <= s.nextID; id++ {
+		// A restored server can have gaps in its ids, left by the jobs the previous one failed to save
+		if j, ok := s.jobs[strconv.Itoa(id)]; ok {
+			jobs = append(jobs, j)
+		}
+	}
+	s.mu.Unlock()
+	summaries := make([]jobSummary, 0, len(jobs))
+	for _, j := range jobs {
+		if sum := j.summary(); status == "" || sum.Status == status {
+			summaries = append(summaries, sum)
+		}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// getJob handles GET /jobs/{id}, returning the job's status and the primes found so far
+func (s *jobServer) getJob(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, j.status())
+}
+
+// deleteJob handles DELETE /jobs/{id}, cancelling the job (taking it off the queue if it hasn't started). It returns the job's status once the pipeline has stopped
+func (s *jobServer) deleteJob(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	j.cancel()
+	select {
+	case <-r.Context().Done():
+	case <-j.done:
+		writeJSON(w, http.StatusOK, j.status())
+	}
+}
+
+// pauseJob pauses the generation of the job's candidates, the workers finish those in flight and then wait. Pausing a paused job does nothing
+func (s *jobServer) pauseJob(w http.ResponseWriter, r *http.Request) {
+	s.gateJob(w, r, "paused", (*pipeline.Gate).Pause)
+}
+
+// resumeJob resumes the generation of a paused job's candidates. Resuming a job that isn't paused does nothing
+func (s *jobServer) resumeJob(w http.ResponseWriter, r *http.Request) {
+	s.gateJob(w, r, "resumed", (*pipeline.Gate).Resume)
+}
+
+// gateJob applies pause or resume to the gate of the job named in the request path and writes its summary, or a 409 if it's queued or has finished
+func (s *jobServer) gateJob(w http.ResponseWriter, r *http.Request, action string, apply func(*pipeline.Gate) bool) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	if j.state != JOB_RUNNING {
+		state := j.state
+		j.mu.Unlock()
+		http.Error(w, fmt.Sprintf("job %s is %s", j.id, state), http.StatusConflict)
+		return
+	}
+	if apply(j.cfg.gate) {
+		slog.Info("job "+action, "job", j.id)
+	}
+	sum := j.snapshot()
+	j.mu.Unlock()
+	writeJSON(w, http.StatusOK, sum)
+}
+
+// lookup returns the job named in the request path, writing a 404 and returning nil if there's no such job
+func (s *jobServer) lookup(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if j == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+	}
+	return j
+}
+
+// writeJSON writes body as the JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
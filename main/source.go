@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// Value sources, selected with the -source flag
+const (
+	SOURCE_RANDOM     = "random"     // Sample random values from the range, with replacement
+	SOURCE_SEQUENTIAL = "sequential" // Walk the range in order, so every prime in it is found
+	SOURCE_CRYPTO     = "crypto"     // Sample random values from the range using crypto/rand
+	SOURCE_FILE       = "file"       // Read values from the input flag's file (or stdin), one per line
+)
+
+// STDIN_INPUT is the input flag's value for reading candidates from stdin
+const STDIN_INPUT = "-"
+
+// checkRange returns an error if the from flag isn't within 0 and the upper bound of the range
+func checkRange(cfg config) error {
+	low, high := rangeBounds(cfg)
+	if low.Sign() < 0 || low.Cmp(high) >= 0 {
+		return fmt.Errorf("from %v must be at least 0 and below the range %v", low, high)
+	}
+	return nil
+}
+
+// rangeSource returns whether the source flag selects a source drawing from the range, rather than reading numbers from elsewhere
+// (the file and kafka sources, or a source registered by a plugin)
+func rangeSource(cfg config) bool {
+	return cfg.source == SOURCE_RANDOM || cfg.source == SOURCE_CRYPTO || cfg.source == SOURCE_SEQUENTIAL
+}
+
+// valueSource returns the getter producers call for candidate numbers, as selected by the source flag. The range sources draw from the from flag up,
+// any other name is looked up among the sources registered with pipeline.RegisterSource, such as those of the source-plugin flag's plugins
+func valueSource(cfg config) (func() (int64, error), error) {
+	switch cfg.source {
+	case SOURCE_RANDOM:
+		return unique(cfg, pipeline.RandValBetween(cfg.from, cfg.numRange)), nil
+	case SOURCE_CRYPTO:
+		return unique(cfg, pipeline.CryptoRandValBetween(cfg.from, cfg.numRange)), nil
+	case SOURCE_SEQUENTIAL:
+		if cfg.checkpoint != nil {
+			return pipeline.SequentialValFrom(cfg.checkpoint.next, cfg.numRange), nil
+		}
+		return pipeline.SequentialValFrom(cfg.from, cfg.numRange), nil
+	case SOURCE_FILE:
+		// The file stays open for the rest of the run
+		if cfg.inputPath == STDIN_INPUT {
+			return pipeline.ReaderVal(os.Stdin), nil
+		}
+		file, err := os.Open(cfg.inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening input: %w", err)
+		}
+		return pipeline.ReaderVal(file), nil
+	default:
+		factory, ok := pipeline.LookupSource(cfg.source)
+		if !ok && len(pipeline.Sources()) > 0 {
+			return nil, fmt.Errorf("unknown source %q, the registered sources are %q", cfg.source, pipeline.Sources())
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown source %q", cfg.source)
+		}
+		src, err := factory(cfg.sourceArg)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s source: %w", cfg.source, err)
+		}
+		return src.Next, nil
+	}
+}
+
+// unique wraps a random getter so it skips the values it has drawn already when the unique flag is set (see pipeline.UniqueValBetween)
+func unique(cfg config, getValue func() (int64, error)) func() (int64, error) {
+	if !cfg.unique {
+		return getValue
+	}
+	return pipeline.UniqueValBetween(getValue, cfg.from, cfg.numRange, int64(cfg.uniqueMemory)<<20)
+}
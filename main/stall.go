@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// stallWatcher logs a warning when a stage has been waiting on its input (starved) or on the next stage (saturated) for longer than the threshold,
+// pointing at the bottleneck: a starved stage is held back by the stages before it, a saturated one by the stages after it.
+// A wait is reported once, as it crosses the threshold. It checks the stages' flow stats (see stageOptions) while the run goes
+type stallWatcher struct {
+	rep       *report
+	threshold time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newStallWatcher(rep *report, threshold time.Duration) *stallWatcher {
+	return &stallWatcher{rep: rep, threshold: threshold, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (w *stallWatcher) start(cfg config) {
+	go w.watch()
+}
+
+func (w *stallWatcher) prime(found pipeline.Found[result]) {}
+
+func (w *stallWatcher) finish(sum summary) error {
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+// watch checks the stages twice per threshold until finish is called
+func (w *stallWatcher) watch() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.threshold / 2)
+	defer ticker.Stop()
+	starved := make(map[string]bool) // Stages whose current wait was reported already
+	saturated := make(map[string]bool)
+	for {
+		var now time.Time
+		select {
+		case <-w.stop:
+			return
+		case now = <-ticker.C:
+		}
+		for _, stage := range w.rep.stageFlows() {
+			recv, send := stage.stats.Waiting(now)
+			if recv > w.threshold && !starved[stage.name] {
+				slog.Warn("stage starved", "stage", stage.name, "waiting", recv.Round(time.Millisecond), "blocked_receiving", stage.stats.RecvBlockedTime().Round(time.Millisecond))
+			}
+			if send > w.threshold && !saturated[stage.name] {
+				slog.Warn("stage saturated", "stage", stage.name, "waiting", send.Round(time.Millisecond), "blocked_sending", stage.stats.SendBlockedTime().Round(time.Millisecond))
+			}
+			starved[stage.name], saturated[stage.name] = recv > w.threshold, send > w.threshold
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	bolt "go.etcd.io/bbolt"
+)
+
+const STORE_OPEN_TIMEOUT = time.Second // How long to wait for another server holding the store open to let go of it
+
+var (
+	jobsBucket      = []byte("jobs")
+	schedulesBucket = []byte("schedules")
+)
+
+// jobStore keeps the jobs of the server in a bbolt database, so a restarted server still lists the jobs it ran and resumes those it hadn't finished.
+// A job is saved when it's created, when it starts, every checkpoint-every while it runs, and once it finishes.
+// The schedules are kept in a bucket of their own, saved whenever they're changed or fire
+type jobStore struct {
+	db *bolt.DB
+}
+
+// storedJob is a job as it's saved in the store, keyed by its ID
+type storedJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"` // The status of a job that was queued or running when the server stopped is kept, so it's resumed
+	Workers    int        `json:"workers"`
+	Error      string     `json:"error,omitempty"`
+	Schedule   string     `json:"schedule,omitempty"`
+	QueuedAt   time.Time  `json:"queued_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Checkpoint checkpoint `json:"checkpoint"` // The job's search, the primes it found and where its candidates got to
+}
+
+// openJobStore opens the store at path, creating it if it doesn't exist
+func openJobStore(path string) (*jobStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: STORE_OPEN_TIMEOUT})
+	if err != nil {
+		return nil, fmt.Errorf("opening job store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{jobsBucket, schedulesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating job store %s: %w", path, err)
+	}
+	return &jobStore{db: db}, nil
+}
+
+// put saves a job, replacing the version saved before
+func (s *jobStore) put(sj storedJob) error {
+	return s.save(jobsBucket, "job", sj.ID, sj)
+}
+
+// load returns every job saved, in the order they were created
+func (s *jobStore) load() ([]storedJob, error) {
+	return loadAll[storedJob](s, jobsBucket, "job")
+}
+
+// putSchedule saves a schedule, replacing the version saved before
+func (s *jobStore) putSchedule(ss storedSchedule) error {
+	return s.save(schedulesBucket, "schedule", ss.ID, ss)
+}
+
+// loadSchedules returns every schedule saved, in the order they were created
+func (s *jobStore) loadSchedules() ([]storedSchedule, error) {
+	return loadAll[storedSchedule](s, schedulesBucket, "schedule")
+}
+
+// deleteSchedule removes a schedule from the store
+func (s *jobStore) deleteSchedule(id string) error {
+	key, err := storeKey(id)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(schedulesBucket).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("deleting schedule %s: %w", id, err)
+	}
+	return nil
+}
+
+// save saves v as JSON under its ID in bucket, kind naming what it is in the errors
+func (s *jobStore) save(bucket []byte, kind, id string, v any) error {
+	key, err := storeKey(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("saving %s %s: %w", kind, id, err)
+	}
+	return nil
+}
+
+// loadAll decodes everything saved in bucket, in the order of their IDs
+func loadAll[T any](s *jobStore, bucket []byte, kind string) ([]T, error) {
+	var all []T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(key, data []byte) error {
+			var v T
+			if err := json.Unmarshal(data, &v); err != nil {
+				return fmt.Errorf("decoding %s %d: %w", kind, binary.BigEndian.Uint64(key), err)
+			}
+			all = append(all, v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading %ss: %w", kind, err)
+	}
+	return all, nil
+}
+
+// close closes the database
+func (s *jobStore) close() error {
+	return s.db.Close()
+}
+
+// storeKey returns the key of a job or a schedule: its ID as a big-endian integer, so the keys sort in the order they were created
+func storeKey(id string) ([]byte, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID %q", id)
+	}
+	return binary.BigEndian.AppendUint64(nil, n), nil
+}
+
+// restore adds the jobs saved in the store to the server, starting again those that were queued or running when the previous server stopped,
+// in the order they were created. They pick up from their last checkpoint, with the settings they were created with
+func (s *jobServer) restore() error {
+	stored, err := s.store.load()
+	if err != nil {
+		return err
+	}
+	var resumed []*job
+	var contexts []context.Context
+	s.mu.Lock()
+	for _, sj := range stored {
+		id, err := strconv.Atoi(sj.ID)
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("invalid job ID %q in the store", sj.ID)
+		}
+		s.nextID = max(s.nextID, id)
+		cp := sj.Checkpoint
+		cfg := s.defaults
+		cfg.strategy = STRATEGY_STREAM
+		cfg.numWorkers = sj.Workers
+		cfg = cp.apply(cfg)
+		j, ctx := s.newJob(sj.ID, cfg)
+		j.resumed, j.saved, j.queued, j.sched = &cp, cp, sj.QueuedAt, sj.Schedule
+		if sj.StartedAt != nil {
+			j.started = *sj.StartedAt
+		}
+		for _, prime := range cp.Found {
+			j.found = append(j.found, newResult(cfg, pipeline.Found[int64]{Value: prime.Value, Worker: prime.Worker, At: prime.FoundAt}))
+		}
+		if sj.Status == JOB_QUEUED || sj.Status == JOB_RUNNING {
+			resumed, contexts = append(resumed, j), append(contexts, ctx)
+			continue
+		}
+		// A finished job is only listed, its context is never used
+		j.cancel()
+		j.state = sj.Status
+		if sj.FinishedAt != nil {
+			j.finished = *sj.FinishedAt
+		}
+		if sj.Error != "" {
+			j.err = errors.New(sj.Error)
+		}
+		close(j.done)
+	}
+	s.mu.Unlock()
+	slog.Info("restored jobs", "jobs", len(stored), "resumed", len(resumed))
+	for i, j := range resumed {
+		s.start(contexts[i], j)
+	}
+	return nil
+}
+
+// restoreSchedules adds the schedules saved in the store to the server, enabling those that were.
+// The runs that came up while no server was running are skipped, an enabled schedule carries on from its next one
+func (s *jobServer) restoreSchedules() error {
+	stored, err := s.store.loadSchedules()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ss := range stored {
+		id, err := strconv.Atoi(ss.ID)
+		if err != nil {
+			return fmt.Errorf("invalid schedule ID %q in the store", ss.ID)
+		}
+		spec, err := parseCron(ss.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s in the store: %w", ss.ID, err)
+		}
+		s.nextSchedule = max(s.nextSchedule, id)
+		sc := &schedule{storedSchedule: ss, spec: spec}
+		s.schedules[ss.ID] = sc
+		s.setEnabled(sc, ss.Enabled)
+	}
+	slog.Info("restored schedules", "schedules", len(stored))
+	return nil
+}
+
+// persistSchedule saves the schedule to the store, if the server has one. A schedule that can't be saved carries on, the failure is logged.
+// The server's lock must be held
+func (s *jobServer) persistSchedule(sc *schedule) {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.putSchedule(sc.storedSchedule); err != nil {
+		slog.Error("saving schedule", "schedule", sc.ID, "err", err)
+	}
+}
+
+// saveCheckpoint is how a job's checkpointer saves: with the job's status, to the store
+func (j *job) saveCheckpoint(cp checkpoint) error {
+	j.mu.Lock()
+	j.saved = cp
+	sj := j.record()
+	j.mu.Unlock()
+	return j.store.put(sj)
+}
+
+// persist saves the job to the store, if the server has one. A job that can't be saved carries on, the failure is logged.
+// The job's lock must be held
+func (j *job) persist() {
+	if j.store == nil {
+		return
+	}
+	if err := j.store.put(j.record()); err != nil {
+		slog.Error("saving job", "job", j.id, "err", err)
+	}
+}
+
+// record returns the job as it's saved to the store. The job's lock must be held
+func (j *job) record() storedJob {
+	sj := storedJob{ID: j.id, Status: j.state, Workers: j.cfg.numWorkers, Schedule: j.sched, QueuedAt: j.queued, Checkpoint: j.saved}
+	if j.err != nil {
+		sj.Error = j.err.Error()
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		sj.StartedAt = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		sj.FinishedAt = &finished
+	}
+	return sj
+}
+
+// searchCheckpoint returns the checkpoint of a job that hasn't run yet, which only describes its search
+func searchCheckpoint(cfg config) checkpoint {
+	cp := checkpoint{
+		Primes:    cfg.numPrimes,
+		Range:     cfg.numRange,
+		From:      cfg.from,
+		Source:    cfg.source,
+		Mode:      cfg.search,
+		Predicate: cfg.predicate,
+		Next:      cfg.from,
+		SavedAt:   time.Now(),
+	}
+	if cfg.seeded {
+		seed := cfg.seed
+		cp.Seed = &seed
+		cp.Workers = make([]workerCheckpoint, cfg.numWorkers)
+	}
+	return cp
+}
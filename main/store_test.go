@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// testServer returns a job server with the given defaults and a store in a temporary directory, with nothing restored from it yet
+func testServer(t *testing.T, defaults config) *jobServer {
+	t.Helper()
+	store, err := openJobStore(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	t.Cleanup(func() { store.close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &jobServer{ctx: ctx, defaults: defaults, cron: cron.New(), jobs: make(map[string]*job), schedules: make(map[string]*schedule), store: store}
+}
+
+// waitJob waits for a job's pipeline to stop, failing the test if it doesn't in time
+func waitJob(t *testing.T, j *job) {
+	t.Helper()
+	select {
+	case <-j.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("job %s still running", j.id)
+	}
+}
+
+// primesOf returns the numbers a job found, in the order it found them
+func primesOf(j *job) []int64 {
+	return j.status().Primes
+}
+
+func TestRestore(t *testing.T) {
+	defaults := testConfig(t, "-source=sequential", "-r=100000", "-n=2")
+	defaults.checkpointEvery = time.Hour // Bound by the serve command's flags
+	s := testServer(t, defaults)
+	finishedAt := time.Now().Add(-time.Minute)
+	search := searchCheckpoint(defaults)
+
+	finished := search
+	finished.Primes = 3
+	finished.Found = []checkpointPrime{{Value: 2}, {Value: 3}, {Value: 5}}
+	finished.Next = 6
+	running := search
+	running.Primes = 10
+	running.Found = []checkpointPrime{{Value: 2}, {Value: 3}, {Value: 5}}
+	running.Next = 6
+	running.Tested = 6
+	queued := search
+	queued.Primes = 4
+	// Job 3 failed to save, leaving a gap in the ids. The running job has a single worker, so it finds the primes in order
+	for _, sj := range []storedJob{
+		{ID: "1", Status: JOB_DONE, Workers: 2, FinishedAt: &finishedAt, Checkpoint: finished},
+		{ID: "2", Status: JOB_RUNNING, Workers: 1, StartedAt: &finishedAt, Checkpoint: running},
+		{ID: "4", Status: JOB_QUEUED, Workers: 2, Checkpoint: queued},
+	} {
+		if err := s.store.put(sj); err != nil {
+			t.Fatalf("saving job %s: %v", sj.ID, err)
+		}
+	}
+
+	if err := s.restore(); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if s.nextID != 4 {
+		t.Errorf("next ID is %d after restoring, want the largest stored, 4", s.nextID)
+	}
+	for _, id := range []string{"1", "2", "4"} {
+		waitJob(t, s.jobs[id])
+	}
+	s.wg.Wait()
+
+	// The finished job is listed as it was saved, without running again
+	done := s.jobs["1"]
+	if st := done.status(); st.Status != JOB_DONE || !slices.Equal(st.Primes, []int64{2, 3, 5}) || st.Tested != 0 {
+		t.Errorf("finished job restored as %s with primes %v after testing %d, want %s with 2, 3, 5 and nothing tested", st.Status, st.Primes, st.Tested, JOB_DONE)
+	}
+	if !done.finished.Equal(finishedAt) {
+		t.Errorf("finished job restored as finished at %v, want %v", done.finished, finishedAt)
+	}
+
+	// The running job carries on from its checkpoint: its primes are kept, and aren't found again from the candidates below Next
+	wantPrimes := []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}
+	resumed := s.jobs["2"]
+	if got := primesOf(resumed); resumed.status().Status != JOB_DONE || !slices.Equal(got, wantPrimes) {
+		t.Errorf("running job finished as %s with primes %v, want %s with %v", resumed.status().Status, got, JOB_DONE, wantPrimes)
+	}
+	if resumed.resumed == nil || resumed.resumed.Next != 6 {
+		t.Errorf("running job resumed from %+v, want its checkpoint", resumed.resumed)
+	}
+
+	// The queued job runs from the start of its search
+	if got := primesOf(s.jobs["4"]); !sameNumbers(got, wantPrimes[:4]) {
+		t.Errorf("queued job found %v, want %v", got, wantPrimes[:4])
+	}
+
+	// The jobs are listed in the order of their ids, skipping the gap
+	w := httptest.NewRecorder()
+	s.listJobs(w, httptest.NewRequest("GET", "/jobs", nil))
+	var listed []jobSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decoding job list %q: %v", w.Body.String(), err)
+	}
+	var ids []string
+	for _, sum := range listed {
+		ids = append(ids, sum.ID)
+	}
+	if !slices.Equal(ids, []string{"1", "2", "4"}) {
+		t.Errorf("listed jobs %v, want 1, 2 and 4", ids)
+	}
+
+	// Restoring again sees the jobs as they finished
+	stored, err := s.store.load()
+	if err != nil {
+		t.Fatalf("loading jobs: %v", err)
+	}
+	for _, sj := range stored {
+		if sj.Status != JOB_DONE {
+			t.Errorf("job %s saved as %s once it's over, want %s", sj.ID, sj.Status, JOB_DONE)
+		}
+		if sj.ID == "2" && (sj.Checkpoint.Next <= 29 || sj.Checkpoint.Tested <= running.Tested) {
+			t.Errorf("running job saved with next candidate %d after testing %d, want past 29 and more than the %d tested before it was restored", sj.Checkpoint.Next, sj.Checkpoint.Tested, running.Tested)
+		}
+	}
+}
+
+// sameNumbers reports whether two lists hold the same numbers, in any order
+func sameNumbers(a, b []int64) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
@@ -0,0 +1,671 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// Execution strategies, selected with the -strategy flag
+const (
+	STRATEGY_STREAM    = "stream"    // Test a stream of random numbers with a pool of workers
+	STRATEGY_SIEVE     = "sieve"     // Sieve the whole range once, then pick primes from it
+	STRATEGY_SEGMENTED = "segmented" // Sieve the range segment by segment with the workers, streaming the primes of each segment as it's done
+)
+
+// runStream finds prime numbers by fanning a stream of candidate numbers out to workers, printing each one found.
+// It returns how many were found and the first error reported by any stage
+func runStream(ctx context.Context, cfg config, rep *report, out output) (found int, err error) {
+	// Return only once every stage has exited, so a server's job or call leaves no generator behind it once its primes are found.
+	// A file source can be blocked reading a terminal, which isn't worth holding the CLI up for
+	if cfg.source != SOURCE_FILE {
+		defer rep.running.Wait()
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := checkComposites(cfg); err != nil {
+		return 0, err
+	}
+	if cfg.transport != "" && (cfg.seeded || cfg.autoscale || enveloped(cfg) || cfg.source == SOURCE_KAFKA) {
+		return 0, fmt.Errorf("a coordinator can't be combined with a seed, autoscaling, tracing, latency tracking or the %s source", SOURCE_KAFKA)
+	}
+	if cfg.redisAddr != "" && (cfg.transport != "" || enveloped(cfg) || cfg.source == SOURCE_KAFKA) {
+		return 0, fmt.Errorf("redis can't be combined with a coordinator, tracing, latency tracking or the %s source", SOURCE_KAFKA)
+	}
+	if cfg.cacheSize > 0 {
+		cfg.resultCache = pipeline.NewResultCache(cfg.cacheSize)
+		rep.setCache(cfg.resultCache)
+	}
+	if bigRange(cfg) {
+		return runBig(ctx, cancel, cfg, rep, out)
+	}
+	if cfg.search == SEARCH_FACTOR {
+		return runFactor(ctx, cancel, cfg, rep, out)
+	}
+	if cfg.engine == ENGINE_ERRGROUP {
+		return runGroup(ctx, cfg, rep, out)
+	}
+	if cfg.source == SOURCE_KAFKA {
+		return runKafka(ctx, cancel, cfg, rep, out)
+	}
+	if enveloped(cfg) {
+		return runTraced(ctx, cancel, cfg, rep, out)
+	}
+
+	keep, err := workerTest(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.checkpoint != nil {
+		keep = cfg.checkpoint.track(keep)
+	}
+	if cfg.compositesPath != "" {
+		composites := newCompositeSink(parent, cfg.compositesPath, cfg.buffer)
+		cfg.composites, cfg.compositeSenders = composites.stream, new(sync.WaitGroup)
+		defer func() {
+			// The sink's stream can only be closed once every worker sending on it has exited. The other stages aren't waited for,
+			// the file source's may be blocked reading a terminal
+			cancel()
+			cfg.compositeSenders.Wait()
+			if closeErr := composites.close(err == nil); err == nil {
+				err = closeErr
+			}
+		}()
+	}
+
+	// Fan out the workers and multiplex their results, fanning them in to a single stream of prime numbers
+	var reducedStream <-chan pipeline.Found[int64]
+	var errcs []<-chan error
+	if cfg.seeded {
+		reducedStream, errcs, err = seededWorkers(ctx, cfg, rep, filterWorker(keep, cfg.composites))
+	} else {
+		reducedStream, errcs, err = sharedWorkers(ctx, cfg, rep, keep)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Values are drawn with replacement, so duplicates are dropped before counting towards the result. The sharded pool has deduped them already
+	primeNumberFinder := reducedStream
+	if cfg.pool != POOL_SHARDED {
+		primeNumberFinder = pipeline.DistinctBy(ctx, reducedStream, func(f pipeline.Found[int64]) int64 { return f.Value }, cfg.dedupLimit, distinctOptions(cfg, rep)...)
+	}
+	primeNumberStream := pipeline.Take(ctx, primeNumberFinder, cfg.numPrimes, stageOptions(cfg, rep, "result")...)
+	return collectResults(cancel, primeNumberStream, pipeline.MergeErrors(errcs...), func(found pipeline.Found[int64]) { out.prime(newResult(cfg, found)) })
+}
+
+// collectResults passes each item of the result stream to emit until the stream closes, while watching the merged error channel for a failure.
+// It returns how many items were emitted and the first error reported by any stage
+func collectResults[T any](cancel context.CancelFunc, resultStream <-chan T, errc <-chan error, emit func(T)) (int, error) {
+	found := 0
+	for resultStream != nil {
+		select {
+		case item, ok := <-resultStream:
+			if !ok {
+				resultStream = nil
+				continue
+			}
+			emit(item)
+			found++
+		case err, ok := <-errc:
+			if ok {
+				return found, err
+			}
+			errc = nil
+		}
+	}
+	return found, firstError(cancel, errc)
+}
+
+// producerStream starts the producers generating candidates from the source flag's source, limited to the rate flag's rate.
+// It returns the stream of candidates the workers share along with the producers' error channels
+func producerStream(ctx context.Context, cfg config, rep *report) (<-chan int64, []<-chan error, error) {
+	getValue, err := valueSource(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.numProducers < 1 {
+		return nil, nil, fmt.Errorf("need at least one producer, got %d", cfg.numProducers)
+	}
+
+	// Generate an input stream of ints. Producers share the getter, and are fanned in when there's more than one
+	sourceCtx := sourceContext(ctx, cfg)
+	var errcs []<-chan error
+	producers := make([]<-chan int64, cfg.numProducers)
+	for i := 0; i < cfg.numProducers; i++ {
+		var sourceErrs <-chan error
+		producers[i], sourceErrs = pipeline.CreateValueStream(sourceCtx, countValues(rep, cfg.maxCandidates, getValue), stageOptions(cfg, rep, "source")...)
+		errcs = append(errcs, sourceErrs)
+	}
+	intStream := producers[0]
+	if len(producers) > 1 {
+		intStream = pipeline.ReduceWorkers(ctx, producers, stageOptions(cfg, rep, "producer fan-in")...)
+	}
+	return prefilter(ctx, cfg, rep, -1, throttle(ctx, cfg, rep, intStream, 1)), errcs, nil
+}
+
+// prefilter runs a stream of candidates through the wheel pre-filter with the wheel flag, so the multiples of 2, 3, 5 and 7 never reach the workers.
+// A candidate it rejects is done with as one the workers found composite: counted in the report, sent to the composites flag's sink,
+// checkpointed past (counted as drawn by worker, the seeded worker the stream feeds, or -1 for a shared stream) and counted in its partition
+func prefilter(ctx context.Context, cfg config, rep *report, worker int, intStream <-chan int64) <-chan int64 {
+	if !cfg.wheel {
+		return intStream
+	}
+	parts := rep.partitioned()
+	return pipeline.WheelFilter(ctx, intStream, append(compositeOptions(cfg, stageOptions(cfg, rep, "wheel")), pipeline.WithDiscard(func(item any) {
+		num := item.(int64)
+		rep.wheeled.Add(1)
+		if cfg.checkpoint != nil {
+			cfg.checkpoint.prefiltered(worker, num)
+		}
+		if parts != nil {
+			parts.record(parts.partition(num), 1)
+		}
+		if cfg.composites != nil {
+			select {
+			case <-ctx.Done():
+			case cfg.composites <- num:
+			}
+		}
+	}))...)
+}
+
+// sharedWorkers starts workers that all read from one input stream fed by the producers, and fans in their results in the order they are found.
+// It returns the stream of prime numbers along with the error channels of every stage
+func sharedWorkers(ctx context.Context, cfg config, rep *report, keep func(int64) (bool, error)) (<-chan pipeline.Found[int64], []<-chan error, error) {
+	// A gRPC coordinator's partitions are set up ahead of the candidates, the wheel pre-filter counts those it rejects in them
+	if cfg.transport == TRANSPORT_GRPC {
+		if err := partitionRun(cfg, rep); err != nil {
+			return nil, nil, err
+		}
+	}
+	intStream, errcs, err := producerStream(ctx, cfg, rep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.pool != POOL_WORKERS && (cfg.autoscale || cfg.transport != "") {
+		return nil, nil, fmt.Errorf("the %s pool can't be combined with autoscaling or a coordinator", cfg.pool)
+	}
+
+	// When autoscaling, a pool of workers writing to one stream is resized as the run goes
+	if cfg.autoscale {
+		if cfg.batchSize > 1 || cfg.redisAddr != "" {
+			return nil, nil, fmt.Errorf("autoscaling can't be combined with batching or Redis")
+		}
+		if cfg.minWorkers < 1 || cfg.maxWorkers < cfg.minWorkers {
+			return nil, nil, fmt.Errorf("invalid autoscaling bounds %d-%d", cfg.minWorkers, cfg.maxWorkers)
+		}
+		size := min(max(cfg.numWorkers, cfg.minWorkers), cfg.maxWorkers)
+		pool := pipeline.NewPool(ctx, intStream, candidateTest(cfg), size, stageOptions(cfg, rep, "pool")...)
+		switch cfg.autoscalePolicy {
+		case AUTOSCALE_IDLE:
+			pool.Autoscale(cfg.minWorkers, cfg.maxWorkers, cfg.autoscaleInterval)
+		case AUTOSCALE_GRADIENT:
+			pool.AdaptGradient(cfg.minWorkers, cfg.maxWorkers, cfg.autoscaleInterval)
+		default:
+			return nil, nil, fmt.Errorf("unknown autoscale policy %q", cfg.autoscalePolicy)
+		}
+		rep.setPool(pool)
+		return pool.Out(), append(errcs, pool.Errors()), nil
+	}
+
+	// In coordinator mode the workers run in other processes, reached over NATS or registered over gRPC
+	if cfg.transport != "" {
+		remote := remoteWorkers
+		if cfg.transport == TRANSPORT_GRPC {
+			remote = grpcWorkers
+		}
+		workers, workerErrs, err := remote(ctx, cfg, intStream, cfg.numWorkers, rep)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pipeline.ReduceWorkers(ctx, workers, fanInOptions(cfg, rep)...), append(errcs, workerErrs...), nil
+	}
+
+	// A semaphore pool is a single worker running up to n tests at once, instead of n workers fanned in
+	if cfg.pool == POOL_SEMAPHORE {
+		if cfg.batchSize > 1 {
+			return nil, nil, fmt.Errorf("the %s pool can't be combined with batching", POOL_SEMAPHORE)
+		}
+		workers, workerErrs := startWorkers(ctx, cfg, intStream, 1, rep, semaphoreWorker(keep, cfg.numWorkers))
+		return workers[0], append(errcs, workerErrs...), nil
+	}
+
+	// A sharded pool dedups each shard's primes as they're found, the shards never share a candidate
+	if cfg.pool == POOL_SHARDED {
+		shards := pipeline.Shard(ctx, intStream, cfg.numWorkers, shardKey, stageOptions(cfg, rep, "shard")...)
+		workers := make([]<-chan pipeline.Found[int64], len(shards))
+		for i, shard := range shards {
+			worker, workerErrs := startWorkers(ctx, cfg, shard, 1, rep, filterWorker(keep, cfg.composites))
+			workers[i] = pipeline.DistinctBy(ctx, worker[0], func(f pipeline.Found[int64]) int64 { return f.Value }, cfg.dedupLimit, distinctOptions(cfg, rep)...)
+			errcs = append(errcs, workerErrs...)
+		}
+		return pipeline.ReduceWorkers(ctx, workers, fanInOptions(cfg, rep)...), errcs, nil
+	}
+
+	if cfg.pool == POOL_STEALING {
+		if cfg.batchSize > 1 {
+			return nil, nil, fmt.Errorf("the %s pool can't be combined with batching", POOL_STEALING)
+		}
+		workers, workerErrs := stealingWorkers(ctx, cfg, intStream, rep, func(num int64) (int64, bool, error) {
+			prime, err := keep(num)
+			return num, prime, err
+		})
+		return pipeline.ReduceWorkers(ctx, workers, fanInOptions(cfg, rep)...), append(errcs, workerErrs), nil
+	}
+
+	// Ordered workers fan in their results in the order of the candidates, through a reorder buffer
+	if cfg.ordered {
+		workers, workerErrs := orderedWorkers(ctx, cfg, intStream, rep, func(num int64) (int64, bool, error) {
+			prime, err := keep(num)
+			return num, prime, err
+		})
+		return workers, append(errcs, workerErrs), nil
+	}
+
+	// Set workers that get prime numbers from input. Fan out the workers
+	workers, workerErrs := startWorkers(ctx, cfg, intStream, cfg.numWorkers, rep, filterWorker(keep, cfg.composites))
+	errcs = append(errcs, workerErrs...)
+	return pipeline.ReduceWorkers(ctx, workers, fanInOptions(cfg, rep)...), errcs, nil
+}
+
+// seededWorkers gives each worker its own random input stream, seeded with a sub-seed derived from the seed flag, and fans in their results in turn.
+// Each worker's primes then only depend on its seed, so two runs with the same flags find the same primes in the same order
+func seededWorkers[R any](ctx context.Context, cfg config, rep *report, work workerFunc[R]) (<-chan pipeline.Found[R], []<-chan error, error) {
+	if cfg.source != SOURCE_RANDOM {
+		return nil, nil, fmt.Errorf("a seed can only be used with the %s source", SOURCE_RANDOM)
+	}
+	if cfg.autoscale || cfg.pool != POOL_WORKERS {
+		return nil, nil, fmt.Errorf("a seeded run has one stream per worker, it can't be autoscaled or run the %s pool", cfg.pool)
+	}
+	if cfg.unique {
+		return nil, nil, fmt.Errorf("a seeded run has one stream per worker, the streams can't share the unique flag's record of the candidates drawn")
+	}
+
+	var workers []<-chan pipeline.Found[R]
+	var errcs []<-chan error
+	sourceCtx := sourceContext(ctx, cfg)
+	for i := 0; i < cfg.numWorkers; i++ {
+		getValue := pipeline.SeededRandValBetween(cfg.from, cfg.numRange, pipeline.SubSeed(cfg.seed, i))
+		if cfg.checkpoint != nil {
+			var err error
+			getValue, err = cfg.checkpoint.fastForward(i, getValue, pipeline.SeededRandValBetween(cfg.from, cfg.numRange, pipeline.SubSeed(cfg.seed, i)))
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		intStream, sourceErrs := pipeline.CreateValueStream(sourceCtx, countValues(rep, cfg.maxCandidates, getValue), stageOptions(cfg, rep, "source")...)
+		worker, workerErrs := startWorkers(ctx, cfg, prefilter(ctx, cfg, rep, i, throttle(ctx, cfg, rep, intStream, cfg.numWorkers)), 1, rep, work)
+		workers = append(workers, worker...)
+		errcs = append(errcs, sourceErrs)
+		errcs = append(errcs, workerErrs...)
+	}
+	return pipeline.RoundRobin(ctx, workers, stageOptions(cfg, rep, "round robin")...), errcs, nil
+}
+
+// sourceContext returns the context of the stages generating candidates. It's derived from the pipeline's ctx, so they stop along with the rest of it,
+// and it's also cancelled once cfg.generation is done, tearing down the generators alone: every stage closes its output once its input closes,
+// so the workers finish the candidates in flight and the results they find still come out of the fan-in, with no candidate generated that won't be tested
+func sourceContext(ctx context.Context, cfg config) context.Context {
+	if cfg.generation == nil {
+		return ctx
+	}
+	sourceCtx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(cfg.generation, func() { cancel(context.Cause(cfg.generation)) })
+	context.AfterFunc(sourceCtx, func() { stop() })
+	return sourceCtx
+}
+
+// throttle limits the candidates of a stream to its share of the rate flag's rate, when the rate is split between n streams.
+// It's where candidates enter the workers, so it also holds them back while the run is paused (see cfg.gate)
+func throttle[T any](ctx context.Context, cfg config, rep *report, stream <-chan T, n int) <-chan T {
+	if cfg.gate != nil {
+		stream = pipeline.Gated(ctx, stream, cfg.gate, stageOptions(cfg, rep, "gate")...)
+	}
+	if cfg.rate <= 0 {
+		return stream
+	}
+	rate := cfg.rate / float64(n)
+	burst := cfg.burst / n
+	if cfg.burst == DEFAULT_BURST {
+		burst = int(rate / 10)
+	}
+	return pipeline.Throttle(ctx, stream, rate, burst, stageOptions(cfg, rep, "throttle")...)
+}
+
+// stageOptions returns the options of a stage: the buffer flag's capacity, and the flow stats shared by the stages with the given name
+// when the metrics endpoint, stall warnings or the dashboard need them. Timing every hand-off isn't free, so the stages don't otherwise
+func stageOptions(cfg config, rep *report, name string) []pipeline.Option {
+	opts := []pipeline.Option{pipeline.WithBuffer(cfg.buffer), pipeline.WithWaitGroup(&rep.running)}
+	if cfg.metricsAddr != "" || cfg.stallThreshold > 0 || cfg.tui {
+		opts = append(opts, pipeline.WithFlowStats(rep.stageStats(name)))
+	}
+	return opts
+}
+
+// compositeOptions adds the goroutines of a stage sending on the composites flag's stream to the group waited for before it's closed, if it's set
+func compositeOptions(cfg config, opts []pipeline.Option) []pipeline.Option {
+	if cfg.compositeSenders == nil {
+		return opts
+	}
+	return append(opts, pipeline.WithWaitGroup(cfg.compositeSenders))
+}
+
+// fanInOptions returns the options of the stage fanning in the workers, which forwards the results at or above the priority-above flag
+// ahead of the others when the stages after it are contended
+func fanInOptions(cfg config, rep *report) []pipeline.Option {
+	opts := stageOptions(cfg, rep, "worker fan-in")
+	if cfg.priorityAbove <= 0 {
+		return opts
+	}
+	return append(opts, pipeline.WithPriority(func(item any) int {
+		var num int64
+		switch found := item.(type) {
+		case pipeline.Found[int64]:
+			num = found.Value
+		case pipeline.Found[kafkaCandidate]:
+			num = found.Value.Value
+		case pipeline.Found[pipeline.Item[int64]]:
+			num = found.Value.Value
+		}
+		if num >= cfg.priorityAbove {
+			return 1
+		}
+		return 0
+	}))
+}
+
+// distinctOptions returns the options of the stages deduping the results, counting the duplicates they drop in the report
+func distinctOptions(cfg config, rep *report) []pipeline.Option {
+	return append(stageOptions(cfg, rep, "distinct"), pipeline.WithDiscard(func(any) { rep.discarded.Add(1) }))
+}
+
+// workerFunc starts a worker reading candidates from intStream, or from batchStream when the batch flag is set, with the given stats and options
+type workerFunc[R any] func(ctx context.Context, intStream <-chan int64, batchStream <-chan []int64, stats *pipeline.Stats, opts ...pipeline.Option) (<-chan R, <-chan error)
+
+// filterWorker returns the workers keeping the candidates that pass the keep test (see workerTest).
+// They send the candidates failing it on rejected, unless it's nil (see pipeline.PartitionWorker)
+func filterWorker(keep func(int64) (bool, error), rejected chan<- int64) workerFunc[int64] {
+	return func(ctx context.Context, intStream <-chan int64, batchStream <-chan []int64, stats *pipeline.Stats, opts ...pipeline.Option) (<-chan int64, <-chan error) {
+		if batchStream != nil {
+			return pipeline.PartitionBatchWorker(ctx, batchStream, keep, rejected, stats, opts...)
+		}
+		return pipeline.PartitionWorker(ctx, intStream, keep, rejected, stats, opts...)
+	}
+}
+
+// shardKey is the key candidates are sharded by. Sharding by the candidate itself would send the even numbers, which are never prime,
+// to half of an even number of shards
+func shardKey(num int64) uint64 {
+	return pipeline.Mix64(uint64(num))
+}
+
+// semaphoreWorker returns the worker of the semaphore pool, testing up to n candidates at once with keep (see pipeline.SemaphoreWorker)
+func semaphoreWorker(keep func(int64) (bool, error), n int) workerFunc[int64] {
+	return func(ctx context.Context, intStream <-chan int64, batchStream <-chan []int64, stats *pipeline.Stats, opts ...pipeline.Option) (<-chan int64, <-chan error) {
+		return pipeline.SemaphoreWorker(ctx, intStream, keep, int64(n), stats, opts...)
+	}
+}
+
+// stealingWorkers starts the n workers of the stealing pool on stream, each turning a candidate into a result with work, or none.
+// It returns the workers' streams, with each result annotated with the worker that found it, and their error channel.
+// The workers depend on each other's deques, so unlike startWorkers's they aren't supervised: the first panic fails the run
+func stealingWorkers[In, R any](ctx context.Context, cfg config, stream <-chan In, rep *report, work func(In) (R, bool, error)) ([]<-chan pipeline.Found[R], <-chan error) {
+	stats := make([]*pipeline.Stats, cfg.numWorkers)
+	indexes := make([]int, cfg.numWorkers)
+	for i := range stats {
+		indexes[i], stats[i] = rep.addWorker()
+	}
+	results, errc := pipeline.StealingWorkers(ctx, stream, work, stats, stageOptions(cfg, rep, "worker")...)
+	annotateOpts := stageOptions(cfg, rep, "annotate")
+	workers := make([]<-chan pipeline.Found[R], len(results))
+	for i, result := range results {
+		workers[i] = pipeline.Annotate(ctx, result, indexes[i], stats[i], annotateOpts...)
+	}
+	return workers, errc
+}
+
+// orderedWorkers starts the n workers of the ordered flag on stream, each turning a candidate into a result with work, or none.
+// It returns one stream of their results in the order of the candidates, each annotated with the worker that found it, and their error channel.
+// Like stealingWorkers's, the workers aren't supervised: a worker restarted past a candidate would hold up the results after it
+func orderedWorkers[In, R any](ctx context.Context, cfg config, stream <-chan In, rep *report, work func(In) (R, bool, error)) (<-chan pipeline.Found[R], <-chan error) {
+	stats := make([]*pipeline.Stats, cfg.numWorkers)
+	indexes := make([]int, cfg.numWorkers)
+	for i := range stats {
+		indexes[i], stats[i] = rep.addWorker()
+	}
+	results, errc := pipeline.OrderedWorkers(ctx, stream, work, stats, cfg.reorderWindow, stageOptions(cfg, rep, "worker")...)
+	return pipeline.Map(ctx, results, func(found pipeline.Found[R]) pipeline.Found[R] {
+		found.Worker = indexes[found.Worker]
+		return found
+	}, stageOptions(cfg, rep, "annotate")...), errc
+}
+
+// startWorkers fans out n workers started with work that get their results from intStream, such as the prime numbers kept by filterWorker.
+// When the batch flag is set the stream is batched first, and the workers read batches.
+// It returns the workers' streams, with each result annotated with the worker that found it, and their error channels
+func startWorkers[R any](ctx context.Context, cfg config, intStream <-chan int64, n int, rep *report, work workerFunc[R]) ([]<-chan pipeline.Found[R], []<-chan error) {
+	var batchStream <-chan []int64
+	if cfg.batchSize > 1 {
+		batchStream = pipeline.Batch(ctx, intStream, cfg.batchSize, cfg.batchWait, stageOptions(cfg, rep, "batch")...)
+	}
+	workerOpts, annotateOpts := compositeOptions(cfg, stageOptions(cfg, rep, "worker")), stageOptions(cfg, rep, "annotate")
+	// A supervisor restarts its worker, so it's waited for along with it
+	supervisorOpts := compositeOptions(cfg, stageOptions(cfg, rep, "supervisor"))
+
+	workers := make([]<-chan pipeline.Found[R], n)
+	errcs := make([]<-chan error, n)
+	for i := 0; i < n; i++ {
+		index, stats := rep.addWorker()
+		start := func() (<-chan R, <-chan error) { return work(ctx, intStream, batchStream, stats, workerOpts...) }
+		// A worker that panics stops with a PanicError, the supervisor restarts it unless restarts are disabled
+		var worker <-chan R
+		if cfg.maxRestarts > 0 {
+			worker, errcs[i] = pipeline.Supervise(ctx, start, cfg.maxRestarts, stats, supervisorOpts...)
+		} else {
+			worker, errcs[i] = start()
+		}
+		workers[i] = pipeline.Annotate(ctx, worker, index, stats, annotateOpts...)
+	}
+	return workers, errcs
+}
+
+// runSieve sieves the whole range concurrently, then prints P distinct primes picked from it to match the output of the stream strategy:
+// at random for the random sources, or the first P in order for the sequential source. It returns how many were found.
+// With the sieve-file flag the sieve is kept in a file mapped into memory, and the primes are walked rather than collected, so only the P picked are held
+func runSieve(ctx context.Context, cfg config, rep *report, out output) (int, error) {
+	if cfg.predicate != PREDICATE_PRIME {
+		return 0, fmt.Errorf("the %s strategy only finds primes, it can't be combined with the %s predicate", STRATEGY_SIEVE, cfg.predicate)
+	}
+	if cfg.search == SEARCH_FACTOR || cfg.search == SEARCH_MERSENNE {
+		return 0, fmt.Errorf("the %s strategy only finds primes, it can't be combined with the %s mode", STRATEGY_SIEVE, cfg.search)
+	}
+	if cfg.wasmFilter != nil {
+		return 0, fmt.Errorf("the %s strategy only finds primes, it can't be combined with a filter module", STRATEGY_SIEVE)
+	}
+	if bigRange(cfg) {
+		return 0, fmt.Errorf("the %s strategy can't sieve a range beyond int64", STRATEGY_SIEVE)
+	}
+	if !rangeSource(cfg) {
+		return 0, fmt.Errorf("the %s strategy picks primes from the range, it can't test numbers from the %s source", STRATEGY_SIEVE, cfg.source)
+	}
+	var sieve *pipeline.Sieve
+	var err error
+	if cfg.sieveFile != "" {
+		sieve, err = pipeline.NewFileSieve(ctx, cfg.numRange, cfg.numWorkers, cfg.sieveFile)
+		if err == nil {
+			// The sieve leaves its file to the caller once it's closed, the run doesn't keep it
+			defer os.Remove(cfg.sieveFile)
+		}
+	} else {
+		sieve, err = pipeline.NewSieve(ctx, cfg.numRange, cfg.numWorkers)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, nil // Interrupted before the sieve was finished, nothing to report
+		}
+		return 0, err
+	}
+	defer sieve.Close()
+	// The sieve covers the range from 0, the primes below the from flag are left out
+	primes := sieve.PrimesFrom(cfg.from)
+	if cfg.search == SEARCH_TWIN {
+		primes = twinPrimes(sieve, primes)
+	}
+
+	// For the random sources, P primes are sampled from the whole range, otherwise the first P are taken
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	if cfg.seeded {
+		rng = rand.New(rand.NewSource(cfg.seed))
+	}
+	var picked []int64
+	var walked int64
+	for prime := range primes {
+		// The walk of a range sieved to a file can take a while, reading it back from the disk
+		if walked%(1<<16) == 0 && ctx.Err() != nil {
+			return 0, nil
+		}
+		if cfg.source == SOURCE_SEQUENTIAL && len(picked) == cfg.numPrimes {
+			break
+		}
+		walked++
+		if len(picked) < cfg.numPrimes {
+			picked = append(picked, prime)
+		} else if i := rng.Int63n(walked); i < int64(len(picked)) {
+			// Reservoir sampling: the prime replaces a picked one with a chance of P in the primes walked so far,
+			// which leaves every prime of the range as likely to be picked as any other
+			picked[i] = prime
+		}
+	}
+	if cfg.source != SOURCE_SEQUENTIAL {
+		rng.Shuffle(len(picked), func(i, j int) { picked[i], picked[j] = picked[j], picked[i] })
+	}
+	// The sieve's workers are reported as one, covering the whole range, having found the primes it walked
+	worker, stats := rep.addWorker()
+	stats.Tested.Add(cfg.numRange)
+	stats.Found.Add(walked)
+	for _, prime := range picked {
+		out.prime(newResult(cfg, pipeline.Found[int64]{Value: prime, Worker: worker, At: time.Now()}))
+	}
+	return len(picked), nil
+}
+
+// runSegmented sieves the window of the range one segment at a time, the n workers sieving the segments in ascending order, and merges the primes
+// of their segments back into ascending order. The primes are then printed as they're found, rather than once the range is sieved, the first P
+// of the window as with the sequential source, and the run stops (cancelling the workers) once they're found, with no candidates sampled twice
+// nor any prime of the window missed. It returns how many were found
+func runSegmented(ctx context.Context, cfg config, rep *report, out output) (int, error) {
+	if cfg.predicate != PREDICATE_PRIME {
+		return 0, fmt.Errorf("the %s strategy only finds primes, it can't be combined with the %s predicate", STRATEGY_SEGMENTED, cfg.predicate)
+	}
+	if cfg.search == SEARCH_FACTOR || cfg.search == SEARCH_MERSENNE {
+		return 0, fmt.Errorf("the %s strategy only finds primes, it can't be combined with the %s mode", STRATEGY_SEGMENTED, cfg.search)
+	}
+	if cfg.wasmFilter != nil {
+		return 0, fmt.Errorf("the %s strategy only finds primes, it can't be combined with a filter module", STRATEGY_SEGMENTED)
+	}
+	if bigRange(cfg) {
+		return 0, fmt.Errorf("the %s strategy can't sieve a range beyond int64", STRATEGY_SEGMENTED)
+	}
+	if cfg.checkpoint != nil || cfg.wheel || cfg.rate > 0 || cfg.compositesPath != "" {
+		return 0, fmt.Errorf("the %s strategy sieves segments rather than testing candidates, it can't be checkpointed, rate limited, pre-filtered by the wheel or write the composites file", STRATEGY_SEGMENTED)
+	}
+	defer rep.running.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sieve := pipeline.SegmentedSieve{From: cfg.from, To: cfg.numRange, Twins: cfg.search == SEARCH_TWIN}
+	segmentStream := sieve.Segments(ctx, stageOptions(cfg, rep, "segments")...)
+	if cfg.gate != nil {
+		segmentStream = pipeline.Gated(ctx, segmentStream, cfg.gate, stageOptions(cfg, rep, "gate")...)
+	}
+	workerOpts, annotateOpts := stageOptions(cfg, rep, "worker"), stageOptions(cfg, rep, "annotate")
+	workers := make([]<-chan pipeline.Found[int64], max(cfg.numWorkers, 1))
+	for i := range workers {
+		index, stats := rep.addWorker()
+		workers[i] = pipeline.Annotate(ctx, sieve.Worker(ctx, segmentStream, stats, workerOpts...), index, stats, annotateOpts...)
+	}
+	// Each worker takes its segments in ascending order, so its primes come in ascending order too, and merging them keeps it.
+	// Each prime is sieved once, there are no duplicates to drop
+	ascending := func(a, b pipeline.Found[int64]) bool { return a.Value < b.Value }
+	mergedStream := pipeline.MergeSorted(ctx, workers, ascending, stageOptions(cfg, rep, "worker fan-in")...)
+	primeNumberStream := pipeline.Take(ctx, mergedStream, cfg.numPrimes, stageOptions(cfg, rep, "result")...)
+	// Sieving a segment can't fail, there's no error channel to watch
+	return collectResults(cancel, primeNumberStream, nil, func(found pipeline.Found[int64]) { out.prime(newResult(cfg, found)) })
+}
+
+// twinPrimes returns the lower prime of each twin pair among the sieve's primes, whose upper prime the sieve is asked about.
+// A pair whose upper prime is past the end of the sieved range isn't found
+func twinPrimes(sieve *pipeline.Sieve, primes iter.Seq[int64]) iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		for prime := range primes {
+			if sieve.IsPrime(prime+2) && !yield(prime) {
+				return
+			}
+		}
+	}
+}
+
+// workerTest returns the test workers keep candidates with: the primality test, rejecting negative numbers as ErrInvalidInput.
+// In mersenne mode the Lucas-Lehmer tests are tracked for the progress output, and stop once the context is done. The chaos flags' faults are injected into the test.
+// With Redis enabled, a candidate another instance claimed is skipped without testing, and a prime another instance found is dropped.
+// The Redis connection is closed once the context is done
+func workerTest(ctx context.Context, cfg config) (func(int64) (bool, error), error) {
+	isPrime := candidateTest(cfg)
+	test := func(num int64) (bool, error) { return isPrime(num), nil }
+	if cfg.search == SEARCH_MERSENNE && cfg.mersenneTests != nil {
+		test = cfg.mersenneTests.test(ctx)
+	}
+	if cfg.wasmFilter != nil {
+		test = cfg.wasmFilter.test(ctx)
+	}
+	test = withChaos(ctx, cfg, test)
+	if cfg.redisAddr == "" {
+		return func(num int64) (bool, error) {
+			if num < 0 {
+				return false, fmt.Errorf("%w: negative candidate %d", pipeline.ErrInvalidInput, num)
+			}
+			return test(num)
+		}, nil
+	}
+
+	dedup, err := newRedisDedup(ctx, cfg.redisAddr, cfg.redisPrefix, cfg.redisCache)
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(ctx, func() { dedup.close() })
+	return func(num int64) (bool, error) {
+		if num < 0 {
+			return false, fmt.Errorf("%w: negative candidate %d", pipeline.ErrInvalidInput, num)
+		}
+		if claimed, err := dedup.claim(ctx, num); err != nil || !claimed {
+			return false, err
+		}
+		if prime, err := test(num); err != nil || !prime {
+			return false, err
+		}
+		return dedup.report(ctx, num)
+	}, nil
+}
+
+// firstError stops the pipeline and waits for its stages to exit, returning the first error reported (nil if the stream ended cleanly)
+func firstError(cancel context.CancelFunc, errc <-chan error) error {
+	slog.Debug("stopping pipeline")
+	cancel()
+	if errc == nil {
+		return nil
+	}
+	for err := range errc {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	DEFAULT_TRACE_SAMPLE = 0.01 // Trace 1 in 100 candidates, tracing every one would swamp the exporter
+	TRACE_FLUSH_TIMEOUT  = 5 * time.Second
+	TRACER_NAME          = "github.com/pbangia/go-concurrency-sample"
+)
+
+// newTracerProvider returns a tracer provider that exports spans over OTLP/HTTP to the endpoint flag (such as http://localhost:4318/v1/traces),
+// sampling the given fraction of candidates
+func newTracerProvider(ctx context.Context, cfg config) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.otlpEndpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.traceSample)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "go-concurrency-sample"))),
+	), nil
+}
+
+// enveloped reports whether a run's candidates are wrapped in a pipeline.Item on their way through the stages (see runTraced),
+// to carry their trace span or their creation time for the latency flag
+func enveloped(cfg config) bool {
+	return cfg.otlpEndpoint != "" || cfg.latency
+}
+
+// runTraced is the stream strategy with every candidate wrapped in a pipeline.Item carrying its trace span and creation time.
+// Each candidate is the root of its own trace (linked to a span for the whole run), with a child span for its primality test and events as it passes
+// through the fan-in and result stages. The root span ends when the candidate is dropped as a composite or duplicate, or emitted as a result.
+// Without an OTLP endpoint the spans are no-ops, and the envelopes are only there to time each result for the latency flag
+func runTraced(ctx context.Context, cancel context.CancelFunc, cfg config, rep *report, out output) (int, error) {
+	if cfg.seeded || cfg.autoscale || cfg.batchSize > 1 {
+		return 0, fmt.Errorf("tracing and latency tracking can't be combined with a seed, autoscaling or batching")
+	}
+	getValue, err := valueSource(cfg)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.numProducers < 1 {
+		return 0, fmt.Errorf("need at least one producer, got %d", cfg.numProducers)
+	}
+
+	var tracer trace.Tracer = noop.NewTracerProvider().Tracer(TRACER_NAME)
+	if cfg.otlpEndpoint != "" {
+		provider, err := newTracerProvider(ctx, cfg)
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			// Flush the spans still queued. The run's context may be cancelled already, so a fresh one bounds the flush
+			flushCtx, cancelFlush := context.WithTimeout(context.Background(), TRACE_FLUSH_TIMEOUT)
+			defer cancelFlush()
+			provider.Shutdown(flushCtx)
+		}()
+		tracer = provider.Tracer(TRACER_NAME)
+	}
+	runCtx, runSpan := tracer.Start(ctx, "run", trace.WithAttributes(
+		attribute.Int("primes", cfg.numPrimes),
+		attribute.Int64("range", cfg.numRange),
+		attribute.Int64("from", cfg.from),
+		attribute.Int("workers", cfg.numWorkers),
+	))
+	defer runSpan.End()
+	runLink := trace.LinkFromContext(runCtx)
+
+	// Generate an input stream of candidates, each starting its trace as it is generated
+	countedValue := countValues(rep, cfg.maxCandidates, getValue)
+	getItem := func() (pipeline.Item[int64], error) {
+		num, err := countedValue()
+		if err != nil {
+			return pipeline.Item[int64]{}, err
+		}
+		itemCtx, _ := tracer.Start(ctx, "candidate", trace.WithNewRoot(), trace.WithLinks(runLink), trace.WithAttributes(attribute.Int64("candidate", num)))
+		return pipeline.NewItem(itemCtx, num), nil
+	}
+	var errcs []<-chan error
+	sourceCtx := sourceContext(ctx, cfg)
+	producers := make([]<-chan pipeline.Item[int64], cfg.numProducers)
+	for i := range producers {
+		var sourceErrs <-chan error
+		producers[i], sourceErrs = pipeline.CreateValueStream(sourceCtx, getItem, stageOptions(cfg, rep, "source")...)
+		errcs = append(errcs, sourceErrs)
+	}
+	itemStream := producers[0]
+	if len(producers) > 1 {
+		itemStream = pipeline.ReduceWorkers(ctx, producers, stageOptions(cfg, rep, "producer fan-in")...)
+	}
+	itemStream = throttle(ctx, cfg, rep, itemStream, 1)
+
+	// Workers test the wrapped value inside a child span. Composites end their trace here
+	isPrime := candidateTest(cfg)
+	keep := func(item pipeline.Item[int64]) (bool, error) {
+		if item.Value < 0 {
+			return false, fmt.Errorf("%w: negative candidate %d", pipeline.ErrInvalidInput, item.Value)
+		}
+		_, testSpan := tracer.Start(item.Ctx, "primality test")
+		prime := isPrime(item.Value)
+		testSpan.End()
+
+		span := trace.SpanFromContext(item.Ctx)
+		span.SetAttributes(attribute.Bool("prime", prime))
+		if !prime {
+			span.End()
+		}
+		return prime, nil
+	}
+	workers := make([]<-chan pipeline.Found[pipeline.Item[int64]], cfg.numWorkers)
+	workerOpts, annotateOpts := stageOptions(cfg, rep, "worker"), stageOptions(cfg, rep, "annotate")
+	for i := range workers {
+		index, stats := rep.addWorker()
+		worker, workerErrs := pipeline.FilterWorker(ctx, itemStream, keep, stats, workerOpts...)
+		workers[i] = pipeline.Annotate(ctx, worker, index, stats, annotateOpts...)
+		errcs = append(errcs, workerErrs)
+	}
+
+	// Fan in the workers, then drop duplicates, ending their traces
+	reducedStream := pipeline.Map(ctx, pipeline.ReduceWorkers(ctx, workers, fanInOptions(cfg, rep)...), func(found pipeline.Found[pipeline.Item[int64]]) pipeline.Found[pipeline.Item[int64]] {
+		trace.SpanFromContext(found.Value.Ctx).AddEvent("fanned in")
+		return found
+	}, stageOptions(cfg, rep, "trace events")...)
+	endDuplicate := pipeline.WithDiscard(func(found any) {
+		span := trace.SpanFromContext(found.(pipeline.Found[pipeline.Item[int64]]).Value.Ctx)
+		span.SetAttributes(attribute.Bool("duplicate", true))
+		span.End()
+	})
+	distinctStream := pipeline.DistinctBy(ctx, reducedStream, func(found pipeline.Found[pipeline.Item[int64]]) int64 { return found.Value.Value }, cfg.dedupLimit, append(distinctOptions(cfg, rep), endDuplicate)...)
+	resultStream := pipeline.Take(ctx, distinctStream, cfg.numPrimes, stageOptions(cfg, rep, "result")...)
+
+	return collectResults(cancel, resultStream, pipeline.MergeErrors(errcs...), func(found pipeline.Found[pipeline.Item[int64]]) {
+		out.prime(newResult(cfg, pipeline.Found[int64]{Value: found.Value.Value, Worker: found.Worker, At: found.At, Attempts: found.Attempts}))
+		rep.latency.Observe(time.Since(found.Value.Created))
+		span := trace.SpanFromContext(found.Value.Ctx)
+		span.AddEvent("emitted")
+		span.End()
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// errVerify is returned by runVerify when a number of the results file failed the test or was found twice
+var errVerify = errors.New("results file failed verification")
+
+// runVerify re-checks every number of a results file with the test of the predicate and mode flags, fanned out to n workers, the candidates being the numbers of the file.
+// The file is the out flag's (one result per line) or, with a .csv extension, the csv flag's. It prints the numbers failing the test and those found twice,
+// then how many were checked, and returns errVerify if there was any
+func runVerify(cfg config, path string) error {
+	if cfg.search == SEARCH_FACTOR {
+		return fmt.Errorf("results of the %s mode can't be verified", SEARCH_FACTOR)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// The pipeline's context is cancelled once the file is checked, ctx is only done if a signal arrived
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening results file: %w", err)
+	}
+	defer file.Close()
+	results := newResultsReader(file, strings.EqualFold(filepath.Ext(path), ".csv"))
+
+	// The workers keep the numbers failing the test
+	isValid := candidateTest(cfg)
+	fail := func(num int64) (bool, error) {
+		if num < 0 {
+			return true, nil
+		}
+		return !isValid(num), nil
+	}
+	valueStream, sourceErrs := pipeline.CreateValueStream(runCtx, results.next)
+	errcs := []<-chan error{sourceErrs}
+	workers := make([]<-chan int64, max(cfg.numWorkers, 1))
+	for i := range workers {
+		var errc <-chan error
+		workers[i], errc = pipeline.FilterWorker(runCtx, valueStream, fail, nil)
+		errcs = append(errcs, errc)
+	}
+	var failed []int64
+	if _, err := collectResults(cancel, pipeline.ReduceWorkers(runCtx, workers), pipeline.MergeErrors(errcs...), func(num int64) { failed = append(failed, num) }); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+
+	slices.Sort(failed)
+	for _, num := range failed {
+		fmt.Printf("Not %s: %d\n", strings.TrimSuffix(searchNoun(cfg), "s"), num)
+	}
+	duplicates := results.duplicates()
+	for _, num := range duplicates {
+		fmt.Printf("Found twice: %d\n", num)
+	}
+	fmt.Printf("Verified %d numbers of %s: %d failed, %d found twice\n", results.count(), path, len(failed), len(duplicates))
+	if len(failed) > 0 || len(duplicates) > 0 {
+		return errVerify
+	}
+	return nil
+}
+
+// resultsReader reads the numbers of a results file and remembers those it read more than once. It's safe for concurrent use
+type resultsReader struct {
+	mu    sync.Mutex
+	csv   *csv.Reader    // Set for a CSV file, whose first column is read
+	lines *bufio.Scanner // Set for a file of results one per line
+	line  int
+	seen  map[int64]int // Times each number was read
+}
+
+func newResultsReader(r io.Reader, isCSV bool) *resultsReader {
+	rr := &resultsReader{seen: make(map[int64]int)}
+	if isCSV {
+		rr.csv = csv.NewReader(r)
+		rr.csv.FieldsPerRecord = -1
+	} else {
+		rr.lines = bufio.NewScanner(r)
+	}
+	return rr
+}
+
+// next returns the next number of the file, or pipeline.ErrExhausted at its end
+func (rr *resultsReader) next() (int64, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	var num int64
+	var err error
+	if rr.csv != nil {
+		num, err = rr.nextRow()
+	} else {
+		num, err = rr.nextLine()
+	}
+	if err == nil {
+		rr.seen[num]++
+	}
+	return num, err
+}
+
+// nextRow reads the number in the first column of the next row of a CSV file, skipping its header
+func (rr *resultsReader) nextRow() (int64, error) {
+	for {
+		row, err := rr.csv.Read()
+		if err == io.EOF {
+			return 0, pipeline.ErrExhausted
+		}
+		if err != nil {
+			return 0, fmt.Errorf("reading results file: %w", err)
+		}
+		rr.line++
+		if rr.line == 1 {
+			continue
+		}
+		num, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: row %d: %q is not an int64", pipeline.ErrInvalidInput, rr.line, row[0])
+		}
+		return num, nil
+	}
+}
+
+// nextLine reads the number of the next line of a file written by the out flag, one result per line: the first number of the line,
+// such as the lower prime of a twin pair, or the exponent of a Mersenne prime written as 2^p-1. Blank lines are skipped
+func (rr *resultsReader) nextLine() (int64, error) {
+	for rr.lines.Scan() {
+		rr.line++
+		fields := strings.Fields(rr.lines.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		text := strings.TrimSuffix(strings.TrimPrefix(fields[0], "2^"), "-1")
+		num, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: line %d: %q is not an int64", pipeline.ErrInvalidInput, rr.line, fields[0])
+		}
+		return num, nil
+	}
+	if err := rr.lines.Err(); err != nil {
+		return 0, fmt.Errorf("reading results file: %w", err)
+	}
+	return 0, pipeline.ErrExhausted
+}
+
+// count returns the numbers read so far
+func (rr *resultsReader) count() int {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	total := 0
+	for _, times := range rr.seen {
+		total += times
+	}
+	return total
+}
+
+// duplicates returns the numbers read more than once, in ascending order
+func (rr *resultsReader) duplicates() []int64 {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	var nums []int64
+	for num, times := range rr.seen {
+		if times > 1 {
+			nums = append(nums, num)
+		}
+	}
+	slices.Sort(nums)
+	return nums
+}
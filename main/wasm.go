@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Filter modules, see wasmFilter
+const (
+	WASM_ACCEPT       = "accept" // Function a filter module exports, taking a candidate as an i64 and returning a non-zero i32 to keep it
+	WASM_MEMORY_PAGES = 256      // Most linear memory of a filter module, in 64KiB pages (16MiB)
+	WASM_MAX_IDLE     = 64       // Most instances of a filter module kept for reuse, those beyond it are closed once their test is done
+)
+
+// wasmFilter runs the accept function of the filter-wasm flag's WebAssembly module in place of the predicate flag's test.
+// A module instance isn't safe for concurrent use, so each call takes an idle instance or starts a new one, and puts it back once done:
+// there are as many instances as tests run at once, one per worker. The module is sandboxed by wazero, it only gets the WASI imports,
+// without a filesystem, so it can't reach outside of the calls it's made
+type wasmFilter struct {
+	path     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	config   wazero.ModuleConfig
+	idle     chan api.Module // Instances not running a test
+}
+
+// loadWasmFilter compiles the module at path, checking that it exports accept(i64) -> i32
+func loadWasmFilter(path string) (*wasmFilter, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading filter module: %w", err)
+	}
+	ctx := context.Background()
+	// Closing modules when the context of a call is done stops a test stuck in a loop once the run is cancelled
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithMemoryLimitPages(WASM_MEMORY_PAGES))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("instantiating WASI: %w", err)
+	}
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("compiling filter module %s: %w", path, err)
+	}
+	accept, ok := compiled.ExportedFunctions()[WASM_ACCEPT]
+	if !ok || !slices.Equal(accept.ParamTypes(), []api.ValueType{api.ValueTypeI64}) || !slices.Equal(accept.ResultTypes(), []api.ValueType{api.ValueTypeI32}) {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("filter module %s must export %s(i64) -> i32", path, WASM_ACCEPT)
+	}
+	return &wasmFilter{
+		path:     path,
+		runtime:  rt,
+		compiled: compiled,
+		// Instances are anonymous, so there can be several of the module. _initialize sets up a WASI reactor (such as a TinyGo or Rust module), it's skipped if there's none
+		config: wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"),
+		idle:   make(chan api.Module, WASM_MAX_IDLE),
+	}, nil
+}
+
+// test returns the workers' test of a candidate, calling the module's accept function. It stops with an error if the module traps,
+// or with the context's error once the context is cancelled
+func (f *wasmFilter) test(ctx context.Context) func(int64) (bool, error) {
+	return func(num int64) (bool, error) {
+		var mod api.Module
+		select {
+		case mod = <-f.idle:
+		default:
+			var err error
+			if mod, err = f.runtime.InstantiateModule(ctx, f.compiled, f.config); err != nil {
+				return false, fmt.Errorf("instantiating filter module %s: %w", f.path, err)
+			}
+		}
+		results, err := mod.ExportedFunction(WASM_ACCEPT).Call(ctx, api.EncodeI64(num))
+		if err != nil {
+			// A module that trapped may be left in any state, it isn't reused
+			mod.Close(ctx)
+			if ctx.Err() != nil {
+				return false, context.Cause(ctx)
+			}
+			return false, fmt.Errorf("filter module %s on %d: %w", f.path, num, err)
+		}
+		select {
+		case f.idle <- mod:
+		default:
+			mod.Close(ctx)
+		}
+		return api.DecodeI32(results[0]) != 0, nil
+	}
+}
+
+// accept is the module's test for the commands whose tests can't fail (see candidateTest). A candidate the module traps on is logged and left out
+func (f *wasmFilter) accept(num int64) bool {
+	ok, err := f.test(context.Background())(num)
+	if err != nil {
+		slog.Warn("filter module failed, leaving the candidate out", "num", num, "err", err)
+	}
+	return ok
+}
+
+// close releases the runtime and every instance of the module
+func (f *wasmFilter) close() {
+	f.runtime.Close(context.Background())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"runtime/pprof"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// workerWatchdog logs a warning when a worker has made no progress for longer than the threshold: every test a worker finishes is a heartbeat,
+// so a worker still on the same test is stuck on its item (such as a test that never returns), which is logged with the item.
+// A worker waiting for input isn't stuck, that's the stall watcher's to report. In mersenne mode each step of a Lucas-Lehmer test is a heartbeat too,
+// so a test of a large exponent is only reported once its steps stop. A stuck test is reported once, and dump adds a dump of every goroutine's stack.
+// Only the workers testing candidates in this process have heartbeats, not the remote ones of a coordinator
+type workerWatchdog struct {
+	rep       *report
+	threshold time.Duration
+	dump      bool
+	mersenne  *mersenneTests // Tests in flight in mersenne mode, nil otherwise
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newWorkerWatchdog(rep *report, threshold time.Duration, dump bool) *workerWatchdog {
+	return &workerWatchdog{rep: rep, threshold: threshold, dump: dump, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (w *workerWatchdog) start(cfg config) {
+	w.mersenne = cfg.mersenneTests
+	go w.watch()
+}
+
+func (w *workerWatchdog) prime(found pipeline.Found[result]) {}
+
+func (w *workerWatchdog) finish(sum summary) error {
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+// watch checks the workers twice per threshold until finish is called.
+// Workers started since the last check (such as by autoscaling) are watched from then on, their first test is reported without its item
+func (w *workerWatchdog) watch() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.threshold / 2)
+	defer ticker.Stop()
+	reported := make(map[*pipeline.Stats]time.Time) // When the test reported already started, by worker
+	for {
+		var now time.Time
+		select {
+		case <-w.stop:
+			return
+		case now = <-ticker.C:
+		}
+		stuck := false
+		for i, stats := range w.rep.workerStats() {
+			stats.Watch()
+			item, since, ok := stats.Testing()
+			if !ok || reported[stats].Equal(since) {
+				continue
+			}
+			progress := since
+			if exponent, isExponent := item.(int64); isExponent && w.mersenne != nil {
+				if step, stepped := w.mersenne.lastStep(exponent); stepped && step.After(progress) {
+					progress = step
+				}
+			}
+			if now.Sub(progress) > w.threshold {
+				attrs := []any{"worker", i, "testing", now.Sub(since).Round(time.Millisecond), "no_progress", now.Sub(progress).Round(time.Millisecond)}
+				if item != nil {
+					attrs = append(attrs, "item", item)
+				}
+				slog.Warn("worker stuck", attrs...)
+				reported[stats] = since
+				stuck = true
+			}
+		}
+		if stuck && w.dump {
+			pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webAssets is the dashboard served by the job API at /, embedded so the binary needs no files next to it
+//
+//go:embed web
+var webAssets embed.FS
+
+// dashboardHandler serves the dashboard's assets. The page polls GET /jobs for the jobs and their workers' counters, charts each worker's test rate
+// from the difference between two polls, and cancels a job with DELETE /jobs/{id}
+func dashboardHandler() http.Handler {
+	assets, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		panic(err) // The directory is embedded, it can only be missing if the go:embed line is changed
+	}
+	return http.FileServerFS(assets)
+}
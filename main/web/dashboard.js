@@ -0,0 +1,117 @@
+// Polls GET /jobs every second and draws each job with a chart of its workers' test rates over the last minute.
+// A rate is the difference between the candidates a worker had tested at two polls
+"use strict";
+
+const POLL_INTERVAL = 1000; // Milliseconds between polls of GET /jobs
+const HISTORY = 60;         // Polls kept in a job's chart
+const COLORS = ["#0969da", "#1a7f37", "#cf222e", "#9a6700", "#8250df", "#bf3989", "#1b7c83", "#57606a"];
+
+const jobs = new Map(); // Job ID to {el, last, history}
+
+async function poll() {
+  try {
+    const resp = await fetch("/jobs");
+    if (resp.ok) {
+      update(await resp.json(), performance.now());
+    }
+  } finally {
+    setTimeout(poll, POLL_INTERVAL);
+  }
+}
+
+function update(summaries, now) {
+  const list = document.getElementById("jobs");
+  if (summaries.length > 0 && jobs.size === 0) {
+    list.replaceChildren();
+  }
+  // Newest first
+  for (const sum of summaries.slice().reverse()) {
+    let job = jobs.get(sum.id);
+    if (!job) {
+      job = {el: newJobElement(sum.id), last: null, history: []};
+      jobs.set(sum.id, job);
+      list.prepend(job.el);
+    }
+    const workers = sum.workers || [];
+    const tested = workers.map(w => w.tested);
+    if (job.last && (sum.status === "running" || sum.status === "paused")) {
+      const seconds = (now - job.last.at) / 1000;
+      job.history.push(tested.map((t, i) => (t - (job.last.tested[i] || 0)) / seconds));
+      job.history = job.history.slice(-HISTORY);
+    }
+    job.last = {at: now, tested};
+    drawJob(job, sum);
+  }
+}
+
+function newJobElement(id) {
+  const el = document.createElement("section");
+  el.className = "job";
+  el.innerHTML = `<header><h2>Job ${id}</h2><span class="status"></span><span class="progress"></span>
+    <button type="button" class="pause"></button><button type="button" class="cancel">Cancel</button></header><div class="error"></div><canvas></canvas><div class="legend"></div>`;
+  el.querySelector(".pause").addEventListener("click", event =>
+    fetch(`/jobs/${id}/${event.target.textContent === "Pause" ? "pause" : "resume"}`, {method: "POST"}));
+  el.querySelector(".cancel").addEventListener("click", () => fetch(`/jobs/${id}`, {method: "DELETE"}));
+  return el;
+}
+
+function drawJob(job, sum) {
+  const el = job.el;
+  const status = el.querySelector(".status");
+  status.textContent = sum.status;
+  status.className = `status ${sum.status}`;
+  el.querySelector(".progress").textContent =
+    `${sum.found} of ${sum.requested} primes, ${sum.tested} tested, ${sum.duration_seconds.toFixed(1)}s`;
+  const live = sum.status === "running" || sum.status === "paused";
+  const pause = el.querySelector(".pause");
+  pause.hidden = !live;
+  pause.textContent = sum.status === "paused" ? "Resume" : "Pause";
+  // A queued job can be cancelled but not paused
+  el.querySelector(".cancel").hidden = !live && sum.status !== "queued";
+  el.querySelector(".error").textContent = sum.error || "";
+
+  const rates = job.history.at(-1) || [];
+  el.querySelector(".legend").replaceChildren(...(sum.workers || []).map((w, i) => {
+    const span = document.createElement("span");
+    span.style.color = COLORS[i % COLORS.length];
+    span.textContent = `worker ${w.worker}: ${Math.round(rates[i] || 0)}/s`;
+    return span;
+  }));
+  drawChart(el.querySelector("canvas"), job.history);
+}
+
+// drawChart draws one line per worker, scaled to the highest rate in the history
+function drawChart(canvas, history) {
+  canvas.width = canvas.clientWidth * devicePixelRatio;
+  canvas.height = canvas.clientHeight * devicePixelRatio;
+  const ctx = canvas.getContext("2d");
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  const top = Math.max(1, ...history.flat());
+  const x = i => i / (HISTORY - 1) * canvas.width;
+  const y = rate => canvas.height - rate / top * (canvas.height - 2);
+  ctx.lineWidth = 2 * devicePixelRatio;
+  const workers = Math.max(0, ...history.map(rates => rates.length));
+  for (let w = 0; w < workers; w++) {
+    ctx.strokeStyle = COLORS[w % COLORS.length];
+    ctx.beginPath();
+    history.forEach((rates, i) => {
+      const point = [x(i + HISTORY - history.length), y(rates[w] || 0)];
+      i === 0 ? ctx.moveTo(...point) : ctx.lineTo(...point);
+    });
+    ctx.stroke();
+  }
+}
+
+document.getElementById("start").addEventListener("submit", async event => {
+  event.preventDefault();
+  const body = {};
+  for (const [name, value] of new FormData(event.target)) {
+    if (value !== "") {
+      body[name] = Number(value);
+    }
+  }
+  const resp = await fetch("/jobs", {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify(body)});
+  document.getElementById("start-error").textContent = resp.ok ? "" : await resp.text();
+});
+
+poll();
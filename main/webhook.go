@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const (
+	WEBHOOK_TIMEOUT          = 5 * time.Second
+	DEFAULT_BREAKER_FAILURES = 5
+	DEFAULT_BREAKER_COOLDOWN = 10 * time.Second
+	DEFAULT_BREAKER_BUFFER   = 1000
+)
+
+// webhookOutput posts each result to the webhook flag's URL as it's found, as the JSON object of a prime line of the jsonl output.
+// The posts go through a circuit breaker (see pipeline.SinkWithBreaker), so an endpoint that's down doesn't hold the run up:
+// once the breaker-failures flag's posts in a row have failed, the results are held for the breaker-cooldown flag's time (or dropped, see breaker-buffer)
+// before the endpoint is tried again. Results that are never posted are logged at the end of the run rather than failing it
+type webhookOutput struct {
+	ctx     context.Context
+	client  *http.Client
+	results chan pipeline.Found[result]
+	errc    <-chan error
+}
+
+// newWebhookOutput starts the sink posting to the webhook flag's URL, recording its breaker in the report for the metrics endpoint
+func newWebhookOutput(ctx context.Context, cfg config, rep *report) *webhookOutput {
+	breaker := pipeline.NewBreaker(cfg.breakerFailures, cfg.breakerCooldown, cfg.breakerBuffer)
+	rep.breaker = breaker
+	client := &http.Client{Timeout: WEBHOOK_TIMEOUT}
+	post := func(found pipeline.Found[result]) error {
+		body, err := json.Marshal(struct {
+			Type    string    `json:"type"`
+			Prime   result    `json:"prime"`
+			Worker  int       `json:"worker"`
+			FoundAt time.Time `json:"found_at"`
+		}{"prime", found.Value, found.Worker, found.At})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook responded %s", resp.Status)
+		}
+		return nil
+	}
+	results := make(chan pipeline.Found[result], cfg.buffer)
+	return &webhookOutput{ctx: ctx, client: client, results: results, errc: pipeline.SinkWithBreaker(ctx, results, post, breaker)}
+}
+
+func (o *webhookOutput) start(cfg config) {}
+
+func (o *webhookOutput) prime(found pipeline.Found[result]) {
+	select {
+	case <-o.ctx.Done():
+	case o.results <- found:
+	}
+}
+
+// finish ends the sink's input and waits for the results held to get their last try, then closes the connection kept to the endpoint
+func (o *webhookOutput) finish(sum summary) error {
+	close(o.results)
+	if err := <-o.errc; err != nil {
+		slog.Warn("results not posted to the webhook", "err", err)
+	}
+	o.client.CloseIdleConnections()
+	return nil
+}
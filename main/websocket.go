@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+const (
+	STREAM_PROGRESS_INTERVAL = time.Second
+	STREAM_WRITE_TIMEOUT     = 10 * time.Second // A client that can't take a frame for this long is disconnected
+)
+
+// Frames pushed to a client streaming a job, told apart by their type field
+type primeFrame struct {
+	Type    string    `json:"type"`
+	Prime   int64     `json:"prime"`
+	Worker  int       `json:"worker"`
+	FoundAt time.Time `json:"found_at"`
+}
+
+type progressFrame struct {
+	Type      string `json:"type"`
+	Found     int    `json:"found"`
+	Requested int    `json:"requested"`
+	Tested    int64  `json:"tested"`
+}
+
+type statusFrame struct {
+	Type string `json:"type"`
+	jobStatus
+}
+
+// streamJob handles GET /jobs/{id}/stream, upgrading to a WebSocket that pushes each prime the job finds (starting with those found already),
+// a progress frame every STREAM_PROGRESS_INTERVAL, and the job's status once it finishes.
+// The stream reads the primes the job has recorded rather than sitting in the pipeline, so a slow client falls behind (and catches up in bursts) without stalling the job
+func (s *jobServer) streamJob(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return // Accept has written the error response
+	}
+	defer conn.CloseNow()
+	// Nothing is read from the client, but reading handles its pings and close, cancelling ctx when it goes away
+	ctx := conn.CloseRead(r.Context())
+
+	ticker := time.NewTicker(STREAM_PROGRESS_INTERVAL)
+	defer ticker.Stop()
+	progress := func() error {
+		st := j.status()
+		return writeFrame(ctx, conn, progressFrame{"progress", st.Found, st.Requested, st.Tested})
+	}
+	sent := 0
+	for {
+		found, finished, updated := j.since(sent)
+		for _, f := range found {
+			err := writeFrame(ctx, conn, primeFrame{"prime", f.Value.Value, f.Worker, f.At})
+			// A client catching up on a backlog still gets its progress frames
+			select {
+			case <-ticker.C:
+				err = errors.Join(err, progress())
+			default:
+			}
+			if err != nil {
+				slog.Debug("job stream closed", "job", j.id, "err", err)
+				return
+			}
+		}
+		sent += len(found)
+		if finished {
+			st := j.status()
+			if err := writeFrame(ctx, conn, statusFrame{"status", st}); err == nil {
+				conn.Close(websocket.StatusNormalClosure, "job "+st.Status)
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-updated:
+		case <-ticker.C:
+			if err := progress(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeFrame sends a frame to the client, giving up after STREAM_WRITE_TIMEOUT
+func writeFrame(ctx context.Context, conn *websocket.Conn, frame any) error {
+	ctx, cancel := context.WithTimeout(ctx, STREAM_WRITE_TIMEOUT)
+	defer cancel()
+	return wsjson.Write(ctx, conn, frame)
+}
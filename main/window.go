@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+const DEFAULT_WINDOW = 10 * time.Second
+
+// windowOutput logs how many results a continuous run found in each window of the window flag's length, such as the primes found in the last 10s,
+// which shows whether the rate holds up over a long run where the totals of the summary smooth it out.
+// The results are put on a stream grouped into windows by pipeline.WindowByTime, so a window with none is logged too.
+// Logs go to stderr, so they don't mix with the results on stdout
+type windowOutput struct {
+	window       time.Duration
+	resultStream chan pipeline.Found[result]
+	done         chan struct{}
+}
+
+func newWindowOutput(window time.Duration) *windowOutput {
+	return &windowOutput{window: window, resultStream: make(chan pipeline.Found[result]), done: make(chan struct{})}
+}
+
+func (o *windowOutput) start(cfg config) {
+	windows := pipeline.WindowByTime(context.Background(), o.resultStream, o.window)
+	go func() {
+		defer close(o.done)
+		last := time.Now()
+		for window := range windows {
+			// The last window is cut short by the end of the run
+			now := time.Now()
+			elapsed := now.Sub(last)
+			last = now
+			slog.Info("found in window", "found", len(window), "window", elapsed.Round(time.Millisecond), "rate", math.Round(float64(len(window))/elapsed.Seconds()*10)/10)
+		}
+	}()
+}
+
+func (o *windowOutput) prime(found pipeline.Found[result]) {
+	o.resultStream <- found
+}
+
+// finish ends the window in progress and waits for it to be logged
+func (o *windowOutput) finish(sum summary) error {
+	close(o.resultStream)
+	<-o.done
+	return nil
+}
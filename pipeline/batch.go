@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Batch groups the items of a stream into slices of up to size items, so the next stage pays for one channel hand-off per batch instead of per item.
+// A partial batch is sent once maxWait has passed since its first item (a maxWait of 0 always waits for a full batch), or when the input stream closes
+func Batch[T any](ctx context.Context, valueStream <-chan T, size int, maxWait time.Duration, opts ...Option) <-chan []T {
+	o := applyOptions(opts)
+	batchStream := make(chan []T, o.buffer)
+	size = max(size, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "batch", "size", size)()
+		defer close(batchStream)
+		var batch []T
+		var timer Timer
+		var timeout <-chan time.Time // Only set while a partial batch is waiting
+
+		// Sends the current batch downstream and starts a new one. Returns false if the context was cancelled
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+			if !send(ctx, o, batchStream, batch) {
+				return false
+			}
+			batch = nil
+			return true
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-valueStream:
+				if !ok {
+					if len(batch) > 0 {
+						flush()
+					}
+					return
+				}
+				if batch == nil {
+					batch = make([]T, 0, size)
+					if maxWait > 0 {
+						timer = o.clock.NewTimer(maxWait)
+						timeout = timer.C()
+					}
+				}
+				batch = append(batch, item)
+				if len(batch) == size && !flush() {
+					return
+				}
+			case <-timeout:
+				if !flush() {
+					return
+				}
+			}
+		}
+	})
+	return batchStream
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	cryptorand "crypto/rand"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+)
+
+// BigPool recycles the *big.Int candidates of a range beyond int64, so drawing and testing millions of them doesn't allocate a number each.
+// The numbers a worker rejects go back to the pool, while the primes it keeps belong to the stages after it
+type BigPool struct {
+	pool sync.Pool
+}
+
+func NewBigPool() *BigPool {
+	return &BigPool{pool: sync.Pool{New: func() any { return new(big.Int) }}}
+}
+
+// Get returns a number from the pool, or a new one if it's empty. Its value is whatever it was put back with
+func (p *BigPool) Get() *big.Int {
+	return p.pool.Get().(*big.Int)
+}
+
+// Put returns a number to the pool. The caller mustn't use it afterwards
+func (p *BigPool) Put(num *big.Int) {
+	p.pool.Put(num)
+}
+
+// BigRandVal returns a function, which returns a random number from low (included) to high (excluded) taken from the pool,
+// the *big.Int counterpart of RandValBetween. It's safe for concurrent use. An empty range is reported as ErrInvalidInput
+func BigRandVal(low, high *big.Int, pool *BigPool) func() (*big.Int, error) {
+	size := new(big.Int).Sub(high, low)
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(rand.Int63()))
+	return func() (*big.Int, error) {
+		if size.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: range %v-%v is empty", ErrInvalidInput, low, high)
+		}
+		num := pool.Get()
+		mu.Lock()
+		num.Rand(rng, size)
+		mu.Unlock()
+		return num.Add(num, low), nil
+	}
+}
+
+// BigCryptoRandVal is BigRandVal drawing from crypto/rand instead of math/rand. Failing to read from the entropy source is returned as an error
+func BigCryptoRandVal(low, high *big.Int, pool *BigPool) func() (*big.Int, error) {
+	size := new(big.Int).Sub(high, low)
+	return func() (*big.Int, error) {
+		if size.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: range %v-%v is empty", ErrInvalidInput, low, high)
+		}
+		// crypto/rand allocates the number it returns, so only the composites put back to the pool are reused
+		val, err := cryptorand.Int(cryptorand.Reader, size)
+		if err != nil {
+			return nil, fmt.Errorf("reading crypto/rand: %w", err)
+		}
+		return pool.Get().Add(val, low), nil
+	}
+}
+
+// BigSequentialVal returns a function, which returns the numbers from low to high in order taken from the pool, and then ErrExhausted.
+// The function is safe to share between several producers, each number is only returned once
+func BigSequentialVal(low, high *big.Int, pool *BigPool) func() (*big.Int, error) {
+	var mu sync.Mutex
+	next := new(big.Int).Set(low)
+	one := big.NewInt(1)
+	return func() (*big.Int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if next.Cmp(high) >= 0 {
+			return nil, ErrExhausted
+		}
+		num := pool.Get().Set(next)
+		next.Add(next, one)
+		return num, nil
+	}
+}
+
+// BigPrimeFilter returns the test of a FilterWorker reading *big.Int candidates: big.Int.ProbablyPrime with the given Miller-Rabin rounds
+// (as ProbablyPrime does for int64). Candidates that aren't prime are put back to the pool once tested, and negative ones are rejected as ErrInvalidInput
+func BigPrimeFilter(rounds int, pool *BigPool) func(*big.Int) (bool, error) {
+	return func(num *big.Int) (bool, error) {
+		if num.Sign() < 0 {
+			return false, fmt.Errorf("%w: negative candidate %v", ErrInvalidInput, num)
+		}
+		if !num.ProbablyPrime(rounds) {
+			pool.Put(num)
+			return false, nil
+		}
+		return true, nil
+	}
+}
@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerState is the state of a Breaker
+type BreakerState int32
+
+const (
+	BreakerClosed   BreakerState = iota // Items are written to the sink
+	BreakerOpen                         // The sink failed too many times in a row, items are held or dropped until the cool-down is over
+	BreakerHalfOpen                     // The cool-down is over, the next write tells whether the sink has recovered
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int32(s))
+}
+
+// Breaker is the circuit breaker of a SinkWithBreaker stage: once failures writes in a row have failed it opens, and the items reaching the sink
+// are held (up to buffer of them, the oldest dropped past that) or dropped if buffer is 0 rather than written, so a dead sink doesn't hold up the pipeline.
+// After cooldown it half-opens and tries one write: if it succeeds the breaker closes and the items held are written, otherwise it opens again.
+// Its state and counters are safe to read while the stage is running, such as for metrics
+type Breaker struct {
+	failures int
+	cooldown time.Duration
+	buffer   int
+
+	state   atomic.Int32
+	Trips   atomic.Int64 // Times the breaker opened
+	Failed  atomic.Int64 // Writes that failed
+	Dropped atomic.Int64 // Items never written, dropped while the breaker was open or because they were still held once the sink stopped
+}
+
+// NewBreaker returns a closed breaker opening after failures writes in a row have failed (at least 1), for cooldown
+func NewBreaker(failures int, cooldown time.Duration, buffer int) *Breaker {
+	return &Breaker{failures: max(failures, 1), cooldown: cooldown, buffer: max(buffer, 0)}
+}
+
+// State returns the breaker's current state
+func (b *Breaker) State() BreakerState {
+	return BreakerState(b.state.Load())
+}
+
+// SinkWithBreaker is a terminal stage calling write with each item of a stream, such as to post it to a remote service, behind the breaker.
+// A failed write's item is held like those arriving while the breaker is open, so it's written again once the sink recovers.
+// Once the input stream closes, items still held get one more try after the cool-down. The returned channel is closed once the sink has finished,
+// carrying an error with the last write error if any item was dropped. Dropped items are passed to the WithDiscard function
+func SinkWithBreaker[T any](ctx context.Context, valueStream <-chan T, write func(T) error, breaker *Breaker, opts ...Option) <-chan error {
+	o := applyOptions(opts)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "breaker sink", "failures", breaker.failures, "cooldown", breaker.cooldown)()
+		defer close(errc)
+		s := &breakerSink[T]{breaker: breaker, write: write, o: o}
+		s.run(ctx, valueStream)
+		if dropped := breaker.Dropped.Load(); dropped > 0 && s.lastErr != nil {
+			errc <- fmt.Errorf("%d items dropped by the circuit breaker, last write failed: %w", dropped, s.lastErr)
+		}
+	})
+	return errc
+}
+
+// breakerSink is the state of a SinkWithBreaker stage, only used by its goroutine
+type breakerSink[T any] struct {
+	breaker *Breaker
+	write   func(T) error
+	o       stageOptions
+	held    []T
+	fails   int   // Writes failed in a row
+	lastErr error // Of the last failed write
+	reopen  Timer // Running while the breaker is open
+}
+
+func (s *breakerSink[T]) run(ctx context.Context, valueStream <-chan T) {
+	defer func() {
+		if s.reopen != nil {
+			s.reopen.Stop()
+		}
+		for _, item := range s.held {
+			s.drop(item)
+		}
+	}()
+	for {
+		var cooledDown <-chan time.Time
+		if s.reopen != nil {
+			cooledDown = s.reopen.C()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-cooledDown:
+			s.halfOpen()
+		case item, ok := <-valueStream:
+			if !ok {
+				s.drain(ctx)
+				return
+			}
+			if s.breaker.State() == BreakerOpen {
+				s.hold(item)
+			} else {
+				s.try(item)
+			}
+		}
+	}
+}
+
+// try queues an item behind those held, so the items are written in the order they reached the sink, and writes them
+func (s *breakerSink[T]) try(item T) {
+	s.held = append(s.held, item)
+	s.flush()
+}
+
+// flush writes the items held, oldest first, until one fails. The failed item stays held, and is written again with the next item to arrive
+// or once the breaker half-opens, so a failing sink isn't retried in a loop
+func (s *breakerSink[T]) flush() {
+	for len(s.held) > 0 {
+		if err := s.write(s.held[0]); err != nil {
+			s.failed(err)
+			break
+		}
+		s.held = s.held[1:]
+		s.fails = 0
+		if s.breaker.State() == BreakerHalfOpen {
+			s.setState(BreakerClosed)
+			s.o.logger.Info("circuit breaker closed, the sink has recovered", "held", len(s.held))
+		}
+	}
+	s.trim()
+}
+
+// failed counts a failed write, opening the breaker after too many in a row or when the write was the half-open breaker's try
+func (s *breakerSink[T]) failed(err error) {
+	s.breaker.Failed.Add(1)
+	s.fails++
+	s.lastErr = err
+	if s.fails >= s.breaker.failures || s.breaker.State() == BreakerHalfOpen {
+		s.setState(BreakerOpen)
+		s.breaker.Trips.Add(1)
+		s.reopen = s.o.clock.NewTimer(s.breaker.cooldown)
+		s.o.logger.Warn("circuit breaker opened", "err", err, "failures", s.fails, "cooldown", s.breaker.cooldown)
+	}
+}
+
+// halfOpen ends the cool-down, trying the oldest item held if there is one. Otherwise the next item to arrive is the try
+func (s *breakerSink[T]) halfOpen() {
+	s.reopen = nil
+	s.setState(BreakerHalfOpen)
+	s.flush()
+}
+
+// drain gives the items still held once the input is closed another try, after the cool-down if the breaker is open,
+// rather than waiting on a dead sink for good. Those that still fail are dropped
+func (s *breakerSink[T]) drain(ctx context.Context) {
+	if len(s.held) == 0 {
+		return
+	}
+	if s.reopen != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reopen.C():
+		}
+		s.reopen = nil
+		s.setState(BreakerHalfOpen)
+	}
+	s.flush()
+}
+
+// hold keeps an item to write once the breaker closes, dropping it if the breaker holds none or the oldest one held if it's full
+func (s *breakerSink[T]) hold(item T) {
+	s.held = append(s.held, item)
+	s.trim()
+}
+
+// trim drops the oldest items held past the breaker's buffer
+func (s *breakerSink[T]) trim() {
+	for len(s.held) > s.breaker.buffer {
+		s.drop(s.held[0])
+		s.held = s.held[1:]
+	}
+}
+
+func (s *breakerSink[T]) drop(item T) {
+	s.breaker.Dropped.Add(1)
+	s.o.discard(item)
+}
+
+func (s *breakerSink[T]) setState(state BreakerState) {
+	s.breaker.state.Store(int32(state))
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// resultCacheShards is the most shards a ResultCache is split into, each behind its own lock, so the workers sharing it rarely wait on each other
+const resultCacheShards = 16
+
+// ResultCache is an LRU cache of whether numbers passed a PrimalityTest, shared by the workers so a number drawn again, as happens when
+// sampling a small range densely, isn't tested again. Its numbers are spread over shards by a hash, each holding its share of the capacity
+// and evicting its least recently used number once it's full. It's safe for concurrent use, and its counters can be read while the workers run
+type ResultCache struct {
+	shards []cacheShard
+
+	Hits      atomic.Int64 // Numbers found in the cache, whose test was skipped
+	Misses    atomic.Int64 // Numbers not in the cache, tested and added to it
+	Evictions atomic.Int64 // Numbers dropped to make room for others
+}
+
+type cacheShard struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[int64]*list.Element // Elements of order, holding a cacheEntry
+	order    *list.List              // Most recently used first
+}
+
+type cacheEntry struct {
+	num  int64
+	pass bool
+}
+
+// NewResultCache returns an empty cache holding up to capacity numbers (at least one). A capacity below resultCacheShards is split
+// into fewer shards, one number each, so the cache never holds more than it was asked to
+func NewResultCache(capacity int) *ResultCache {
+	capacity = max(capacity, 1)
+	c := &ResultCache{shards: make([]cacheShard, min(capacity, resultCacheShards))}
+	for i := range c.shards {
+		// The first shards take the remainder, so the capacities add up to the one asked for
+		share := capacity / len(c.shards)
+		if i < capacity%len(c.shards) {
+			share++
+		}
+		c.shards[i] = cacheShard{capacity: share, entries: make(map[int64]*list.Element), order: list.New()}
+	}
+	return c
+}
+
+// Test returns a PrimalityTest answering from the cache, running test on the numbers it doesn't hold and adding them to it.
+// Two workers missing the same number at once both run the test, the cache isn't locked while it runs
+func (c *ResultCache) Test(test PrimalityTest) PrimalityTest {
+	return func(num int64) bool {
+		if pass, ok := c.Get(num); ok {
+			return pass
+		}
+		pass := test(num)
+		c.Add(num, pass)
+		return pass
+	}
+}
+
+// Get returns whether num passed the test if the cache holds it, making it the shard's most recently used number, and counts a hit or a miss
+func (c *ResultCache) Get(num int64) (pass, ok bool) {
+	s := c.shard(num)
+	s.mu.Lock()
+	elem, ok := s.entries[num]
+	if ok {
+		s.order.MoveToFront(elem)
+		pass = elem.Value.(cacheEntry).pass
+	}
+	s.mu.Unlock()
+	if ok {
+		c.Hits.Add(1)
+	} else {
+		c.Misses.Add(1)
+	}
+	return pass, ok
+}
+
+// Add records whether num passed the test, evicting the shard's least recently used number if it's full
+func (c *ResultCache) Add(num int64, pass bool) {
+	s := c.shard(num)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[num]; ok {
+		elem.Value = cacheEntry{num: num, pass: pass}
+		s.order.MoveToFront(elem)
+		return
+	}
+	if s.order.Len() >= s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(cacheEntry).num)
+		c.Evictions.Add(1)
+	}
+	s.entries[num] = s.order.PushFront(cacheEntry{num: num, pass: pass})
+}
+
+// Len returns the number of numbers the cache holds
+func (c *ResultCache) Len() int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += s.order.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// shard returns the shard holding num, picked by Mix64 so consecutive numbers are spread over every shard
+func (c *ResultCache) shard(num int64) *cacheShard {
+	return &c.shards[Mix64(uint64(num))%uint64(len(c.shards))]
+}
@@ -0,0 +1,85 @@
+package pipeline_test
+
+import (
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+func TestResultCacheCapacity(t *testing.T) {
+	for _, capacity := range []int{1, 5, 16, 100} {
+		cache := pipeline.NewResultCache(capacity)
+		for num := range int64(1000) {
+			cache.Add(num, true)
+		}
+		if got := cache.Len(); got != capacity {
+			t.Errorf("cache of capacity %d holds %d numbers", capacity, got)
+		}
+		if got, want := cache.Evictions.Load(), int64(1000-capacity); got != want {
+			t.Errorf("cache of capacity %d evicted %d numbers, want %d", capacity, got, want)
+		}
+	}
+}
+
+func TestResultCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	// A single shard, so every number competes for the same room
+	cache := pipeline.NewResultCache(1)
+	cache.Add(7, true)
+	cache.Add(8, false)
+	if _, ok := cache.Get(7); ok {
+		t.Errorf("7 still cached once 8 took its place")
+	}
+	if pass, ok := cache.Get(8); !ok || pass {
+		t.Errorf("Get(8) = %v, %v, want false, true", pass, ok)
+	}
+}
+
+func TestResultCacheTest(t *testing.T) {
+	var tested int
+	cache := pipeline.NewResultCache(100)
+	test := cache.Test(func(num int64) bool {
+		tested++
+		return pipeline.DeterministicPrime(num)
+	})
+	for range 3 {
+		for _, num := range []int64{7, 9, 11} {
+			if got, want := test(num), pipeline.DeterministicPrime(num); got != want {
+				t.Fatalf("test(%d) = %v, want %v", num, got, want)
+			}
+		}
+	}
+	if tested != 3 {
+		t.Errorf("ran the test %d times, want once per number", tested)
+	}
+	if hits, misses := cache.Hits.Load(), cache.Misses.Load(); hits != 6 || misses != 3 {
+		t.Errorf("got %d hits and %d misses, want 6 and 3", hits, misses)
+	}
+}
+
+// BenchmarkResultCache runs the trial division of ProbablyPrime on candidates sampling a small range densely, as a random source does,
+// with and without a cache holding the whole range, to show what caching the outcomes saves once every candidate has been drawn once
+func BenchmarkResultCache(b *testing.B) {
+	const low, high = 1 << 40, 1<<40 + 10_000
+	tests := []struct {
+		name string
+		test func() pipeline.PrimalityTest
+	}{
+		{"uncached", func() pipeline.PrimalityTest { return pipeline.TrialDivision(pipeline.ProbablyPrime(0)) }},
+		{"cached", func() pipeline.PrimalityTest {
+			return pipeline.NewResultCache(high - low).Test(pipeline.TrialDivision(pipeline.ProbablyPrime(0)))
+		}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			test := tt.test()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				getValue := pipeline.SeededRandValBetween(low, high, 1)
+				for pb.Next() {
+					num, _ := getValue()
+					test(num)
+				}
+			})
+		})
+	}
+}
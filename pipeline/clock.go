@@ -0,0 +1,47 @@
+package pipeline
+
+import "time"
+
+// Clock is the source of time of the stages that are driven by it (such as Throttle, Batch, WindowByTime, Debounce and Annotate), so they can be run on a fake clock.
+// The pipelinetest package has one that only moves when told to, which makes the timing of those stages deterministic in tests
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer started by a Clock, which sends the time on C once it fires
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool // Returns false if the timer already fired or was stopped, as time.Timer does
+}
+
+// WithClock sets the clock a stage reads the time and starts its timers from.
+// Stages use the system clock if no clock is given
+func WithClock(clock Clock) Option {
+	return func(o *stageOptions) {
+		o.clock = clock
+	}
+}
+
+// systemClock is the Clock of the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
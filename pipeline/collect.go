@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// SortedCollect is a terminal stage that reads a stream until it closes and returns its items in ascending order.
+// The fan-in makes the order of a stream depend on scheduling, sorting gives results that are stable from run to run.
+// If the context is cancelled first, the items received so far are returned
+func SortedCollect[T cmp.Ordered](ctx context.Context, valueStream <-chan T) []T {
+	return SortedCollectFunc(ctx, valueStream, cmp.Compare[T])
+}
+
+// SortedCollectFunc is SortedCollect for items that aren't ordered themselves (such as a Found), sorting them with the compare function as in slices.SortFunc
+func SortedCollectFunc[T any](ctx context.Context, valueStream <-chan T, compare func(a, b T) int) []T {
+	var items []T
+	for {
+		select {
+		case <-ctx.Done():
+			slices.SortFunc(items, compare)
+			return items
+		case item, ok := <-valueStream:
+			if !ok {
+				slices.SortFunc(items, compare)
+				return items
+			}
+			items = append(items, item)
+		}
+	}
+}
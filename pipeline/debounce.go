@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Debounce forwards an item of a stream once no other item has followed it for d, replacing an item still waiting with the one after it,
+// so a burst of items comes out as its last one once the burst is over. Unlike Throttle it never holds up the stage feeding it:
+// the items replaced are passed to the WithDiscard callback (if any) instead. An item waiting for the next stage to take it is replaced too,
+// restarting the wait. The item waiting when the input stream closes is sent then, without waiting for the rest of d
+func Debounce[T any](ctx context.Context, valueStream <-chan T, d time.Duration, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	debouncedStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "debounce", "quiet", d)()
+		defer close(debouncedStream)
+		var pending T
+		var held bool
+		var timer Timer
+		var quiet <-chan time.Time // Only set while the pending item is waiting for the quiet period to pass
+		var out chan<- T           // Only set once it has passed
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-valueStream:
+				if !ok {
+					if held {
+						send(ctx, o, debouncedStream, pending)
+					}
+					return
+				}
+				if held {
+					o.discard(pending)
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				pending, held, out = item, true, nil
+				timer = o.clock.NewTimer(d)
+				quiet = timer.C()
+			case <-quiet:
+				timer, quiet, out = nil, nil, debouncedStream
+			case out <- pending:
+				var zero T
+				pending, held, out = zero, false, nil
+			}
+		}
+	})
+	return debouncedStream
+}
+
+// Sample forwards at most one item of a stream per interval d: an item is sent straight away once d has passed since the last one,
+// and the items coming in meanwhile replace each other, so the latest is sent once it's over. A fast stream then comes out as its latest item every d,
+// such as to feed a display or a notification with the state of a stream too fast to follow item by item.
+// Like Debounce it never holds up the stage feeding it, the items replaced are passed to the WithDiscard callback (if any).
+// The interval starts once the next stage has taken an item. The item waiting when the input stream closes is sent then, without waiting for the rest of d
+func Sample[T any](ctx context.Context, valueStream <-chan T, d time.Duration, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	sampledStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "sample", "interval", d)()
+		defer close(sampledStream)
+		var pending T
+		var held bool
+		var timer Timer
+		var interval <-chan time.Time // Only set while the interval since the last item sent is running
+		var out chan<- T              // Only set while there's an item to send and no interval running
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-valueStream:
+				if !ok {
+					if held {
+						send(ctx, o, sampledStream, pending)
+					}
+					return
+				}
+				if held {
+					o.discard(pending)
+				}
+				pending, held = item, true
+				if timer == nil {
+					out = sampledStream
+				}
+			case <-interval:
+				timer, interval = nil, nil
+				if held {
+					out = sampledStream
+				}
+			case out <- pending:
+				var zero T
+				pending, held, out = zero, false, nil
+				timer = o.clock.NewTimer(d)
+				interval = timer.C()
+			}
+		}
+	})
+	return sampledStream
+}
@@ -0,0 +1,50 @@
+package pipeline
+
+import "context"
+
+// Distinct forwards each item of a stream only the first time it is seen (so a prime drawn twice by the generator is only counted once).
+// With a limit of 0 every item seen is remembered. A positive limit bounds memory by remembering only the most recent limit items,
+// an item evicted from that window can be forwarded again
+func Distinct[T comparable](ctx context.Context, valueStream <-chan T, limit int, opts ...Option) <-chan T {
+	return DistinctBy(ctx, valueStream, func(item T) T { return item }, limit, opts...)
+}
+
+// DistinctBy is Distinct for items that are told apart by a key, such as the value wrapped in an Item.
+// Duplicates are passed to the stage's WithDiscard function, if one was given
+func DistinctBy[T any, K comparable](ctx context.Context, valueStream <-chan T, key func(T) K, limit int, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	distinctStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "distinct", "limit", limit)()
+		defer close(distinctStream)
+		seen := make(map[K]struct{})
+		var window []K // Order keys were seen in, used for eviction when bounded
+		next := 0
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			k := key(item)
+			if _, ok := seen[k]; ok {
+				o.discard(item)
+				continue
+			}
+			seen[k] = struct{}{}
+			if limit > 0 {
+				// Ring buffer of the last limit keys, the oldest is forgotten once it is full
+				if len(window) < limit {
+					window = append(window, k)
+				} else {
+					delete(seen, window[next])
+					window[next] = k
+					next = (next + 1) % limit
+				}
+			}
+			if !send(ctx, o, distinctStream, item) {
+				return
+			}
+		}
+	})
+	return distinctStream
+}
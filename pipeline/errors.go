@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrInvalidInput is reported by a stage when it receives an item it can't process
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrExhausted is returned by a value getter that has no more values, ending its stream without reporting an error
+	ErrExhausted = errors.New("source exhausted")
+
+	// ErrPanicked is matched by the PanicError a worker reports when its test panics
+	ErrPanicked = errors.New("worker panicked")
+)
+
+// PanicError is reported by a worker whose test panicked on an item, instead of the panic taking the process down. The worker stops, Supervise can restart it
+type PanicError struct {
+	Item  any    // Item the test panicked on
+	Value any    // Value the test panicked with
+	Stack []byte // Stack of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("worker panicked testing %v: %v", e.Item, e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	return ErrPanicked
+}
+
+// MergeErrors multiplexes the error channels of several stages into a single channel.
+// Stages in this package report at most one error before stopping, so the merged channel is buffered to hold one error per stage and never blocks them.
+// The merged channel is closed once every stage has stopped, which lets a consumer tell a stream that ended apart from one that failed.
+func MergeErrors(errcs ...<-chan error) <-chan error {
+	var wg sync.WaitGroup
+	merged := make(chan error, len(errcs))
+
+	wg.Add(len(errcs))
+	for _, errc := range errcs {
+		go func(errc <-chan error) {
+			defer wg.Done()
+			for err := range errc {
+				merged <- err
+			}
+		}(errc)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	return merged
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// trialDivisionLimit is the largest factor Factorize looks for by trial division, before handing the rest of the number over to Pollard's rho
+const trialDivisionLimit = 1000
+
+// rhoBatch is the number of steps of Pollard's rho whose differences are multiplied together before taking a gcd, which is the expensive part of a step
+const rhoBatch = 128
+
+// Factorization is a number along with its prime factors
+type Factorization struct {
+	Num     int64
+	Factors []int64 // In ascending order, repeated as many times as they divide Num
+}
+
+// FactorWorker reads an input stream of numbers and outputs the factorization of each one (see Factorize). It's a CPU-bound stage whose cost
+// grows with the size of the largest factors, unlike PrimeNumberWorker which keeps a fraction of its input.
+// A negative number is reported as ErrInvalidInput on the returned error channel, and the worker stops. The worker's progress is added to stats, which may be nil
+func FactorWorker(ctx context.Context, intStream <-chan int64, stats *Stats, opts ...Option) (<-chan Factorization, <-chan error) {
+	return MapWorker(ctx, intStream, Factor, stats, opts...)
+}
+
+// Factor factorizes a number for a worker (see Factorize), rejecting negative numbers as ErrInvalidInput
+func Factor(num int64) (Factorization, error) {
+	if num < 0 {
+		return Factorization{}, fmt.Errorf("%w: negative candidate %d", ErrInvalidInput, num)
+	}
+	return Factorization{Num: num, Factors: Factorize(num)}, nil
+}
+
+// Factorize returns the prime factors of a number in ascending order, repeated as many times as they divide it, such as [2 2 3] for 12.
+// Factors up to trialDivisionLimit are found by trial division, and what's left is split with Pollard's rho until every part passes DeterministicPrime.
+// Numbers below 2 have no prime factors
+func Factorize(num int64) []int64 {
+	if num < 2 {
+		return nil
+	}
+	var factors []int64
+	n := uint64(num)
+	for d := uint64(2); d <= trialDivisionLimit && d*d <= n; d++ {
+		for n%d == 0 {
+			factors = append(factors, int64(d))
+			n /= d
+		}
+	}
+	// Whatever is left has no factor up to the limit, so it's 1, a prime, or a product of large primes
+	factors = splitFactors(factors, n)
+	slices.Sort(factors)
+	return factors
+}
+
+// splitFactors appends the prime factors of n to factors, splitting it with Pollard's rho while it's composite
+func splitFactors(factors []int64, n uint64) []int64 {
+	switch {
+	case n == 1:
+		return factors
+	case DeterministicPrime(int64(n)):
+		return append(factors, int64(n))
+	}
+	d := rhoDivisor(n)
+	return splitFactors(splitFactors(factors, d), n/d)
+}
+
+// rhoDivisor returns a divisor of the odd composite n other than 1 and n, using Brent's variant of Pollard's rho.
+// It walks the sequence x -> x^2 + c mod n until two values collide modulo a factor of n, and picks another c if they collide modulo n itself
+func rhoDivisor(n uint64) uint64 {
+	for c := uint64(1); ; c++ {
+		step := func(x uint64) uint64 { return (mulMod(x, x, n) + c) % n }
+		y, q, g := uint64(2), uint64(1), uint64(1)
+		var x, ys uint64
+		for r := 1; g == 1; r *= 2 {
+			x = y
+			for i := 0; i < r; i++ {
+				y = step(y)
+			}
+			for k := 0; k < r && g == 1; k += rhoBatch {
+				ys = y
+				for i := 0; i < min(rhoBatch, r-k); i++ {
+					y = step(y)
+					q = mulMod(q, diff(x, y), n)
+				}
+				g = gcd(q, n)
+			}
+		}
+		if g == n {
+			// The batch went past the collision, so it's stepped through again one gcd at a time
+			for g = 1; g == 1; {
+				ys = step(ys)
+				g = gcd(diff(x, ys), n)
+			}
+		}
+		if g != n {
+			return g
+		}
+	}
+}
+
+// diff returns the absolute difference of a and b
+func diff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// gcd returns the greatest common divisor of a and b, with Euclid's algorithm
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
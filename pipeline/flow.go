@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// FlowStats measures how long a stage spends blocked on its channels: waiting for input from the stage before it (starved),
+// and waiting for the stage after it to take its output (saturated). Comparing them across stages shows where the bottleneck of a pipeline is.
+// A stage running several goroutines (such as ReduceWorkers), or several stages given the same FlowStats, add up their waits.
+// Counters are safe to read while the pipeline is running
+type FlowStats struct {
+	RecvBlocked atomic.Int64 // Nanoseconds spent waiting for input
+	SendBlocked atomic.Int64 // Nanoseconds spent waiting for the next stage to take an item
+
+	recvSince atomic.Int64 // Unix nanoseconds the current wait for input started at, 0 while not waiting
+	sendSince atomic.Int64 // Unix nanoseconds the current wait to send started at, 0 while not waiting
+}
+
+// RecvBlockedTime returns the time the stage spent waiting for input
+func (f *FlowStats) RecvBlockedTime() time.Duration {
+	return time.Duration(f.RecvBlocked.Load())
+}
+
+// SendBlockedTime returns the time the stage spent blocked sending downstream
+func (f *FlowStats) SendBlockedTime() time.Duration {
+	return time.Duration(f.SendBlocked.Load())
+}
+
+// Waiting returns how long the stage has been waiting for input, and waiting to send, as of now (0 if it isn't).
+// With several goroutines sharing the FlowStats, it's the wait that started last
+func (f *FlowStats) Waiting(now time.Time) (recv, send time.Duration) {
+	if since := f.recvSince.Load(); since != 0 {
+		recv = now.Sub(time.Unix(0, since))
+	}
+	if since := f.sendSince.Load(); since != 0 {
+		send = now.Sub(time.Unix(0, since))
+	}
+	return max(recv, 0), max(send, 0)
+}
+
+// receive takes the next item of a stage's input stream, adding the time it waited to the stage's flow stats (if any).
+// It returns false once the stream is closed or the context is cancelled
+func receive[T any](ctx context.Context, o stageOptions, valueStream <-chan T) (T, bool) {
+	var start time.Time
+	if o.flow != nil {
+		start = time.Now()
+		o.flow.recvSince.Store(start.UnixNano())
+		defer func() {
+			o.flow.recvSince.Store(0)
+			o.flow.RecvBlocked.Add(int64(time.Since(start)))
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	case item, ok := <-valueStream:
+		return item, ok
+	}
+}
+
+// send sends an item on a stage's output stream, adding the time it waited to the stage's flow stats (if any).
+// It returns false if the context was cancelled first
+func send[T any](ctx context.Context, o stageOptions, out chan<- T, item T) bool {
+	var start time.Time
+	if o.flow != nil {
+		start = time.Now()
+		o.flow.sendSince.Store(start.UnixNano())
+		defer func() {
+			o.flow.sendSince.Store(0)
+			o.flow.SendBlocked.Add(int64(time.Since(start)))
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- item:
+		return true
+	}
+}
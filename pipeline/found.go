@@ -0,0 +1,27 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Found describes an item kept by a worker (a prime number in our usage), along with where and when it was found
+type Found[T any] struct {
+	Value    T
+	Worker   int       // Index of the worker that found it
+	At       time.Time // When it was found
+	Attempts int64     // Items the worker tested since its previous find, including this one
+}
+
+// Annotate wraps each item from a worker's output stream in a Found, reading the attempt count from the worker's stats.
+// Stats are read as the item is received, so with a buffered worker stream the attempt count can include items tested after it
+func Annotate[T any](ctx context.Context, workerStream <-chan T, worker int, stats *Stats, opts ...Option) <-chan Found[T] {
+	clock := applyOptions(opts).clock
+	var lastTested int64
+	return Map(ctx, workerStream, func(item T) Found[T] {
+		tested := stats.Tested.Load()
+		found := Found[T]{Value: item, Worker: worker, At: clock.Now(), Attempts: tested - lastTested}
+		lastTested = tested
+		return found
+	}, opts...)
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// GapStats describes the gaps between consecutive numbers of a sorted stream. With every prime of a range in the stream
+// (from the sequential source or the sieve) they're the prime gaps of the range, otherwise the gaps between the primes that were sampled
+type GapStats struct {
+	Gaps     int64   // Number of gaps, one less than the numbers read
+	Min      int64   // Smallest gap
+	Max      int64   // Largest gap
+	Mean     float64 // Average gap
+	MaxAfter int64   // Number the first of the largest gaps starts at
+}
+
+// Gaps reads a stream of numbers sorted in ascending order (such as primes collected with SortedCollect) and sends the stats of the gaps
+// between them once the stream is closed. Nothing is sent for a stream of fewer than two numbers, or if the context is cancelled first.
+// A number below the one before it is reported as ErrInvalidInput on the returned error channel, and the stage stops
+func Gaps(ctx context.Context, sortedStream <-chan int64, opts ...Option) (<-chan GapStats, <-chan error) {
+	o := applyOptions(opts)
+	statsStream := make(chan GapStats, 1)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "gaps")()
+		defer close(statsStream)
+		defer close(errc)
+		var stats GapStats
+		var prev, total int64
+		read := false
+		for {
+			num, ok := receive(ctx, o, sortedStream)
+			if !ok {
+				break
+			}
+			if !read {
+				prev, read = num, true
+				continue
+			}
+			if num < prev {
+				reportError(ctx, errc, fmt.Errorf("%w: %d after %d in a sorted stream", ErrInvalidInput, num, prev))
+				return
+			}
+			gap := num - prev
+			if stats.Gaps == 0 || gap < stats.Min {
+				stats.Min = gap
+			}
+			if stats.Gaps == 0 || gap > stats.Max {
+				stats.Max, stats.MaxAfter = gap, prev
+			}
+			stats.Gaps++
+			total += gap
+			prev = num
+		}
+		if ctx.Err() != nil || stats.Gaps == 0 {
+			return
+		}
+		stats.Mean = float64(total) / float64(stats.Gaps)
+		send(ctx, o, statsStream, stats)
+	})
+	return statsStream, errc
+}
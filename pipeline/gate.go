@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate pauses the items going through the Gated stages sharing it. While it's paused each of them holds on to its next item,
+// so the stages after them drain the items in flight and then wait for more. The zero value is open, and a Gate is safe for concurrent use
+type Gate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // Closed when the gate is resumed, nil while it's open
+}
+
+// Pause closes the gate, reporting whether it was open
+func (g *Gate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Resume opens the gate, letting the items held back through. It reports whether the gate was paused
+func (g *Gate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+// Paused reports whether the gate is paused
+func (g *Gate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// Wait blocks while the gate is paused. It returns false if the context was cancelled first
+func (g *Gate) Wait(ctx context.Context) bool {
+	for {
+		g.mu.Lock()
+		resumed := g.resumed
+		g.mu.Unlock()
+		if resumed == nil {
+			return ctx.Err() == nil
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-resumed:
+			// Paused again before this goroutine got to run is possible, so check again
+		}
+	}
+}
+
+// Gated forwards the items of a stream while the gate is open, and holds them back while it's paused, such as between a generator and the workers
+// to stop new candidates while the tests in flight finish
+func Gated[T any](ctx context.Context, valueStream <-chan T, gate *Gate, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	gatedStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "gate")()
+		defer close(gatedStream)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok || !gate.Wait(ctx) || !send(ctx, o, gatedStream, item) {
+				return
+			}
+		}
+	})
+	return gatedStream
+}
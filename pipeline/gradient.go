@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	gradientTolerance = 0.8  // Latency gradient below which the workers are contending, the latency being 25% above the best seen
+	gradientDecrease  = 0.75 // Factor the pool shrinks by once the workers contend
+	gradientMinGain   = 1.05 // Throughput gain a worker added must bring to be kept
+	gradientIdle      = 0.5  // Fraction of the interval workers spent waiting for input above which the producers can't feed them
+	gradientProbe     = 10   // Intervals at a settled size before probing with one more worker again
+)
+
+// AdaptGradient starts a controller that converges on the number of workers giving the most throughput on this host, checking every interval,
+// as an alternative to Autoscale reacting to how long workers wait for input. It probes by adding one worker at a time (additive increase),
+// keeping each that raises the throughput and taking back out one that doesn't. It watches the latency gradient, the best time per test seen
+// over the current one: once it falls below 0.8 the workers are slowing each other down (contending for CPUs or memory), and the pool is cut
+// by a quarter (multiplicative decrease). Once settled it probes again every 10 intervals, in case the host got less busy.
+// A pool whose workers mostly wait for input loses a worker. Every decision is recorded in History with its reason
+func (p *Pool) AdaptGradient(minWorkers, maxWorkers int, interval time.Duration) {
+	p.opts.spawn(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		start := time.Now()
+		lastTested, lastTestTime, lastIdle := p.tested(), p.testTime(), p.idle.Load()
+		bestLatency := math.Inf(1)
+		var rateBeforeProbe float64 // Throughput before the last worker was added, 0 if the last change wasn't a probe
+		settled := 0                // Intervals since the pool last settled on its size, 0 while it's probing
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-p.inputDone:
+				return
+			case <-ticker.C:
+			}
+
+			tested, testTime, idle := p.tested(), p.testTime(), p.idle.Load()
+			size := p.Size()
+			rate := float64(tested-lastTested) / interval.Seconds()
+			idleFraction := float64(idle-lastIdle) / float64(int64(interval)*int64(max(size, 1)))
+			gradient := 1.0
+			if tested > lastTested {
+				latency := float64(testTime-lastTestTime) / float64(tested-lastTested)
+				bestLatency = min(bestLatency, latency)
+				gradient = bestLatency / latency
+			}
+			lastTested, lastTestTime, lastIdle = tested, testTime, idle
+
+			next, reason := size, ""
+			switch {
+			case gradient < gradientTolerance:
+				next, reason = int(float64(size)*gradientDecrease), "latency rising"
+				settled = 1
+			case rateBeforeProbe > 0 && rate < rateBeforeProbe*gradientMinGain:
+				next, reason = size-1, "no gain"
+				settled = 1
+			case idleFraction > gradientIdle:
+				next, reason = size-1, "waiting for input"
+			case settled == 0 || settled >= gradientProbe:
+				next, reason = size+1, "probe"
+				settled = 0
+			default:
+				settled++
+			}
+			next = min(max(next, minWorkers), maxWorkers)
+			rateBeforeProbe = 0
+			if next > size {
+				rateBeforeProbe = rate
+			}
+			if next == size {
+				continue
+			}
+
+			p.opts.logger.Debug("adapting pool", "from", size, "to", next, "reason", reason, "rate", rate, "gradient", gradient)
+			p.Resize(next)
+			p.mu.Lock()
+			p.history = append(p.history, ScaleEvent{At: time.Since(start), Workers: next, Rate: rate, Reason: reason})
+			p.mu.Unlock()
+		}
+	})
+}
+
+// testTime returns the nanoseconds all of the pool's workers spent testing numbers
+func (p *Pool) testTime() int64 {
+	var total int64
+	for _, stats := range p.Stats() {
+		total += stats.TestTime.Load()
+	}
+	return total
+}
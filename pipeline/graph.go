@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Graph wires stages into a directed acyclic graph rather than a chain: the stages are its nodes, added with AddSource, AddStage, AddJoin and AddSink,
+// and the streams between them are its edges, declared with NewEdge. A node writes an edge that one or more nodes read, and an edge read by several
+// nodes is split between them with a Tee, so every reader gets every item (such as the results going to stdout, a CSV file and a metrics aggregator).
+// A node that stops reading its input before it's closed (such as Take) then holds up the Tee, and with it the other readers, so it has to drain it.
+// AddJoin merges several edges into one. Start checks that every edge has one node writing it and at least one reading it and that the edges form no cycle,
+// then starts the nodes in dependency order, each once the nodes writing its inputs have started.
+// A Graph is built from one goroutine, and run once
+type Graph struct {
+	opts    []Option
+	edges   []*graphEdge
+	nodes   []*graphNode
+	names   map[string]bool
+	err     error // First error adding a node or an edge, returned by Start
+	started bool
+
+	stages sync.WaitGroup // The Tees and joins the graph starts itself
+	parent context.Context
+	cancel context.CancelFunc
+	stop   context.CancelFunc // Cancels the sources' context
+	errc   <-chan error
+}
+
+// graphEdge is an edge of a Graph, the stream between the node writing it and the nodes reading it
+type graphEdge struct {
+	name    string
+	writer  *graphNode
+	readers []*graphNode
+	split   func(ctx context.Context, stream any, n int) []any // Tees the writer's stream into n streams of the edge's type
+	streams []any                                              // The streams of the readers that haven't started yet, set once the writer has
+}
+
+// graphNode is a node of a Graph, starting its stage from the streams of its input edges
+type graphNode struct {
+	name    string
+	source  bool
+	sink    bool
+	inputs  []*graphEdge
+	output  *graphEdge // nil for a sink
+	start   func(ctx context.Context, inputs []any) (output any, errc <-chan error)
+	pending int // Inputs whose writer hasn't been sorted yet, while sorting
+}
+
+// NewGraph returns an empty graph. The options are those of the Tees splitting the edges read by several nodes and of the joins,
+// such as WithBuffer, the stages of the nodes take their own
+func NewGraph(opts ...Option) *Graph {
+	g := &Graph{names: make(map[string]bool)}
+	g.opts = append(slices.Clip(opts), WithWaitGroup(&g.stages))
+	return g
+}
+
+// Edge is an edge of a Graph carrying items of type T, declared with NewEdge and connected to the nodes writing and reading it as they're added
+type Edge[T any] struct {
+	e *graphEdge
+}
+
+// NewEdge declares an edge of the graph named name, which is used in the errors about it
+func NewEdge[T any](g *Graph, name string) Edge[T] {
+	e := &graphEdge{name: name, split: func(ctx context.Context, stream any, n int) []any {
+		streams := make([]any, n)
+		if n == 1 {
+			streams[0] = stream
+			return streams
+		}
+		for i, tee := range Tee(ctx, stream.(<-chan T), n, g.opts...) {
+			streams[i] = tee
+		}
+		return streams
+	}}
+	g.edges = append(g.edges, e)
+	return Edge[T]{e}
+}
+
+// AddSource adds a node with no input writing out, such as CreateValueStream. Sources run on a context of their own,
+// so Stop can tear them down alone and let the rest of the graph drain. errc may be nil for a source that can't fail
+func AddSource[T any](g *Graph, name string, out Edge[T], start func(ctx context.Context) (<-chan T, <-chan error)) {
+	g.addNode(&graphNode{name: name, source: true}, nil, out.e, func(ctx context.Context, inputs []any) (any, <-chan error) {
+		return start(ctx)
+	})
+}
+
+// AddStage adds a node reading in and writing out, such as a set of workers fanned in. errc may be nil for a stage that can't fail
+func AddStage[In, Out any](g *Graph, name string, in Edge[In], out Edge[Out], start func(ctx context.Context, in <-chan In) (<-chan Out, <-chan error)) {
+	g.addNode(&graphNode{name: name}, []*graphEdge{in.e}, out.e, func(ctx context.Context, inputs []any) (any, <-chan error) {
+		return start(ctx, inputs[0].(<-chan In))
+	})
+}
+
+// AddJoin adds a node merging the items of its inputs into out as they're ready (see ReduceWorkers)
+func AddJoin[T any](g *Graph, name string, ins []Edge[T], out Edge[T]) {
+	inputs := make([]*graphEdge, len(ins))
+	for i, in := range ins {
+		inputs[i] = in.e
+	}
+	g.addNode(&graphNode{name: name}, inputs, out.e, func(ctx context.Context, inputs []any) (any, <-chan error) {
+		channels := make([]<-chan T, len(inputs))
+		for i, input := range inputs {
+			channels[i] = input.(<-chan T)
+		}
+		return ReduceWorkers(ctx, channels, g.opts...), nil
+	})
+}
+
+// AddSink adds a node reading in with no output, such as SinkToFile. The graph is done once every sink's error channel is closed,
+// so a sink returns one, which it closes once it's done with its input
+func AddSink[T any](g *Graph, name string, in Edge[T], sink func(ctx context.Context, in <-chan T) <-chan error) {
+	g.addNode(&graphNode{name: name, sink: true}, []*graphEdge{in.e}, nil, func(ctx context.Context, inputs []any) (any, <-chan error) {
+		return nil, sink(ctx, inputs[0].(<-chan T))
+	})
+}
+
+// addNode connects a node to its edges, recording the first error in g.err
+func (g *Graph) addNode(n *graphNode, inputs []*graphEdge, output *graphEdge, start func(ctx context.Context, inputs []any) (any, <-chan error)) {
+	switch {
+	case g.err != nil:
+		return
+	case g.started:
+		g.err = fmt.Errorf("pipeline: node %q added to a graph already started", n.name)
+		return
+	case g.names[n.name]:
+		g.err = fmt.Errorf("pipeline: two nodes of the graph are named %q", n.name)
+		return
+	case output != nil && output.writer != nil:
+		g.err = fmt.Errorf("pipeline: edge %q is written by both %q and %q", output.name, output.writer.name, n.name)
+		return
+	}
+	edges := slices.Clip(inputs)
+	if !n.sink {
+		edges = append(edges, output)
+	}
+	for _, e := range edges {
+		switch {
+		case e == nil:
+			g.err = fmt.Errorf("pipeline: an edge of node %q wasn't declared with NewEdge", n.name)
+			return
+		case !slices.Contains(g.edges, e):
+			g.err = fmt.Errorf("pipeline: edge %q of node %q belongs to another graph", e.name, n.name)
+			return
+		}
+	}
+	g.names[n.name] = true
+	n.inputs, n.output, n.start = inputs, output, start
+	for _, e := range inputs {
+		e.readers = append(e.readers, n)
+	}
+	if output != nil {
+		output.writer = n
+	}
+	g.nodes = append(g.nodes, n)
+}
+
+// sorted returns the nodes in dependency order, each after the nodes writing its inputs, or an error if an edge is missing a node or the edges form a cycle
+func (g *Graph) sorted() ([]*graphNode, error) {
+	for _, e := range g.edges {
+		switch {
+		case e.writer == nil:
+			return nil, fmt.Errorf("pipeline: no node writes edge %q", e.name)
+		case len(e.readers) == 0:
+			// An edge nobody drains would block the node writing it
+			return nil, fmt.Errorf("pipeline: no node reads edge %q", e.name)
+		}
+	}
+	// Kahn's algorithm, keeping the order the nodes were added in among those that are ready
+	var order, ready []*graphNode
+	for _, n := range g.nodes {
+		n.pending = len(n.inputs)
+		if n.pending == 0 {
+			ready = append(ready, n)
+		}
+	}
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		order = append(order, n)
+		if n.output == nil {
+			continue
+		}
+		for _, reader := range n.output.readers {
+			if reader.pending--; reader.pending == 0 {
+				ready = append(ready, reader)
+			}
+		}
+	}
+	if len(order) < len(g.nodes) {
+		// The nodes left are those on a cycle and those after one
+		var blocked []string
+		for _, n := range g.nodes {
+			if n.pending > 0 {
+				blocked = append(blocked, fmt.Sprintf("%q", n.name))
+			}
+		}
+		return nil, fmt.Errorf("pipeline: the graph has a cycle, nodes %s wait on one", strings.Join(blocked, ", "))
+	}
+	return order, nil
+}
+
+// Start checks the graph and starts its nodes in dependency order on ctx. Cancelling ctx stops every node,
+// Stop tears the graph down from its sources instead, and Wait waits for it to be done
+func (g *Graph) Start(ctx context.Context) error {
+	if g.err != nil {
+		return g.err
+	}
+	if g.started {
+		return errors.New("pipeline: graph started twice")
+	}
+	order, err := g.sorted()
+	if err != nil {
+		return err
+	}
+	g.started = true
+	g.parent = ctx
+	ctx, g.cancel = context.WithCancel(ctx)
+	sourceCtx, stop := context.WithCancel(ctx)
+	g.stop = stop
+
+	// Once every sink is done the nodes still running have nowhere to send their items, such as the stages before a Take, so they're cancelled
+	var sinks sync.WaitGroup
+	for _, n := range g.nodes {
+		if n.sink {
+			sinks.Add(1)
+		}
+	}
+	go func() {
+		sinks.Wait()
+		g.cancel()
+	}()
+	var errcs []<-chan error
+	for _, n := range order {
+		inputs := make([]any, len(n.inputs))
+		for i, e := range n.inputs {
+			inputs[i], e.streams = e.streams[0], e.streams[1:]
+		}
+		nodeCtx := ctx
+		if n.source {
+			nodeCtx = sourceCtx
+		}
+		output, errc := n.start(nodeCtx, inputs)
+		if n.output != nil {
+			n.output.streams = n.output.split(ctx, output, len(n.output.readers))
+		}
+		done := func() {}
+		if n.sink {
+			done = sinks.Done
+		}
+		if errc == nil {
+			done()
+			continue
+		}
+		errcs = append(errcs, nodeErrors(n.name, errc, done))
+	}
+	g.errc = MergeErrors(errcs...)
+	return nil
+}
+
+// nodeErrors forwards the errors of a node's error channel, wrapped with the node's name, calling done once it's closed
+func nodeErrors(name string, errc <-chan error, done func()) <-chan error {
+	named := make(chan error, 1)
+	go func() {
+		defer close(named)
+		defer done()
+		for err := range errc {
+			named <- fmt.Errorf("%s: %w", name, err)
+		}
+	}()
+	return named
+}
+
+// Stop cancels the context of the graph's sources. Every stage closes its output once its input closes,
+// so the rest of the graph drains the items in flight and stops in dependency order, which Wait waits for
+func (g *Graph) Stop() {
+	if g.stop != nil {
+		g.stop()
+	}
+}
+
+// Wait waits for every node of a started graph to be done. The graph is done once every sink is, the nodes still running are cancelled then.
+// The first error reported by a node, wrapped with its name, cancels the whole graph and is returned.
+// It returns the context's error if the context Start was given was cancelled first, and nil otherwise
+func (g *Graph) Wait() error {
+	if g.errc == nil {
+		return errors.New("pipeline: graph waited on before it's started")
+	}
+	var first error
+	for err := range g.errc {
+		if first == nil {
+			first = err
+			g.cancel()
+		}
+	}
+	g.cancel()
+	g.stages.Wait()
+	if first == nil {
+		first = g.parent.Err()
+	}
+	return first
+}
+
+// Run starts the graph on ctx and waits for it to be done, returning the first error of Start or Wait
+func (g *Graph) Run(ctx context.Context) error {
+	if err := g.Start(ctx); err != nil {
+		return err
+	}
+	return g.Wait()
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// FilterGroup is the errgroup counterpart of CreateValueStream, FilterWorker and ReduceWorkers: a producer calls getValue and one worker per stats
+// (none of which may be nil) keeps the values passing keep, run as goroutines of an errgroup.Group.
+// emit is called from the calling goroutine with each value kept, and returns false to stop the group.
+// The first error of any goroutine (other than ErrExhausted from getValue, which ends the values) cancels the others and is returned once they've all exited,
+// rather than going through error channels. A panic in keep is returned as a PanicError. It returns nil once the values run out, emit stops it, or ctx is cancelled
+func FilterGroup[T any](ctx context.Context, getValue func() (T, error), keep func(T) (bool, error), stats []*Stats, emit func(Found[T]) bool, opts ...Option) error {
+	o := applyOptions(opts)
+	defer logLifetime(ctx, o.logger, "filter group", "workers", len(stats))()
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, groupCtx := errgroup.WithContext(groupCtx)
+	valueStream := make(chan T, o.buffer)
+	keptStream := make(chan Found[T], o.buffer)
+
+	g.Go(func() error {
+		defer close(valueStream)
+		for {
+			val, err := getValue()
+			if errors.Is(err, ErrExhausted) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !send(groupCtx, o, valueStream, val) {
+				return nil
+			}
+		}
+	})
+	var workers sync.WaitGroup
+	for worker, stats := range stats {
+		workers.Add(1)
+		g.Go(func() error {
+			defer workers.Done()
+			var lastTested int64
+			for {
+				val, ok := receive(groupCtx, o, valueStream)
+				if !ok {
+					return nil
+				}
+				found, err := runTest(val, keep, stats)
+				if err != nil {
+					return err
+				}
+				if !found {
+					continue
+				}
+				tested := stats.Tested.Load()
+				err = sendItem(groupCtx, o, Found[T]{Value: val, Worker: worker, At: o.clock.Now(), Attempts: tested - lastTested}, stats, keptStream)
+				lastTested = tested
+				if err != nil {
+					return nil // Only the context being done stops a send
+				}
+			}
+		})
+	}
+	o.spawn(func() {
+		workers.Wait()
+		close(keptStream)
+	})
+
+	for found := range keptStream {
+		if !emit(found) {
+			break
+		}
+	}
+	// Unblock the workers if emit stopped the group, then wait for every goroutine to exit
+	cancel()
+	for range keptStream {
+	}
+	err := g.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
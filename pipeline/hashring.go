@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+// HashRing assigns keys to members by consistent hashing: each member is hashed to replicas points on a ring, and a key belongs to the member
+// of the first point at or after the key's own hash. Adding or removing a member only moves the keys of the points it takes or gives up,
+// about 1/n of them with n members, where assigning by hash modulo n would move most keys.
+// It isn't safe for concurrent use
+type HashRing struct {
+	replicas int
+	points   []ringPoint // Sorted by hash
+	members  map[string]struct{}
+}
+
+type ringPoint struct {
+	hash   uint64
+	member string
+}
+
+// NewHashRing returns an empty ring, hashing each member to replicas points (at least 1). More points spread the keys more evenly between the members
+func NewHashRing(replicas int) *HashRing {
+	return &HashRing{replicas: max(replicas, 1), members: make(map[string]struct{})}
+}
+
+// Add adds a member to the ring, doing nothing if it's already on it
+func (r *HashRing) Add(member string) {
+	if _, ok := r.members[member]; ok {
+		return
+	}
+	r.members[member] = struct{}{}
+	for i := 0; i < r.replicas; i++ {
+		r.points = append(r.points, ringPoint{hash: ringHash(member + "#" + strconv.Itoa(i)), member: member})
+	}
+	slices.SortFunc(r.points, func(a, b ringPoint) int {
+		if a.hash != b.hash {
+			return cmp.Compare(a.hash, b.hash)
+		}
+		// Two members hashed to the same point are ordered by name, so the ring doesn't depend on the order they were added in
+		return cmp.Compare(a.member, b.member)
+	})
+}
+
+// Remove removes a member from the ring, doing nothing if it isn't on it
+func (r *HashRing) Remove(member string) {
+	if _, ok := r.members[member]; !ok {
+		return
+	}
+	delete(r.members, member)
+	r.points = slices.DeleteFunc(r.points, func(p ringPoint) bool { return p.member == member })
+}
+
+// Owner returns the member the key belongs to, or false if the ring is empty
+func (r *HashRing) Owner(key string) (string, bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+	hash := ringHash(key)
+	i, _ := slices.BinarySearchFunc(r.points, hash, func(p ringPoint, hash uint64) int { return cmp.Compare(p.hash, hash) })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].member, true
+}
+
+// Len returns the number of members on the ring
+func (r *HashRing) Len() int {
+	return len(r.members)
+}
+
+// ringHash hashes a member's point or a key with FNV-1a, mixed by Mix64 as FNV alone spreads short keys
+// that only differ in their last characters, such as "worker#1" and "worker#2", unevenly
+func ringHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return Mix64(h.Sum64())
+}
@@ -0,0 +1,19 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Item wraps a value with the context it was created in, so request-scoped values (such as a trace span) can follow the value through the stages.
+// Generic stages carry items like any other type, and a FilterWorker can test the wrapped value
+type Item[T any] struct {
+	Value   T
+	Ctx     context.Context
+	Created time.Time // When the item was wrapped, to measure how long it took to go through the stages (see Latencies)
+}
+
+// NewItem wraps a value in an Item created now
+func NewItem[T any](ctx context.Context, val T) Item[T] {
+	return Item[T]{Value: val, Ctx: ctx, Created: time.Now()}
+}
@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"math"
+	"runtime"
+)
+
+// All returns the results of Run as an iterator, so they can be ranged over: for found, err := range p.All(ctx).
+// Breaking out of the loop stops the pipeline, as does the end of Run. The error Run returns, if any, is yielded last with a zero Result
+func (p Pipeline) All(ctx context.Context) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		err := p.Run(ctx, func(found Result) error {
+			if !yield(found, nil) {
+				return ErrStop
+			}
+			return nil
+		})
+		if err != nil {
+			yield(Result{}, err)
+		}
+	}
+}
+
+// Primes returns an iterator over the prime numbers from 2 up, such as for p := range pipeline.Primes(ctx).
+// The candidates are walked in order and tested by a worker per CPU, so the primes come in the order the workers find them, close to ascending.
+// The iteration goes on until the loop breaks out of it or ctx is cancelled, either of which stops the pipeline
+func Primes(ctx context.Context, opts ...Option) iter.Seq[int64] {
+	p := Pipeline{Source: SourceFunc(SequentialVal(math.MaxInt64)), Workers: runtime.NumCPU(), Options: opts}
+	return func(yield func(int64) bool) {
+		// The only error of a sequential source tested with ProbablyPrime is the context's, which ends the iteration
+		for found, err := range p.All(ctx) {
+			if err != nil || !yield(found.Value) {
+				return
+			}
+		}
+	}
+}
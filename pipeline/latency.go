@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of latencies Latencies keeps for its quantiles, so a long run doesn't keep every one of them
+const latencySamples = 4096
+
+// Latencies records how long items took to go through a pipeline, such as from the Created time of an Item to its emission as a result.
+// Its quantiles are estimated from a uniform sample of the latencies observed (a reservoir of latencySamples), while the count, sum and maximum are exact.
+// The zero value is empty, and Latencies is safe for concurrent use
+type Latencies struct {
+	mu      sync.Mutex
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+	samples []time.Duration
+}
+
+// Observe records the latency of an item
+func (l *Latencies) Observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count++
+	l.sum += d
+	l.max = max(l.max, d)
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+	} else if i := rand.Int64N(l.count); i < latencySamples {
+		// Replacing a sample with a probability of latencySamples/count keeps every latency observed equally likely to be in the sample
+		l.samples[i] = d
+	}
+}
+
+// Count returns the number of latencies observed
+func (l *Latencies) Count() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Sum returns the total of the latencies observed
+func (l *Latencies) Sum() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sum
+}
+
+// Max returns the highest latency observed
+func (l *Latencies) Max() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.max
+}
+
+// Quantiles returns the latency below which each fraction qs of the items observed went through (such as 0.99 for the p99), in the same order.
+// They're 0 if nothing was observed
+func (l *Latencies) Quantiles(qs ...float64) []time.Duration {
+	l.mu.Lock()
+	sorted := slices.Clone(l.samples)
+	l.mu.Unlock()
+	slices.Sort(sorted)
+	quantiles := make([]time.Duration, len(qs))
+	if len(sorted) == 0 {
+		return quantiles
+	}
+	for i, q := range qs {
+		// The nearest-rank quantile, the smallest sample with at least q of the samples at or below it
+		rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+		quantiles[i] = sorted[min(max(rank, 0), len(sorted)-1)]
+	}
+	return quantiles
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// leakPollInterval is how often Leaked checks whether the goroutines it's waiting for have exited
+const leakPollInterval = 10 * time.Millisecond
+
+// Goroutines is a snapshot of the goroutines running, taken before building a pipeline so Leaked can tell which goroutines
+// were started since and are still running once it's over
+type Goroutines struct {
+	running map[string]bool // By goroutine ID
+}
+
+// SnapshotGoroutines records the goroutines running now
+func SnapshotGoroutines() Goroutines {
+	running := make(map[string]bool)
+	for id := range goroutineStacks() {
+		running[id] = true
+	}
+	return Goroutines{running: running}
+}
+
+// Leaked waits up to timeout for the goroutines started since the snapshot to exit, and returns the stacks of those still running after it, nil if none are.
+// A stage's goroutines exit shortly after its output is closed rather than before, so they're given the timeout to go
+func (g Goroutines) Leaked(timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		var leaked []string
+		for id, stack := range goroutineStacks() {
+			if !g.running[id] {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(leakPollInterval)
+	}
+}
+
+// goroutineStacks returns the stack of every goroutine running, by goroutine ID
+func goroutineStacks() map[string]string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[string]string)
+	// Each goroutine's stack starts with a "goroutine <ID> [<state>]:" line, and a blank line separates them
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		fields := bytes.Fields(stack)
+		if len(fields) < 2 || string(fields[0]) != "goroutine" {
+			continue
+		}
+		if _, err := strconv.ParseUint(string(fields[1]), 10, 64); err == nil {
+			stacks[string(fields[1])] = string(stack)
+		}
+	}
+	return stacks
+}
@@ -0,0 +1,48 @@
+package pipeline_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// blockedOn is a goroutine left blocked until its channel is closed, standing in for a stage that leaked
+func blockedOn(release <-chan struct{}) {
+	<-release
+}
+
+func TestLeakedReportsRunningGoroutines(t *testing.T) {
+	before := pipeline.SnapshotGoroutines()
+	release := make(chan struct{})
+	go blockedOn(release)
+	leaked := before.Leaked(50 * time.Millisecond)
+	if len(leaked) != 1 || !strings.Contains(leaked[0], "blockedOn") {
+		t.Fatalf("got leaked stacks %q, want the blocked goroutine's", leaked)
+	}
+	close(release)
+	if leaked := before.Leaked(time.Second); leaked != nil {
+		t.Fatalf("got leaked stacks %q once the goroutine was released, want none", leaked)
+	}
+}
+
+func TestLeakedIgnoresGoroutinesRunningBefore(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	go blockedOn(release)
+	if leaked := pipeline.SnapshotGoroutines().Leaked(0); leaked != nil {
+		t.Fatalf("got leaked stacks %q, want none for a goroutine started before the snapshot", leaked)
+	}
+}
+
+// TestLeakedWaitsForExit checks that a goroutine exiting within the timeout isn't reported, as a stage does shortly after closing its output
+func TestLeakedWaitsForExit(t *testing.T) {
+	before := pipeline.SnapshotGoroutines()
+	release := make(chan struct{})
+	go blockedOn(release)
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+	if leaked := before.Leaked(time.Second); leaked != nil {
+		t.Fatalf("got leaked stacks %q, want none once the goroutine exited", leaked)
+	}
+}
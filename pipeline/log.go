@@ -0,0 +1,20 @@
+package pipeline
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logLifetime logs a stage starting at debug level, and returns a function that logs the stage stopping, or being cancelled if the context is done.
+// Stages call it as the first deferred call of their goroutine, so the stop is logged once the stage's channels are closed
+func logLifetime(ctx context.Context, logger *slog.Logger, stage string, args ...any) func() {
+	logger = logger.With(append([]any{"stage", stage}, args...)...)
+	logger.Debug("stage started")
+	return func() {
+		if ctx.Err() != nil {
+			logger.Debug("stage cancelled", "cause", context.Cause(ctx))
+			return
+		}
+		logger.Debug("stage stopped")
+	}
+}
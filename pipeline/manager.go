@@ -0,0 +1,241 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// JobState is the state of a Job
+type JobState int32
+
+const (
+	JobRunning JobState = iota
+	JobDone             // The pipeline ran to its end: its Limit was reached, its source exhausted or its callback returned ErrStop
+	JobStopped          // The job was stopped with Stop, or its context was cancelled, before the pipeline ran to its end
+	JobFailed           // A stage or the callback returned an error, reported by Err
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobRunning:
+		return "running"
+	case JobDone:
+		return "done"
+	case JobStopped:
+		return "stopped"
+	case JobFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("JobState(%d)", int32(s))
+}
+
+// Manager runs several Pipelines at once in one process, such as a pipeline per range, each as a named Job that can be stopped and waited on
+// by itself, and counts their work together. A finished job is kept, with its status, until it's removed with Remove.
+// A Manager is safe for concurrent use
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+	all  []*Job // In the order they were started
+	wg   sync.WaitGroup
+}
+
+// NewManager returns a Manager running no job
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*Job)}
+}
+
+// Job is a Pipeline run by a Manager
+type Job struct {
+	name    string
+	cancel  context.CancelFunc
+	done    chan struct{} // Closed once the pipeline's Run has returned
+	started time.Time
+	tested  atomic.Int64
+	found   atomic.Int64
+
+	// Set once the job is done
+	state    JobState
+	err      error
+	finished time.Time
+}
+
+// JobStatus is a snapshot of a Job
+type JobStatus struct {
+	Name     string
+	State    JobState
+	Tested   int64 // Candidates the pipeline's test has been run on
+	Found    int64 // Results the callback has been called with
+	Err      error // The error the job failed with
+	Started  time.Time
+	Finished time.Time // Zero while the job is running
+}
+
+// Start runs p on a context derived from ctx in a job named name, calling fn (which may be nil) with each of its results as Pipeline.Run does.
+// It returns an error if a job the manager still has is named name. fn is called from the job's goroutine, so the jobs' callbacks run concurrently
+func (m *Manager) Start(ctx context.Context, name string, p Pipeline, fn func(result Result) error) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[name]; ok {
+		return nil, fmt.Errorf("pipeline: a job named %q is already managed", name)
+	}
+	clock := applyOptions(p.Options).clock
+	ctx, cancel := context.WithCancel(ctx)
+	j := &Job{name: name, cancel: cancel, done: make(chan struct{}), started: clock.Now()}
+	isPrime := p.Test
+	if isPrime == nil {
+		isPrime = ProbablyPrime(0)
+	}
+	p.Test = func(num int64) bool {
+		j.tested.Add(1)
+		return isPrime(num)
+	}
+	m.jobs[name] = j
+	m.all = append(m.all, j)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(j.done)
+		defer cancel()
+		err := p.Run(ctx, func(result Result) error {
+			j.found.Add(1)
+			if fn == nil {
+				return nil
+			}
+			return fn(result)
+		})
+		j.finished = clock.Now()
+		switch {
+		case err == nil:
+			j.state = JobDone
+		case ctx.Err() != nil:
+			// The pipeline returns the context's error once it's cancelled, which isn't a failure of the job
+			j.state = JobStopped
+		default:
+			j.state, j.err = JobFailed, err
+		}
+	}()
+	return j, nil
+}
+
+// Job returns the job named name, or false if the manager has none
+func (m *Manager) Job(name string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[name]
+	return j, ok
+}
+
+// Jobs returns the manager's jobs in the order they were started
+func (m *Manager) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Job(nil), m.all...)
+}
+
+// Stop stops the job named name, returning an error if the manager has none. Like Job.Stop it doesn't wait for the job to be done
+func (m *Manager) Stop(name string) error {
+	j, ok := m.Job(name)
+	if !ok {
+		return fmt.Errorf("pipeline: no job named %q", name)
+	}
+	j.Stop()
+	return nil
+}
+
+// Remove forgets the job named name once it's done, so its name can be used again.
+// It returns an error if the manager has no such job or if it's still running
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[name]
+	switch {
+	case !ok:
+		return fmt.Errorf("pipeline: no job named %q", name)
+	case j.Status().State == JobRunning:
+		return fmt.Errorf("pipeline: job %q is still running", name)
+	}
+	delete(m.jobs, name)
+	for i, other := range m.all {
+		if other == j {
+			m.all = append(m.all[:i], m.all[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// StopAll stops every job, without waiting for them to be done
+func (m *Manager) StopAll() {
+	for _, j := range m.Jobs() {
+		j.Stop()
+	}
+}
+
+// Wait waits for every job started so far to be done, including those already removed
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// ManagerStats counts the jobs of a Manager by state, and the work of all of them together
+type ManagerStats struct {
+	Running, Done, Stopped, Failed int
+	Tested                         int64 // Candidates tested by every job
+	Found                          int64 // Results found by every job
+}
+
+// Stats returns a snapshot of the manager's jobs. The jobs removed aren't counted
+func (m *Manager) Stats() ManagerStats {
+	var stats ManagerStats
+	for _, j := range m.Jobs() {
+		st := j.Status()
+		switch st.State {
+		case JobRunning:
+			stats.Running++
+		case JobDone:
+			stats.Done++
+		case JobStopped:
+			stats.Stopped++
+		case JobFailed:
+			stats.Failed++
+		}
+		stats.Tested += st.Tested
+		stats.Found += st.Found
+	}
+	return stats
+}
+
+// Name returns the name the job was started with
+func (j *Job) Name() string {
+	return j.name
+}
+
+// Stop cancels the job's pipeline. The job is done once every stage has exited, which Wait and Done wait for
+func (j *Job) Stop() {
+	j.cancel()
+}
+
+// Done returns a channel closed once the job is done
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Wait waits for the job to be done and returns the error it failed with, nil if it ran to its end or was stopped
+func (j *Job) Wait() error {
+	<-j.done
+	return j.err
+}
+
+// Status returns a snapshot of the job. Its counters are read while it runs, so a running job's status shows its progress
+func (j *Job) Status() JobStatus {
+	st := JobStatus{Name: j.name, State: JobRunning, Tested: j.tested.Load(), Found: j.found.Load(), Started: j.started}
+	select {
+	case <-j.done:
+		// The fields set once the job is done are only read after done is closed
+		st.State, st.Err, st.Finished = j.state, j.err, j.finished
+	default:
+	}
+	return st
+}
@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// maxMersenneExponent is the largest exponent LucasLehmer takes. 2^p-1 is held in p bits, so the limit keeps a stray candidate from allocating gigabytes
+const maxMersenneExponent = 1 << 26
+
+// MersennePrime is a PrimalityTest reporting whether the Mersenne number 2^p-1 is prime for the exponent p (see LucasLehmer)
+func MersennePrime(p int64) bool {
+	prime, _ := LucasLehmer(context.Background(), p, nil)
+	return prime
+}
+
+// LucasLehmer reports whether the Mersenne number 2^p-1 is prime, with the Lucas-Lehmer test: s starts at 4 and is replaced by s^2-2 mod 2^p-1
+// p-2 times, and the number is prime if s ends up at 0. Only a prime exponent can give a Mersenne prime, so other exponents are rejected straight away.
+// Each step squares a number of p bits, so unlike the other tests it takes seconds for exponents in the tens of thousands.
+// progress, which may be nil, is called after every step with the steps done and the total. The test stops with the context's error once it's cancelled
+func LucasLehmer(ctx context.Context, p int64, progress func(done, total int64)) (bool, error) {
+	switch {
+	case p > maxMersenneExponent:
+		return false, fmt.Errorf("%w: Mersenne exponent %d above %d", ErrInvalidInput, p, maxMersenneExponent)
+	case p == 2:
+		return true, nil // 3, the one Mersenne prime the test doesn't cover
+	case !DeterministicPrime(p):
+		return false, nil
+	}
+	m := new(big.Int).Lsh(big.NewInt(1), uint(p))
+	m.Sub(m, big.NewInt(1))
+	s, two, high := big.NewInt(4), big.NewInt(2), new(big.Int)
+	total := p - 2
+	for i := int64(1); i <= total; i++ {
+		if i%64 == 0 && ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		s.Mul(s, s)
+		s.Sub(s, two)
+		// 2^p is 1 mod 2^p-1, so the bits above p fold back onto the low ones, which is cheaper than a division
+		for s.Cmp(m) > 0 {
+			high.Rsh(s, uint(p))
+			s.And(s, m)
+			s.Add(s, high)
+		}
+		if s.Cmp(m) == 0 {
+			s.SetInt64(0)
+		}
+		if progress != nil {
+			progress(i, total)
+		}
+	}
+	return s.Sign() == 0, nil
+}
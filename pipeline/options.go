@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Option configures a stage, and is passed as the last arguments of the stage function
+type Option func(*stageOptions)
+
+type stageOptions struct {
+	buffer   int
+	discard  func(item any)
+	logger   *slog.Logger
+	flow     *FlowStats
+	clock    Clock
+	stages   []*sync.WaitGroup
+	priority func(item any) int
+}
+
+// WithBuffer sets the capacity of the channel a stage writes its output to.
+// Stages use unbuffered channels by default, so every hand-off waits for the next stage to be ready
+func WithBuffer(size int) Option {
+	return func(o *stageOptions) {
+		o.buffer = size
+	}
+}
+
+// WithDiscard sets a function called with every item a stage drops rather than sending downstream (such as the duplicates removed by DistinctBy),
+// so resources tied to the item can be released. Given more than once, the functions are called in the order they were given
+func WithDiscard(fn func(item any)) Option {
+	return func(o *stageOptions) {
+		prev := o.discard
+		o.discard = func(item any) {
+			prev(item)
+			fn(item)
+		}
+	}
+}
+
+// WithPriority sets the priority of the items a fan-in stage (ReduceWorkers) forwards: when its output is contended, the items waiting
+// are sent highest priority first, items of the same priority in the order they arrived. The items of the higher priorities can hold the others
+// back for as long as they keep coming. Fan-ins forward whichever item is ready first if no priority is given
+func WithPriority(priority func(item any) int) Option {
+	return func(o *stageOptions) {
+		o.priority = priority
+	}
+}
+
+// WithLogger sets the logger a stage writes debug events to, such as the stage starting, stopping or being cancelled.
+// Stages log to slog.Default() if no logger is given
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *stageOptions) {
+		o.logger = logger
+	}
+}
+
+// WithFlowStats sets the stats a stage adds the time it's blocked on its channels to, so the bottleneck of a pipeline can be found.
+// Stages don't time their hand-offs if no stats are given
+func WithFlowStats(stats *FlowStats) Option {
+	return func(o *stageOptions) {
+		o.flow = stats
+	}
+}
+
+// WithWaitGroup adds the goroutines a stage starts to wg, so whoever cancels the pipeline can wait for every stage to have exited
+// rather than returning while some are still winding down, such as a server's job or a library call leaving nothing running behind it.
+// Given more than once, the goroutines are added to every group, so a caller can also wait for a subset of the stages (such as those sending on a channel it closes)
+func WithWaitGroup(wg *sync.WaitGroup) Option {
+	return func(o *stageOptions) {
+		o.stages = append(o.stages, wg)
+	}
+}
+
+// applyOptions returns the settings of a stage with the given options applied over the defaults
+func applyOptions(opts []Option) stageOptions {
+	o := stageOptions{discard: func(any) {}, logger: slog.Default(), clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// spawn runs fn in a new goroutine of the stage, added to the wait groups set with WithWaitGroup
+func (o stageOptions) spawn(fn func()) {
+	for _, wg := range o.stages {
+		wg.Add(1)
+	}
+	go func() {
+		defer func() {
+			for _, wg := range o.stages {
+				wg.Done()
+			}
+		}()
+		fn()
+	}()
+}
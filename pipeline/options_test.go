@@ -0,0 +1,18 @@
+package pipeline_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// BenchmarkBuffer runs the stages of BenchmarkStages with their channels sized by WithBuffer, from the unbuffered default up,
+// showing how much of their time goes to waiting on each other's hand-offs
+func BenchmarkBuffer(b *testing.B) {
+	for _, size := range []int{0, 1, 64, 1024} {
+		b.Run("buffer="+strconv.Itoa(size), func(b *testing.B) {
+			runStages(b, b.N, pipeline.WithBuffer(size))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// sequenced is an item of an OrderedWorkers stage tagged with its position in the input stream
+type sequenced[T any] struct {
+	seq  int64
+	item T
+}
+
+// orderedResult is what a worker of an OrderedWorkers stage made of the item at seq, kept or not, so the reorder buffer knows it's done
+type orderedResult[T any] struct {
+	seq   int64
+	found Found[T]
+	keep  bool
+}
+
+// OrderedWorkers runs one worker per stats (none of which may be nil) like a set of MapWorkers fanned in, each returning whether to keep its result,
+// but the results come out in the order of the items they were made from rather than in the order the workers finish them.
+// Each item is numbered as it's read, and the workers report every item done, kept or not, to a reorder buffer that holds the results
+// finishing ahead of an item still being worked on. At most window items are read ahead of the oldest one in flight, which bounds the buffer:
+// when a slow item holds it up the workers run dry, so a window of a few items per worker keeps them busy through the usual differences in cost.
+// The results are annotated with the index of the worker's stats as Worker. The first error (a panic in work included, as a PanicError)
+// is reported on the returned error channel and stops every worker
+func OrderedWorkers[In, Out any](ctx context.Context, valueStream <-chan In, work func(In) (Out, bool, error), stats []*Stats, window int, opts ...Option) (<-chan Found[Out], <-chan error) {
+	o := applyOptions(opts)
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	errc := make(chan error, 1)
+	var once sync.Once
+	fail := func(err error) {
+		once.Do(func() {
+			reportError(parent, errc, err)
+			cancel()
+		})
+	}
+
+	// A token is taken for each item read and given back once its result is out of the reorder buffer
+	tokens := make(chan struct{}, max(window, 1))
+	jobs := make(chan sequenced[In])
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "sequencer", "window", cap(tokens))()
+		defer close(jobs)
+		for seq := int64(0); ; seq++ {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case tokens <- struct{}{}:
+			}
+			if !send(ctx, o, jobs, sequenced[In]{seq: seq, item: item}) {
+				return
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	results := make(chan orderedResult[Out])
+	for worker, stats := range stats {
+		wg.Add(1)
+		o.spawn(func() {
+			defer wg.Done()
+			defer logLifetime(ctx, o.logger, "ordered worker", "worker", worker)()
+			var lastTested int64
+			for {
+				job, ok := receive(ctx, o, jobs)
+				if !ok {
+					return
+				}
+				var result Out
+				found, err := runTest(job.item, func(item In) (bool, error) {
+					var keep bool
+					var err error
+					result, keep, err = work(item)
+					return keep, err
+				}, stats)
+				if err != nil {
+					fail(err)
+					return
+				}
+				done := orderedResult[Out]{seq: job.seq, keep: found}
+				if found {
+					tested := stats.Tested.Load()
+					done.found = Found[Out]{Value: result, Worker: worker, At: o.clock.Now(), Attempts: tested - lastTested}
+					lastTested = tested
+				}
+				if !send(ctx, o, results, done) {
+					return
+				}
+			}
+		})
+	}
+	o.spawn(func() {
+		wg.Wait()
+		close(results)
+		close(errc)
+	})
+
+	orderedStream := make(chan Found[Out], o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "reorder buffer")()
+		defer close(orderedStream)
+		defer cancel()
+		pending := make(map[int64]orderedResult[Out], cap(tokens))
+		var next int64
+		for {
+			done, ok := receive(ctx, o, results)
+			if !ok {
+				return
+			}
+			pending[done.seq] = done
+			for {
+				done, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-tokens
+				if done.keep && !send(ctx, o, orderedStream, done.found) {
+					return
+				}
+			}
+		}
+	})
+	return orderedStream, errc
+}
@@ -0,0 +1,314 @@
+// Package pipeline contains the building blocks of a fan-out/fan-in channel pipeline.
+//
+// Each stage is a function that starts a goroutine and returns the channel it writes to.
+// Stages are generic over the item type, so a pipeline is type-safe end to end without boxing values in interface{}.
+// Every stage takes a context, cancelling it (or reaching its deadline) stops all stages of the pipeline.
+// Stages also take trailing Options, such as WithBuffer to size the channel the stage writes to.
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// CreateResultStream gets a stream containing the number of specified items from a given input stream (number of prime numbers to generate in our usage).
+//
+// Deprecated: CreateResultStream is Take, use Take instead
+func CreateResultStream[T any](ctx context.Context, valueStream <-chan T, num int, opts ...Option) <-chan T {
+	return Take(ctx, valueStream, num, opts...)
+}
+
+// ReduceWorkers takes a set of channels (worker channels containing prime numbers in our usage) and multiplexes their streams into a single stream.
+// The items are forwarded as they're ready, unless WithPriority is given to forward the highest priority items first when the output is contended
+func ReduceWorkers[T any](ctx context.Context, channels []<-chan T, opts ...Option) <-chan T {
+	var wg sync.WaitGroup
+	o := applyOptions(opts)
+	if o.priority != nil {
+		return reducePriority(ctx, channels, o)
+	}
+	reducedStream := make(chan T, o.buffer)
+	logStopped := logLifetime(ctx, o.logger, "reduce", "channels", len(channels))
+
+	// Forwards output of given channel to one stream
+	reduceChan := func(workerChannel <-chan T) {
+		defer wg.Done()
+		for {
+			item, ok := receive(ctx, o, workerChannel)
+			if !ok || !send(ctx, o, reducedStream, item) {
+				return
+			}
+		}
+	}
+
+	// Combine output of all worker channels. Wait until all items are processed
+	wg.Add(len(channels))
+	for _, wc := range channels {
+		o.spawn(func() { reduceChan(wc) })
+	}
+	o.spawn(func() {
+		defer logStopped()
+		wg.Wait()
+		close(reducedStream)
+	})
+
+	return reducedStream
+}
+
+// RoundRobin multiplexes a set of channels into a single stream by taking one item from each channel in turn.
+// Unlike ReduceWorkers, the output order only depends on the contents of the input streams (so seeded workers give reproducible results), at the cost of a slow channel holding the others back.
+// A closed channel is skipped, and the stream is closed once all of them are
+func RoundRobin[T any](ctx context.Context, channels []<-chan T, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	orderedStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "round robin", "channels", len(channels))()
+		defer close(orderedStream)
+		open := append([]<-chan T(nil), channels...)
+		for len(open) > 0 {
+			for i := 0; i < len(open); i++ {
+				item, ok := receive(ctx, o, open[i])
+				if ctx.Err() != nil {
+					return
+				}
+				if !ok {
+					// Drop the closed channel, keeping the order of the rest
+					open = append(open[:i], open[i+1:]...)
+					i--
+					continue
+				}
+				if !send(ctx, o, orderedStream, item) {
+					return
+				}
+			}
+		}
+	})
+	return orderedStream
+}
+
+// MergeSorted multiplexes a set of channels whose items each come in ascending order (by less) into a single stream in ascending order,
+// such as the workers of a SegmentedSieve. It holds the next item of every open channel and forwards the least, so it waits for each channel
+// to send an item or close before forwarding anything: a slow channel holds the others back, each of them blocked on its next item.
+// Items that are equal are forwarded in the order of their channels
+func MergeSorted[T any](ctx context.Context, channels []<-chan T, less func(a, b T) bool, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	mergedStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "merge sorted", "channels", len(channels))()
+		defer close(mergedStream)
+		open := append([]<-chan T(nil), channels...)
+		heads := make([]T, 0, len(open))
+		// Take the first item of each channel, dropping those closed before sending any
+		for i := 0; i < len(open); i++ {
+			item, ok := receive(ctx, o, open[i])
+			if ctx.Err() != nil {
+				return
+			}
+			if !ok {
+				open = append(open[:i], open[i+1:]...)
+				i--
+				continue
+			}
+			heads = append(heads, item)
+		}
+		for len(open) > 0 {
+			least := 0
+			for i := 1; i < len(heads); i++ {
+				if less(heads[i], heads[least]) {
+					least = i
+				}
+			}
+			if !send(ctx, o, mergedStream, heads[least]) {
+				return
+			}
+			item, ok := receive(ctx, o, open[least])
+			if ctx.Err() != nil {
+				return
+			}
+			if !ok {
+				open = append(open[:least], open[least+1:]...)
+				heads = append(heads[:least], heads[least+1:]...)
+				continue
+			}
+			heads[least] = item
+		}
+	})
+	return mergedStream
+}
+
+// Tee copies every item of a stream to n output streams, so several consumers (such as a printer and a file sink) each get every item.
+// An item is sent to each output in turn before the next one is read, so the slowest consumer sets the pace of them all,
+// and consumers mustn't wait on each other. A consumer that stops reading must cancel the context, or it holds the others back
+func Tee[T any](ctx context.Context, valueStream <-chan T, n int, opts ...Option) []<-chan T {
+	o := applyOptions(opts)
+	streams := make([]chan T, n)
+	outs := make([]<-chan T, n)
+	for i := range streams {
+		streams[i] = make(chan T, o.buffer)
+		outs[i] = streams[i]
+	}
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "tee", "outputs", n)()
+		defer func() {
+			for _, stream := range streams {
+				close(stream)
+			}
+		}()
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			for _, stream := range streams {
+				if !send(ctx, o, stream, item) {
+					return
+				}
+			}
+		}
+	})
+	return outs
+}
+
+// Shard splits a stream into n streams by key, sending each item to stream key(item) % n, so every item with the same key goes to the same stream.
+// Giving each worker a shard spares them from competing to receive on one shared stream, and makes per-shard state (such as a DistinctBy per shard)
+// safe without a lock, since no two shards see the same item. The shards only balance as well as the keys spread: a key that isn't evenly spread
+// (such as the value itself when most values are odd) leaves some shards idle. An item waits for its shard to take it, holding back the items behind it
+func Shard[T any](ctx context.Context, valueStream <-chan T, n int, key func(T) uint64, opts ...Option) []<-chan T {
+	o := applyOptions(opts)
+	n = max(n, 1)
+	shards := make([]chan T, n)
+	outs := make([]<-chan T, n)
+	for i := range shards {
+		shards[i] = make(chan T, o.buffer)
+		outs[i] = shards[i]
+	}
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "shard", "shards", n)()
+		defer func() {
+			for _, shard := range shards {
+				close(shard)
+			}
+		}()
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok || !send(ctx, o, shards[key(item)%uint64(n)], item) {
+				return
+			}
+		}
+	})
+	return outs
+}
+
+// Mix64 scrambles the bits of a key with the finalizer of SplitMix64, so keys that are close together (such as consecutive numbers,
+// or the hashes of strings differing in their last character) spread evenly once taken modulo a number of shards
+func Mix64(key uint64) uint64 {
+	key = (key ^ (key >> 30)) * 0xbf58476d1ce4e5b9
+	key = (key ^ (key >> 27)) * 0x94d049bb133111eb
+	return key ^ (key >> 31)
+}
+
+// Take forwards the first num items of a stream and then closes its output, such as the number of prime numbers to generate.
+// It stops reading once it has them, the stages before it are left blocked until the context is cancelled
+func Take[T any](ctx context.Context, valueStream <-chan T, num int, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	takenStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "take", "num", num)()
+		defer close(takenStream)
+		for i := 0; i < num; i++ {
+			// Wait on the input as well as the output, so a cancelled context isn't stuck behind a slow upstream stage
+			item, ok := receive(ctx, o, valueStream)
+			if !ok || !send(ctx, o, takenStream, item) {
+				return
+			}
+		}
+	})
+	return takenStream
+}
+
+// Skip drops the first num items of a stream and forwards the rest
+func Skip[T any](ctx context.Context, valueStream <-chan T, num int, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	skippedStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "skip", "num", num)()
+		defer close(skippedStream)
+		for i := 0; ; i++ {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			if i < num {
+				o.discard(item)
+				continue
+			}
+			if !send(ctx, o, skippedStream, item) {
+				return
+			}
+		}
+	})
+	return skippedStream
+}
+
+// Filter forwards the items of a stream that pass the keep test, in the same order. Items that fail it are passed to the stage's WithDiscard function.
+// Unlike FilterWorker the test can't fail and isn't counted in Stats, which suits cheap tests (FilterWorker is the one to fan out for expensive ones such as a primality test)
+func Filter[T any](ctx context.Context, valueStream <-chan T, keep func(T) bool, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	filteredStream := make(chan T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "filter")()
+		defer close(filteredStream)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			if !keep(item) {
+				o.discard(item)
+				continue
+			}
+			if !send(ctx, o, filteredStream, item) {
+				return
+			}
+		}
+	})
+	return filteredStream
+}
+
+// FlatMap applies fn to every item of a stream and sends each item of the slice it returns downstream, in order (none if the slice is empty)
+func FlatMap[In, Out any](ctx context.Context, valueStream <-chan In, fn func(In) []Out, opts ...Option) <-chan Out {
+	o := applyOptions(opts)
+	flatStream := make(chan Out, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "flat map")()
+		defer close(flatStream)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			for _, out := range fn(item) {
+				if !send(ctx, o, flatStream, out) {
+					return
+				}
+			}
+		}
+	})
+	return flatStream
+}
+
+// Map applies fn to every item of a stream, sending the results downstream in the same order
+func Map[In, Out any](ctx context.Context, valueStream <-chan In, fn func(In) Out, opts ...Option) <-chan Out {
+	o := applyOptions(opts)
+	mappedStream := make(chan Out, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "map")()
+		defer close(mappedStream)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok || !send(ctx, o, mappedStream, fn(item)) {
+				return
+			}
+		}
+	})
+	return mappedStream
+}
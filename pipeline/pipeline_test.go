@@ -0,0 +1,67 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/pipeline/pipelinetest"
+)
+
+// odd is a test cheap enough for the benchmarks to measure the stages rather than the test, passing every other candidate
+func odd(num int64) bool {
+	return num&1 == 1
+}
+
+// runStages takes num items out of a generator feeding a worker running odd, reporting the allocations made per item taken
+func runStages(b *testing.B, num int, opts ...pipeline.Option) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.ReportAllocs()
+	b.ResetTimer()
+	valueStream, _ := pipeline.CreateValueStream(ctx, pipeline.SequentialVal(1<<62), opts...)
+	primeStream, _ := pipeline.PrimeNumberWorker(ctx, valueStream, odd, nil, opts...)
+	for range pipeline.Take(ctx, primeStream, num, opts...) {
+	}
+}
+
+// BenchmarkStages runs the generic stages Generator → Worker → Take, whose channels carry int64s rather than boxing each of them in an interface{}
+func BenchmarkStages(b *testing.B) {
+	runStages(b, b.N)
+}
+
+// BenchmarkStagesBoxed runs the stages of BenchmarkStages carrying interface{} values, as the stages did before they were generic:
+// every candidate is boxed by the generator and asserted back to an int64 by the worker. Compare it with BenchmarkStages for the cost of the boxing
+func BenchmarkStagesBoxed(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.ReportAllocs()
+	b.ResetTimer()
+	next := pipeline.SequentialVal(1 << 62)
+	valueStream, _ := pipeline.CreateValueStream(ctx, func() (any, error) { return next() })
+	primeStream, _ := pipeline.FilterWorker(ctx, valueStream, func(val any) (bool, error) { return odd(val.(int64)), nil }, nil)
+	for range pipeline.Take(ctx, primeStream, b.N) {
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	defer pipelinetest.CheckLeaks(t)()
+	ctx := context.Background()
+	channels := []<-chan int{
+		pipelinetest.Feed(ctx, 1, 4, 9),
+		pipelinetest.Feed[int](ctx),
+		pipelinetest.Feed(ctx, 2, 3, 10, 11),
+		pipelinetest.Feed(ctx, 5),
+	}
+	merged := pipeline.MergeSorted(ctx, channels, func(a, b int) bool { return a < b })
+	pipelinetest.Expect(t, merged, 1, 2, 3, 4, 5, 9, 10, 11)
+}
+
+func TestMergeSortedCancelled(t *testing.T) {
+	defer pipelinetest.CheckLeaks(t)()
+	ctx, cancel := context.WithCancel(context.Background())
+	merged := pipeline.MergeSorted(ctx, []<-chan int{pipelinetest.Feed(ctx, 1, 2), make(chan int)}, func(a, b int) bool { return a < b })
+	// The channel that never sends holds the merge back, until it's cancelled
+	cancel()
+	pipelinetest.Expect(t, merged)
+}
@@ -0,0 +1,88 @@
+package pipelinetest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// Clock is a pipeline.Clock that only moves when Advance is called, firing the timers that are due.
+// Stages start their timers from their own goroutines, so a test waits for them with BlockUntil before advancing the clock past them
+type Clock struct {
+	mu      sync.Mutex
+	changed *sync.Cond // Broadcast whenever a timer is started
+	now     time.Time
+	timers  []*timer
+}
+
+// NewClock returns a clock reading start until it's advanced
+func NewClock(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTimer(d time.Duration) pipeline.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.changed.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers due by then in the order they're due
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	slices.SortStableFunc(c.timers, func(a, b *timer) int { return a.at.Compare(b.at) })
+	for len(c.timers) > 0 && !c.timers[0].at.After(c.now) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		t.c <- t.at
+	}
+}
+
+// BlockUntil waits until n timers are started and haven't fired or been stopped
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// timer is a timer of the fake clock. Its channel has room for the one time it sends, so firing it never blocks the clock
+type timer struct {
+	clock *Clock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	i := slices.Index(t.clock.timers, t)
+	if i < 0 {
+		return false
+	}
+	t.clock.timers = slices.Delete(t.clock.timers, i, i+1)
+	return true
+}
@@ -0,0 +1,50 @@
+package pipelinetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline/pipelinetest"
+)
+
+func TestClockFiresDueTimers(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := pipelinetest.NewClock(start)
+	early, late := clock.NewTimer(time.Second), clock.NewTimer(time.Minute)
+	clock.Advance(30 * time.Second)
+	select {
+	case at := <-early.C():
+		if want := start.Add(time.Second); !at.Equal(want) {
+			t.Errorf("timer fired at %v, want %v", at, want)
+		}
+	default:
+		t.Fatalf("timer due after a second didn't fire once the clock moved 30s")
+	}
+	select {
+	case <-late.C():
+		t.Fatalf("timer due after a minute fired once the clock moved 30s")
+	default:
+	}
+	if !late.Stop() {
+		t.Errorf("Stop returned false for a timer that hadn't fired")
+	}
+	if early.Stop() {
+		t.Errorf("Stop returned true for a timer that had fired")
+	}
+	if got, want := clock.Now(), start.Add(30*time.Second); !got.Equal(want) {
+		t.Errorf("Now returned %v, want %v", got, want)
+	}
+}
+
+func TestClockBlockUntil(t *testing.T) {
+	clock := pipelinetest.NewClock(time.Time{})
+	fired := make(chan time.Time)
+	go func() {
+		fired <- <-clock.NewTimer(time.Second).C()
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if at := <-fired; !at.Equal(time.Time{}.Add(time.Second)) {
+		t.Errorf("timer fired at %v, want a second in", at)
+	}
+}
@@ -0,0 +1,24 @@
+package pipelinetest
+
+import "sync"
+
+// Rand is a pipeline.Rand returning scripted values in order, starting over once they've all been returned.
+// Each value is taken modulo the n it's drawn for, so a value below n is returned as is. It's safe for concurrent use
+type Rand struct {
+	mu     sync.Mutex
+	values []int64
+	next   int
+}
+
+// NewRand returns a Rand returning the given values, which mustn't be empty or negative
+func NewRand(values ...int64) *Rand {
+	return &Rand{values: values}
+}
+
+func (r *Rand) Int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	val := r.values[r.next] % n
+	r.next = (r.next + 1) % len(r.values)
+	return val
+}
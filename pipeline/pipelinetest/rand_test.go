@@ -0,0 +1,19 @@
+package pipelinetest_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline/pipelinetest"
+)
+
+func TestRandRepeatsValues(t *testing.T) {
+	r := pipelinetest.NewRand(3, 12, 5)
+	var got []int64
+	for range 4 {
+		got = append(got, r.Int63n(10))
+	}
+	if want := []int64{3, 2, 5, 3}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+// Package pipelinetest drives the stages of the pipeline package with scripted inputs, a fake clock and a scripted source of random ints,
+// and checks what they output and that they leave no goroutine running, so they can be tested without depending on timing or scheduling
+package pipelinetest
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// collectTimeout is how long Collect waits for a stream to close before failing the test.
+// It only stops a test from hanging on a stage that never closes its output, it isn't relied on to order anything
+const collectTimeout = 10 * time.Second
+
+// leakTimeout is how long CheckLeaks gives the goroutines started by a test to exit
+const leakTimeout = time.Second
+
+// TB is the part of testing.TB the helpers report failures to
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
+
+// Feed returns a stream sending the given items in order and then closed, the input of the stage under test.
+// The stream is unbuffered, so every item has been taken by the stage once the stream is closed
+func Feed[T any](ctx context.Context, items ...T) <-chan T {
+	valueStream := make(chan T)
+	go func() {
+		defer close(valueStream)
+		for _, item := range items {
+			select {
+			case <-ctx.Done():
+				return
+			case valueStream <- item:
+			}
+		}
+	}()
+	return valueStream
+}
+
+// Collect reads a stream until it's closed and returns its items, failing the test if it isn't closed in time
+func Collect[T any](t TB, valueStream <-chan T) []T {
+	t.Helper()
+	var items []T
+	timeout := time.After(collectTimeout)
+	for {
+		select {
+		case item, ok := <-valueStream:
+			if !ok {
+				return items
+			}
+			items = append(items, item)
+		case <-timeout:
+			t.Fatalf("stream not closed after %v, got %v so far", collectTimeout, items)
+			return items
+		}
+	}
+}
+
+// Next reads the next item of a stream, failing the test if the stream is closed or nothing is sent in time
+func Next[T any](t TB, valueStream <-chan T) T {
+	t.Helper()
+	select {
+	case item, ok := <-valueStream:
+		if !ok {
+			t.Fatalf("stream closed, want another item")
+		}
+		return item
+	case <-time.After(collectTimeout):
+		t.Fatalf("no item after %v", collectTimeout)
+	}
+	var zero T
+	return zero
+}
+
+// Expect reads a stream until it's closed, failing the test unless it sent the wanted items in order
+func Expect[T comparable](t TB, valueStream <-chan T, want ...T) {
+	t.Helper()
+	if got := Collect(t, valueStream); !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// ExpectNoError reads an error channel until it's closed, failing the test on the first error
+func ExpectNoError(t TB, errc <-chan error) {
+	t.Helper()
+	for _, err := range Collect(t, errc) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// CheckLeaks snapshots the goroutines running and returns a function failing the test if any goroutine started since is still running
+// once it's called, after giving them leakTimeout to exit. It's deferred at the start of a test, before the stages under test are started:
+//
+//	defer pipelinetest.CheckLeaks(t)()
+func CheckLeaks(t TB) func() {
+	t.Helper()
+	goroutines := pipeline.SnapshotGoroutines()
+	return func() {
+		t.Helper()
+		if leaked := goroutines.Leaked(leakTimeout); len(leaked) > 0 {
+			t.Fatalf("%d goroutines still running after %v:\n\n%s", len(leaked), leakTimeout, strings.Join(leaked, "\n\n"))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/pipeline/pipelinetest"
+)
+
+// recorder is a pipelinetest.TB recording the failures reported to it rather than failing the test, so the helpers' checks can be tested
+type recorder struct {
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestFeedExpect(t *testing.T) {
+	defer pipelinetest.CheckLeaks(t)()
+	ctx := context.Background()
+	doubled := pipeline.Map(ctx, pipelinetest.Feed(ctx, 1, 2, 3), func(n int) int { return 2 * n })
+	pipelinetest.Expect(t, doubled, 2, 4, 6)
+}
+
+func TestExpectReportsMismatch(t *testing.T) {
+	ctx := context.Background()
+	var r recorder
+	pipelinetest.Expect(&r, pipelinetest.Feed(ctx, 1, 2), 1, 3)
+	if len(r.failures) != 1 {
+		t.Fatalf("got failures %q, want one for the mismatch", r.failures)
+	}
+}
+
+func TestNext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer pipelinetest.CheckLeaks(t)()
+	defer cancel()
+	stream := pipelinetest.Feed(ctx, "a", "b")
+	if got := pipelinetest.Next(t, stream); got != "a" {
+		t.Fatalf("got %q, want %q", got, "a")
+	}
+	var r recorder
+	pipelinetest.Next(&r, stream)
+	pipelinetest.Next(&r, stream)
+	if len(r.failures) != 1 {
+		t.Fatalf("got failures %q, want one for the closed stream", r.failures)
+	}
+}
+
+func TestExpectNoError(t *testing.T) {
+	ctx := context.Background()
+	pipelinetest.ExpectNoError(t, pipelinetest.Feed[error](ctx))
+	var r recorder
+	pipelinetest.ExpectNoError(&r, pipelinetest.Feed(ctx, errors.New("failed")))
+	if len(r.failures) != 1 {
+		t.Fatalf("got failures %q, want one for the error", r.failures)
+	}
+}
+
+func TestCheckLeaksReportsBlockedStage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var r recorder
+	check := pipelinetest.CheckLeaks(&r)
+	// Nothing reads the stage's output, so it stays blocked until it's cancelled
+	pipeline.Map(ctx, pipelinetest.Feed(ctx, 1), func(n int) int { return n })
+	check()
+	cancel()
+	if len(r.failures) != 1 {
+		t.Fatalf("got failures %q, want one for the blocked stage", r.failures)
+	}
+	pipelinetest.CheckLeaks(t)()
+}
@@ -0,0 +1,228 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pool is a set of prime number workers that can be resized while the pipeline is running.
+// Workers all read from one input stream and write to one output stream, so no fan-in is needed when workers come and go
+type Pool struct {
+	ctx  context.Context
+	in   <-chan int64
+	out  chan Found[int64]
+	errc chan error
+	keep func(int64) (bool, error)
+	opts stageOptions
+
+	mu      sync.Mutex
+	stops   []chan struct{} // One per running worker, closed to stop it
+	stats   []*Stats        // One per worker started, including stopped ones
+	stopped bool            // Set once the pool's streams are being closed, no workers can be added after that
+	wg      sync.WaitGroup
+
+	inputDone chan struct{} // Closed once a worker sees the input stream close
+	inputOnce sync.Once
+	idle      atomic.Int64 // Nanoseconds workers spent waiting for input, read by the autoscaler
+
+	history []ScaleEvent
+}
+
+// ScaleEvent records a change to the number of workers in a pool
+type ScaleEvent struct {
+	At      time.Duration // Time since the pool was started
+	Workers int
+	Rate    float64 // Numbers tested per second over the interval that led to the change
+	Reason  string  // Why the controller made the change, empty for the initial size
+}
+
+// NewPool starts a pool of size workers that get prime numbers from intStream, checking each number with the given test.
+// The pool's output stream is closed when the input stream closes or the context is cancelled, and the first error reported by a worker is sent on Errors
+func NewPool(ctx context.Context, intStream <-chan int64, isPrime PrimalityTest, size int, opts ...Option) *Pool {
+	o := applyOptions(opts)
+	p := &Pool{
+		ctx:       ctx,
+		in:        intStream,
+		out:       make(chan Found[int64], o.buffer),
+		opts:      o,
+		errc:      make(chan error, 1),
+		keep:      primeFilter(isPrime),
+		inputDone: make(chan struct{}),
+	}
+	p.history = []ScaleEvent{{Workers: size}}
+	p.Resize(size)
+
+	// Close the streams once the input is used up (or the pipeline is cancelled) and every worker has returned
+	p.opts.spawn(func() {
+		select {
+		case <-ctx.Done():
+		case <-p.inputDone:
+		}
+		p.mu.Lock()
+		p.stopped = true
+		p.mu.Unlock()
+		p.wg.Wait()
+		close(p.out)
+		close(p.errc)
+	})
+	return p
+}
+
+// Out returns the stream of prime numbers found by the pool's workers. Workers are numbered in the order the pool started them, as in Stats
+func (p *Pool) Out() <-chan Found[int64] {
+	return p.out
+}
+
+// Errors returns the pool's error channel, which carries at most one error
+func (p *Pool) Errors() <-chan error {
+	return p.errc
+}
+
+// Size returns the number of running workers
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+// Resize starts or stops workers until the pool has size of them. A stopped worker finishes the number it is testing first
+func (p *Pool) Resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	for len(p.stops) < size {
+		stop := make(chan struct{})
+		stats := new(Stats)
+		p.stops = append(p.stops, stop)
+		p.stats = append(p.stats, stats)
+		worker := len(p.stats) - 1
+		p.wg.Add(1)
+		p.opts.spawn(func() { p.work(worker, stop, stats) })
+	}
+	for len(p.stops) > max(size, 0) {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+// Stats returns the counters of every worker the pool has started, including those it has since stopped
+func (p *Pool) Stats() []*Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Stats(nil), p.stats...)
+}
+
+// tested returns the numbers checked by all of the pool's workers
+func (p *Pool) tested() int64 {
+	var total int64
+	for _, stats := range p.Stats() {
+		total += stats.Tested.Load()
+	}
+	return total
+}
+
+// History returns the changes made to the pool size by Autoscale, starting with the initial size
+func (p *Pool) History() []ScaleEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ScaleEvent(nil), p.history...)
+}
+
+// work is the loop run by each of the pool's workers
+func (p *Pool) work(worker int, stop <-chan struct{}, stats *Stats) {
+	defer p.wg.Done()
+	defer logLifetime(p.ctx, p.opts.logger, "pool worker", "worker", worker)()
+	var lastTested int64
+	for {
+		waitStart := time.Now()
+		var num int64
+		var ok bool
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-stop:
+			return
+		case num, ok = <-p.in:
+		}
+		p.idle.Add(int64(time.Since(waitStart)))
+		if p.opts.flow != nil {
+			p.opts.flow.RecvBlocked.Add(int64(time.Since(waitStart)))
+		}
+		if !ok {
+			p.inputOnce.Do(func() { close(p.inputDone) })
+			return
+		}
+		found, err := runTest(num, p.keep, stats)
+		if err == nil && found {
+			tested := stats.Tested.Load()
+			err = sendItem(p.ctx, p.opts, Found[int64]{Value: num, Worker: worker, At: time.Now(), Attempts: tested - lastTested}, stats, p.out)
+			lastTested = tested
+		}
+		if err != nil {
+			reportError(p.ctx, p.errc, err)
+			return
+		}
+	}
+}
+
+// Autoscale starts a controller that resizes the pool between minWorkers and maxWorkers, checking the pool's throughput every interval.
+// Workers that spend most of the interval waiting for input mean the pool is larger than the producers can feed, so a worker is removed.
+// Workers that are busy for most of the interval mean more could help, so a worker is added as long as the last one added raised the throughput.
+// When an added worker doesn't help it is removed again, and the pool isn't grown past that size from then on.
+func (p *Pool) Autoscale(minWorkers, maxWorkers int, interval time.Duration) {
+	p.opts.spawn(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		start := time.Now()
+		lastTested := p.tested()
+		lastIdle := p.idle.Load()
+		var rateBeforeAdd float64 // Throughput before the last worker was added, 0 if the last change wasn't an addition
+		ceiling := maxWorkers
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-p.inputDone:
+				return
+			case <-ticker.C:
+			}
+
+			tested, idle := p.tested(), p.idle.Load()
+			rate := float64(tested-lastTested) / interval.Seconds()
+			size := p.Size()
+			idleFraction := float64(idle-lastIdle) / float64(int64(interval)*int64(max(size, 1)))
+			lastTested, lastIdle = tested, idle
+
+			next, reason := size, ""
+			switch {
+			case rateBeforeAdd > 0 && rate < rateBeforeAdd*1.05:
+				// The last worker added didn't help, take it back out
+				next, reason = size-1, "no gain"
+				ceiling = next
+			case idleFraction > 0.5:
+				next, reason = size-1, "waiting for input"
+			case idleFraction < 0.3:
+				next, reason = size+1, "busy"
+			}
+			next = min(max(next, minWorkers), max(ceiling, minWorkers))
+			rateBeforeAdd = 0
+			if next > size {
+				rateBeforeAdd = rate
+			}
+			if next == size {
+				continue
+			}
+
+			p.opts.logger.Debug("autoscaling pool", "from", size, "to", next, "rate", rate, "idle", idleFraction)
+			p.Resize(next)
+			p.mu.Lock()
+			p.history = append(p.history, ScaleEvent{At: time.Since(start), Workers: next, Rate: rate, Reason: reason})
+			p.mu.Unlock()
+		}
+	})
+}
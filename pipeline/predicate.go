@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"strconv"
+)
+
+// PredicateWorker reads an input stream and outputs the items the predicate holds for, generalizing PrimeNumberWorker to other CPU-bound tests
+// (such as PerfectSquare or Palindrome). The predicate can't fail, so unlike FilterWorker there's no error channel.
+// The worker's progress is added to stats, which may be nil
+func PredicateWorker[T any](ctx context.Context, valueStream <-chan T, predicate func(T) bool, stats *Stats, opts ...Option) <-chan T {
+	keptStream, _ := FilterWorker(ctx, valueStream, func(item T) (bool, error) { return predicate(item), nil }, stats, opts...)
+	return keptStream
+}
+
+// PerfectSquare reports whether a number is the square of an integer
+func PerfectSquare(num int64) bool {
+	if num < 0 {
+		return false
+	}
+	// The float square root can be off by one for large numbers, so it's corrected with exact integer arithmetic (in uint64, which (root+1)^2 fits in)
+	root := uint64(math.Sqrt(float64(num)))
+	n := uint64(num)
+	for root*root > n {
+		root--
+	}
+	for (root+1)*(root+1) <= n {
+		root++
+	}
+	return root*root == n
+}
+
+// Palindrome reports whether a number reads the same backwards in base 10. Negative numbers aren't palindromes
+func Palindrome(num int64) bool {
+	if num < 0 {
+		return false
+	}
+	digits := strconv.FormatInt(num, 10)
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		if digits[i] != digits[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// TwinPrime returns a test reporting whether a number is the lower prime of a pair of twin primes (p and p+2 both prime), with isPrime testing both.
+// The test of p+2 only runs once p passed, so it costs little more than isPrime on candidates that aren't prime
+func TwinPrime(isPrime PrimalityTest) PrimalityTest {
+	return func(num int64) bool {
+		// p+2 would overflow past the largest int64, which isn't prime anyway
+		return num <= math.MaxInt64-2 && isPrime(num) && isPrime(num+2)
+	}
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import "math/bits"
+
+// PrimalityTest reports whether a number is prime
+type PrimalityTest func(num int64) bool
+
+// scratchInts are the big.Ints ProbablyPrime tests numbers with, reused rather than allocating one per number tested
+var scratchInts = NewBigPool()
+
+// ProbablyPrime returns a PrimalityTest that runs the given number of Miller-Rabin rounds with random bases, as well as the Baillie-PSW test done by big.Int.
+// More rounds lower the chance of a composite number being reported as prime, at the cost of speed
+func ProbablyPrime(rounds int) PrimalityTest {
+	return func(num int64) bool {
+		n := scratchInts.Get()
+		defer scratchInts.Put(n)
+		return n.SetInt64(num).ProbablyPrime(rounds)
+	}
+}
+
+// trialPrimes are the odd primes up to trialDivisionLimit TrialDivision divides numbers by, computed once at startup.
+// Every number below the limit's square, a million, is settled by them alone
+var trialPrimes = smallPrimes(trialDivisionLimit)[1:]
+
+// TrialDivision returns a PrimalityTest that settles what it can cheaply before running test: an even number is only prime if it's 2,
+// and an odd one is divided by the odd primes up to trialDivisionLimit. Most composites have one of them as a factor, and a number
+// with none of them below its square root is prime, so test only runs on the large numbers left, most of them prime.
+// Negative numbers are passed on to test, which decides what they are
+func TrialDivision(test PrimalityTest) PrimalityTest {
+	return func(num int64) bool {
+		switch {
+		case num < 0:
+			return test(num)
+		case num < 2:
+			return false
+		case num&1 == 0:
+			return num == 2
+		}
+		for _, p := range trialPrimes {
+			if p*p > num {
+				return true
+			}
+			if num%p == 0 {
+				return false
+			}
+		}
+		return test(num)
+	}
+}
+
+// millerRabinBases are enough witnesses for Miller-Rabin to give a proven answer for every number below 3.3*10^24, which covers int64
+var millerRabinBases = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+// DeterministicPrime is a PrimalityTest that is proven correct over the int64 range. It runs Miller-Rabin with a fixed set of bases instead of random ones
+func DeterministicPrime(num int64) bool {
+	if num < 2 {
+		return false
+	}
+	n := uint64(num)
+	for _, p := range millerRabinBases {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+
+	// Write n-1 as d*2^s with d odd
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= s
+
+	for _, a := range millerRabinBases {
+		x := powMod(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+		composite := true
+		for i := 1; i < s; i++ {
+			x = mulMod(x, x, n)
+			if x == n-1 {
+				composite = false
+				break
+			}
+		}
+		if composite {
+			return false
+		}
+	}
+	return true
+}
+
+// mulMod returns a*b mod m without overflowing, using the full 128 bit product
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi%m, lo, m)
+	return rem
+}
+
+// powMod returns base^exp mod m by repeated squaring
+func powMod(base, exp, m uint64) uint64 {
+	result := uint64(1)
+	base %= m
+	for exp > 0 {
+		if exp&1 == 1 {
+			result = mulMod(result, base, m)
+		}
+		base = mulMod(base, base, m)
+		exp >>= 1
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package pipeline_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// BenchmarkProbablyPrime tests odd candidates near 2^62 from every CPU at once, as the workers of a large run do, with ProbablyPrime's pooled
+// big.Ints and with a big.Int allocated per candidate. The allocations left in the pooled run are made inside big.Int.ProbablyPrime
+func BenchmarkProbablyPrime(b *testing.B) {
+	tests := []struct {
+		name string
+		test pipeline.PrimalityTest
+	}{
+		{"pooled", pipeline.ProbablyPrime(0)},
+		{"allocated", func(num int64) bool { return big.NewInt(num).ProbablyPrime(0) }},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for num := int64(1<<62 + 1); pb.Next(); num += 2 {
+					tt.test(num)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// prioritized is an item waiting in a priority fan-in, with the channel it came from and the order it arrived in
+// to keep items of the same priority in order
+type prioritized[T any] struct {
+	item     T
+	from     int
+	priority int
+	seq      int64
+}
+
+// priorityQueue is a heap of the items waiting in a priority fan-in, highest priority first
+type priorityQueue[T any] []prioritized[T]
+
+func (q priorityQueue[T]) Len() int { return len(q) }
+func (q priorityQueue[T]) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q priorityQueue[T]) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *priorityQueue[T]) Push(x any)   { *q = append(*q, x.(prioritized[T])) }
+func (q *priorityQueue[T]) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// reducePriority is ReduceWorkers with the WithPriority option: a goroutine per channel hands its items to a merging goroutine,
+// which sends the highest priority item waiting whenever the output is ready. Each channel has one item waiting at most, and only reads
+// its next one once that item is sent, so a slow consumer still holds the channels back rather than the items piling up,
+// and the channel of the item just sent (such as the one of a high priority worker) is the one to refill its place
+func reducePriority[T any](ctx context.Context, channels []<-chan T, o stageOptions) <-chan T {
+	reducedStream := make(chan T, o.buffer)
+	arrivals := make(chan prioritized[T])
+	sent := make([]chan struct{}, len(channels)) // Signals a channel's goroutine that its item waiting was sent
+	logStopped := logLifetime(ctx, o.logger, "priority reduce", "channels", len(channels))
+
+	var wg sync.WaitGroup
+	wg.Add(len(channels))
+	for i, wc := range channels {
+		sent[i] = make(chan struct{}, 1)
+		o.spawn(func() {
+			defer wg.Done()
+			for {
+				item, ok := receive(ctx, o, wc)
+				if !ok || !send(ctx, o, arrivals, prioritized[T]{item: item, from: i, priority: o.priority(item)}) {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-sent[i]:
+				}
+			}
+		})
+	}
+	o.spawn(func() {
+		wg.Wait()
+		close(arrivals)
+	})
+
+	o.spawn(func() {
+		defer logStopped()
+		defer close(reducedStream)
+		var waiting priorityQueue[T]
+		var seq int64
+		in := arrivals
+		for in != nil || waiting.Len() > 0 {
+			var out chan<- T
+			var next T
+			if waiting.Len() > 0 {
+				out, next = reducedStream, waiting[0].item
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				item.seq = seq
+				seq++
+				heap.Push(&waiting, item)
+			case out <- next:
+				sent[heap.Pop(&waiting).(prioritized[T]).from] <- struct{}{}
+			}
+		}
+	})
+	return reducedStream
+}
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Source produces the candidates of a pipeline from somewhere of the user's choosing, such as a database cursor or a message queue.
+// Next returns the next value, or ErrExhausted once there are none left (any other error fails the pipeline).
+// A pipeline may run several producers on one source, so Next must be safe for concurrent use
+type Source interface {
+	Next() (int64, error)
+}
+
+// SourceFunc adapts a getter, such as the one returned by RandVal, to Source
+type SourceFunc func() (int64, error)
+
+func (f SourceFunc) Next() (int64, error) {
+	return f()
+}
+
+// SourceFactory opens a registered source, given the argument it was selected with (such as a connection string)
+type SourceFactory func(arg string) (Source, error)
+
+var (
+	sourcesMu sync.Mutex
+	sources   = make(map[string]SourceFactory)
+)
+
+// RegisterSource makes a source available under a name, so a program built on the pipeline can select it by name (the CLI does with its source flag).
+// It's meant to be called from an init function, of the program's own packages or of a Go plugin it opens.
+// As with database/sql.Register, registering a name twice panics
+func RegisterSource(name string, factory SourceFactory) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if factory == nil {
+		panic("pipeline: RegisterSource factory is nil")
+	}
+	if _, ok := sources[name]; ok {
+		panic(fmt.Sprintf("pipeline: source %q registered twice", name))
+	}
+	sources[name] = factory
+}
+
+// LookupSource returns the factory registered under name
+func LookupSource(name string) (SourceFactory, bool) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	factory, ok := sources[name]
+	return factory, ok
+}
+
+// Sources returns the names of the registered sources, sorted
+func Sources() []string {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy is how Retry backs off between the restarts of a failing stage. The first restart waits Initial, and each one in a row after it
+// waits Multiplier times longer, up to Max. A stage that got an item through since its last restart has recovered, so its next failure starts over from Initial
+type RetryPolicy struct {
+	MaxRetries int           // Restarts in a row before the stage's error is reported, none if 0
+	Initial    time.Duration // Wait before the first restart
+	Max        time.Duration // Longest wait, unlimited if 0
+	Multiplier float64       // Factor the wait grows by with each restart in a row, 2 if it's 0
+	// Jitter spreads the waits so stages failing together (such as producers sharing a connection) don't all retry at once:
+	// each wait is drawn at random between 1-Jitter times the backoff and the backoff, so from 0 to 1
+	Jitter    float64
+	Retryable func(err error) bool // Whether an error is worth retrying, every error is if nil
+	Rand      Rand                 // Source of the jitter, math/rand/v2's if nil
+}
+
+// backoff returns the wait before the nth restart in a row, counting from 0
+func (p RetryPolicy) backoff(n int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	wait := float64(p.Initial)
+	for range n {
+		wait *= multiplier
+		if p.Max > 0 && wait >= float64(p.Max) {
+			break
+		}
+	}
+	if p.Max > 0 {
+		wait = min(wait, float64(p.Max))
+	}
+	if spread := int64(wait * p.Jitter); spread > 0 {
+		if p.Rand != nil {
+			wait -= float64(p.Rand.Int63n(spread))
+		} else {
+			wait -= float64(rand.Int64N(spread))
+		}
+	}
+	return time.Duration(wait)
+}
+
+// Retry runs a stage started by start, forwarding its output, and starts it again after a backoff (see RetryPolicy) if it stops on an error,
+// so a stage doing flaky I/O (such as a source consuming from a broker) rides out transient failures rather than stopping the pipeline.
+// start is called again for each restart, so the stage must be able to pick up where it left off, such as a source reading on from a shared connection.
+// An error that isn't retryable, or the last one once the retries in a row are used up, is reported on the returned error channel
+func Retry[T any](ctx context.Context, start func() (<-chan T, <-chan error), policy RetryPolicy, opts ...Option) (<-chan T, <-chan error) {
+	o := applyOptions(opts)
+	retriedStream := make(chan T, o.buffer)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "retry", "max_retries", policy.MaxRetries)()
+		defer close(retriedStream)
+		defer close(errc)
+		retries := 0 // Restarts in a row, since the stage last got an item through
+		for {
+			stageStream, stageErrs := start()
+			for {
+				item, ok := receive(ctx, o, stageStream)
+				if !ok {
+					break
+				}
+				if !send(ctx, o, retriedStream, item) {
+					return
+				}
+				retries = 0
+			}
+			// The stage's error is queued before its streams are closed
+			err := <-stageErrs
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			if retries == policy.MaxRetries || (policy.Retryable != nil && !policy.Retryable(err)) {
+				reportError(ctx, errc, err)
+				return
+			}
+			wait := policy.backoff(retries)
+			retries++
+			o.logger.Warn("retrying stage after an error", "err", err, "retry", retries, "max_retries", policy.MaxRetries, "backoff", wait)
+			timer := o.clock.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+		}
+	})
+	return retriedStream, errc
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrStop is returned by the callback of Pipeline.Run to stop the pipeline early without it being an error, Run then returns nil
+var ErrStop = errors.New("pipeline: stop")
+
+// Result is a number found by a Pipeline, with the worker that found it
+type Result = Found[int64]
+
+// Pipeline assembles the stages of the prime number pipeline for programs that want its results without wiring the channels themselves:
+// a value stream drawing from Source, fanned out to Workers FilterWorkers running Test, fanned back in and deduped.
+// The zero value of a field other than Source takes a default
+type Pipeline struct {
+	Source  Source        // Candidates, such as SourceFunc(RandVal(1000000)). Required
+	Test    PrimalityTest // Test a candidate has to pass to be a result, ProbablyPrime(0) if nil
+	Workers int           // Workers running the test, 1 if below 1
+	Limit   int           // Distinct results to find before stopping, 0 to run until the source is exhausted or the context is cancelled
+	Options []Option      // Options of every stage, such as WithBuffer or WithLogger
+}
+
+// Run runs the pipeline, calling fn with each result in the order they're fanned in. fn is called from the goroutine calling Run, one result at a time,
+// so it doesn't need to be safe for concurrent use, and the workers wait on it once the stages' buffers are full.
+// Run cancels every stage once Limit results have been found, the source is exhausted, ctx is cancelled or fn returns an error,
+// and waits for all of them to exit before returning, so no goroutine of the pipeline is left calling the source or the test. A Next that blocks holds it up.
+// It returns fn's error (nil for ErrStop), the first error reported by a stage, or the context's error if ctx was cancelled first
+func (p Pipeline) Run(ctx context.Context, fn func(result Result) error) error {
+	if p.Source == nil {
+		return errors.New("pipeline: Run needs a Source")
+	}
+	isPrime := p.Test
+	if isPrime == nil {
+		isPrime = ProbablyPrime(0)
+	}
+	keep := func(num int64) (bool, error) { return isPrime(num), nil }
+
+	var stages sync.WaitGroup
+	opts := append(slices.Clip(p.Options), WithWaitGroup(&stages))
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	valueStream, sourceErrs := CreateValueStream(ctx, p.Source.Next, opts...)
+	errcs := []<-chan error{sourceErrs}
+	annotated := make([]<-chan Found[int64], max(p.Workers, 1))
+	for i := range annotated {
+		stats := new(Stats)
+		stream, errc := FilterWorker(ctx, valueStream, keep, stats, opts...)
+		annotated[i] = Annotate(ctx, stream, i, stats, opts...)
+		errcs = append(errcs, errc)
+	}
+	resultStream := DistinctBy(ctx, ReduceWorkers(ctx, annotated, opts...), func(found Result) int64 { return found.Value }, 0, opts...)
+	if p.Limit > 0 {
+		resultStream = Take(ctx, resultStream, p.Limit, opts...)
+	}
+	merged := MergeErrors(errcs...)
+	// Stop every stage and wait for them to exit, the merged channel closing once the stages reporting errors have
+	defer func() {
+		cancel()
+		stages.Wait()
+		for range merged {
+		}
+	}()
+
+	errc := merged
+
+	for resultStream != nil {
+		select {
+		case result, ok := <-resultStream:
+			if !ok {
+				resultStream = nil
+				continue
+			}
+			if err := fn(result); errors.Is(err, ErrStop) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		case err, ok := <-errc:
+			if ok {
+				return err
+			}
+			errc = nil
+		}
+	}
+	// The result stream also closes when the context is cancelled, which isn't an error of the stages
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	cancel()
+	if errc != nil {
+		for err := range errc {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/pipeline/pipelinetest"
+)
+
+// exitTimeout is how long the goroutines of a pipeline are given to exit once it's over. Run waits for its stages, so they shouldn't need any of it
+const exitTimeout = time.Second
+
+// checkBaseline fails the test if goroutines started since the snapshot are still running, or if there are more goroutines than before it
+func checkBaseline(t *testing.T, before pipeline.Goroutines, baseline int) {
+	t.Helper()
+	if leaked := before.Leaked(exitTimeout); len(leaked) > 0 {
+		t.Fatalf("%d goroutines still running:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+	}
+	if count := runtime.NumGoroutine(); count > baseline {
+		t.Fatalf("%d goroutines running, want at most the %d running before the pipeline", count, baseline)
+	}
+}
+
+// endless is a source that never runs out, so a run only stops once its limit is reached or it's cancelled
+func endless() pipeline.Source {
+	return pipeline.SourceFunc(pipeline.SequentialVal(math.MaxInt64))
+}
+
+func TestRunReturnsToBaseline(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	before := pipeline.SnapshotGoroutines()
+	p := pipeline.Pipeline{Source: pipeline.SourceFunc(pipeline.SequentialVal(1000)), Workers: 4}
+	var found int
+	if err := p.Run(context.Background(), func(pipeline.Result) error { found++; return nil }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if found != 168 {
+		t.Errorf("found %d primes below 1000, want 168", found)
+	}
+	checkBaseline(t, before, baseline)
+}
+
+func TestRunLimitStopsSource(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	before := pipeline.SnapshotGoroutines()
+	p := pipeline.Pipeline{Source: endless(), Workers: 4, Limit: 10}
+	var found int
+	if err := p.Run(context.Background(), func(pipeline.Result) error { found++; return nil }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if found != 10 {
+		t.Errorf("found %d primes, want the limit of 10", found)
+	}
+	checkBaseline(t, before, baseline)
+}
+
+func TestRunCancelled(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	before := pipeline.SnapshotGoroutines()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := pipeline.Pipeline{Source: endless(), Workers: 4}
+	err := p.Run(ctx, func(pipeline.Result) error { cancel(); return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want %v", err, context.Canceled)
+	}
+	checkBaseline(t, before, baseline)
+}
+
+func TestRunStopped(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	before := pipeline.SnapshotGoroutines()
+	p := pipeline.Pipeline{Source: endless(), Workers: 4}
+	if err := p.Run(context.Background(), func(pipeline.Result) error { return pipeline.ErrStop }); err != nil {
+		t.Fatalf("Run returned %v, want nil for ErrStop", err)
+	}
+	checkBaseline(t, before, baseline)
+}
+
+// TestTakeShortCircuit checks that the stages before a Take left blocked once it has its items all exit when the context is cancelled
+func TestTakeShortCircuit(t *testing.T) {
+	defer pipelinetest.CheckLeaks(t)()
+	var stages sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	valueStream, errc := pipeline.CreateValueStream(ctx, pipeline.SequentialVal(math.MaxInt64), pipeline.WithWaitGroup(&stages))
+	primeStream, workerErrc := pipeline.PrimeNumberWorker(ctx, valueStream, pipeline.DeterministicPrime, nil, pipeline.WithWaitGroup(&stages))
+	pipelinetest.Expect(t, pipeline.Take(ctx, primeStream, 5, pipeline.WithWaitGroup(&stages)), 2, 3, 5, 7, 11)
+	cancel()
+	stages.Wait()
+	pipelinetest.ExpectNoError(t, pipeline.MergeErrors(errc, workerErrc))
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"math/bits"
+	"time"
+)
+
+// SegmentedSieve sieves the numbers from From to To (exclusive) one segment at a time, so its primes can be streamed as each segment is done
+// rather than once the whole range is: Segments hands out the segments in ascending order, and any number of Workers sieve them concurrently,
+// fanned in like the workers of a stream of candidates. Unlike sampling candidates, every prime of the range is found exactly once
+type SegmentedSieve struct {
+	From, To int64
+	Size     int64 // Numbers in a segment, segmentSize if 0
+	Twins    bool  // Find the lower prime of each pair of twin primes, p and p+2 both in the range, rather than every prime
+}
+
+// Segment is a part of the range of a SegmentedSieve, from Low to High (exclusive)
+type Segment struct {
+	Low, High int64
+}
+
+// Segments returns a stream of the segments covering the sieve's range, in ascending order, closed once the range is covered
+func (s SegmentedSieve) Segments(ctx context.Context, opts ...Option) <-chan Segment {
+	o := applyOptions(opts)
+	segmentStream := make(chan Segment, o.buffer)
+	size := s.Size
+	if size <= 0 {
+		size = segmentSize
+	}
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "segments", "from", s.From, "to", s.To)()
+		defer close(segmentStream)
+		for low := max(s.From, 0); low < s.To; low += size {
+			if !send(ctx, o, segmentStream, Segment{Low: low, High: min(low+size, s.To)}) {
+				return
+			}
+		}
+	})
+	return segmentStream
+}
+
+// Worker reads segments from segmentStream and sieves each one, sending on its primes in ascending order.
+// The worker's progress is added to stats, which may be nil, with the segment's numbers counted as tested
+func (s SegmentedSieve) Worker(ctx context.Context, segmentStream <-chan Segment, stats *Stats, opts ...Option) <-chan int64 {
+	o := applyOptions(opts)
+	primeStream := make(chan int64, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "segment worker")()
+		defer close(primeStream)
+		// Primes up to the square root of the limit are enough to mark every composite in the range
+		base := smallPrimes(isqrt(s.To - 1))
+		var composite []uint64
+		var primes []int64
+		for {
+			segment, ok := receive(ctx, o, segmentStream)
+			if !ok {
+				return
+			}
+			testStart := time.Now()
+			// Twin pairs need the two numbers past the segment too, the upper prime of its last pair can be in the next one
+			high := segment.High
+			if s.Twins {
+				high = min(high+2, s.To)
+			}
+			composite = markSegment(composite, segment.Low, high, base)
+			primes = primes[:0]
+			for num := range segmentPrimes(composite, segment.Low, segment.High) {
+				// The range ending before the upper prime of a pair leaves the pair out
+				if upper := num + 2 - segment.Low; s.Twins && (num+2 >= high || composite[upper/64]&(1<<(upper%64)) != 0) {
+					continue
+				}
+				primes = append(primes, num)
+			}
+			if stats != nil {
+				stats.TestTime.Add(int64(time.Since(testStart)))
+				stats.Tested.Add(segment.High - segment.Low)
+				stats.Found.Add(int64(len(primes)))
+			}
+			for _, prime := range primes {
+				if sendItem(ctx, o, prime, stats, primeStream) != nil {
+					return
+				}
+			}
+		}
+	})
+	return primeStream
+}
+
+// markSegment marks the composites from low to high (exclusive) in a bit set of the segment, bit i standing for low+i, reusing composite's array.
+// 0 and 1 are marked too, they aren't prime either
+func markSegment(composite []uint64, low, high int64, base []int64) []uint64 {
+	words := int((high - low + 63) / 64)
+	if cap(composite) < words {
+		composite = make([]uint64, words)
+	}
+	composite = composite[:words]
+	clear(composite)
+	mark := func(num int64) {
+		composite[(num-low)/64] |= 1 << ((num - low) % 64)
+	}
+	for num := low; num < min(high, 2); num++ {
+		mark(num)
+	}
+	for _, p := range base {
+		if p*p >= high {
+			break
+		}
+		// Start at the first multiple of p in the segment, leaving p itself unmarked
+		for num := max(p*p, (low+p-1)/p*p); num < high; num += p {
+			mark(num)
+		}
+	}
+	// The bits past high in the last word stand for numbers outside the segment
+	if rest := (high - low) % 64; rest != 0 {
+		composite[words-1] |= ^uint64(0) << rest
+	}
+	return composite
+}
+
+// segmentPrimes returns an iterator over the primes of a segment's bit set, from low up to high (exclusive)
+func segmentPrimes(composite []uint64, low, high int64) iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		for word, marked := range composite {
+			for primes := ^marked; primes != 0; primes &= primes - 1 {
+				num := low + int64(word)*64 + int64(bits.TrailingZeros64(primes))
+				if num >= high || !yield(num) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pipeline_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+	"github.com/pbangia/go-concurrency-sample/pipeline/pipelinetest"
+)
+
+// segmentedPrimes sieves the range of sieve with the given number of workers, returning the primes merged back into ascending order
+// and the numbers the workers counted as tested
+func segmentedPrimes(t *testing.T, sieve pipeline.SegmentedSieve, workers int) ([]int64, int64) {
+	t.Helper()
+	ctx := context.Background()
+	segmentStream := sieve.Segments(ctx)
+	var stats pipeline.Stats
+	primeStreams := make([]<-chan int64, workers)
+	for i := range primeStreams {
+		primeStreams[i] = sieve.Worker(ctx, segmentStream, &stats)
+	}
+	var primes []int64
+	for prime := range pipeline.MergeSorted(ctx, primeStreams, func(a, b int64) bool { return a < b }) {
+		primes = append(primes, prime)
+	}
+	return primes, stats.Tested.Load()
+}
+
+func TestSegmentedSieve(t *testing.T) {
+	defer pipelinetest.CheckLeaks(t)()
+	// The segments start at 11 rather than a multiple of their size, so 61 starts one and splits the twin pair 59, 61 across two segments.
+	// The range ends before 1021, leaving the pair 1019, 1021 out
+	const from, to = 11, 1021
+	for _, twins := range []bool{false, true} {
+		var want []int64
+		for num := int64(from); num < to; num++ {
+			if pipeline.DeterministicPrime(num) && (!twins || num+2 < to && pipeline.DeterministicPrime(num+2)) {
+				want = append(want, num)
+			}
+		}
+		if twins && (!slices.Contains(want, 59) || slices.Contains(want, 1019)) {
+			t.Fatalf("twin pairs %v should hold 59 and not 1019", want)
+		}
+		sieve := pipeline.SegmentedSieve{From: from, To: to, Size: 50, Twins: twins}
+		got, tested := segmentedPrimes(t, sieve, 3)
+		if !slices.Equal(got, want) {
+			t.Errorf("twins %v: sieved %v, want %v", twins, got, want)
+		}
+		if tested != to-from {
+			t.Errorf("twins %v: tested %d numbers, want the %d of the range", twins, tested, to-from)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// SemaphoreWorker is a FilterWorker testing up to n items at once: rather than n worker goroutines each with its own output stream to be fanned in,
+// a single dispatcher reads the stream and starts a goroutine per item, once a weighted semaphore of n has a slot free. Every goroutine sends on the same output stream.
+// The first error from the test is reported on the returned error channel, and stops the dispatcher and the goroutines still running.
+// The goroutines share stats, which may be nil, so SemaphoreWorker counts as one worker
+func SemaphoreWorker[T any](ctx context.Context, valueStream <-chan T, keep func(T) (bool, error), n int64, stats *Stats, opts ...Option) (<-chan T, <-chan error) {
+	o := applyOptions(opts)
+	keptStream := make(chan T, o.buffer)
+	errc := make(chan error, 1)
+	n = max(n, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "semaphore worker", "slots", n)()
+		defer close(keptStream)
+		defer close(errc)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sem := semaphore.NewWeighted(n)
+		var once sync.Once
+		fail := func(err error) {
+			once.Do(func() {
+				reportError(ctx, errc, err)
+				cancel()
+			})
+		}
+		// Waits for the goroutines still running, by taking every slot
+		defer sem.Acquire(context.Background(), n)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return
+			}
+			o.spawn(func() {
+				defer sem.Release(1)
+				if err := testItem(ctx, o, item, keep, stats, keptStream, nil); err != nil && ctx.Err() == nil {
+					fail(err)
+				}
+			})
+		}
+	})
+	return keptStream, errc
+}
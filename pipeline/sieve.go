@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"math"
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+// segmentSize is the number of values sieved by a worker at a time. It is a multiple of 64 so segments never share a word of the bit set
+const segmentSize = 1 << 18
+
+// Sieve is a bit set of the composite numbers from 0 to a limit, built with the sieve of Eratosthenes
+type Sieve struct {
+	limit     int64
+	composite []uint64
+	unmap     func() error // Unmaps the file of a sieve made by NewFileSieve, nil otherwise
+}
+
+// NewSieve sieves the numbers from 0 to limit (exclusive). The range is split into segments which are sieved concurrently by the given number of workers.
+// If the context is cancelled before every segment is done, the context's error is returned
+func NewSieve(ctx context.Context, limit int64, workers int) (*Sieve, error) {
+	s := &Sieve{limit: limit}
+	if limit <= 0 {
+		return s, nil
+	}
+	s.composite = make([]uint64, (limit+63)/64)
+	if err := s.sieve(ctx, workers); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// sieve marks the composites of the range in the zeroed bit set
+func (s *Sieve) sieve(ctx context.Context, workers int) error {
+	limit := s.limit
+	s.mark(0)
+	if limit > 1 {
+		s.mark(1)
+	}
+
+	// Primes up to the square root of the limit are enough to mark every composite in the range
+	base := smallPrimes(isqrt(limit - 1))
+
+	// Workers sieve the segments they are handed until the range is covered. The segments are handed out in ascending order,
+	// so the workers write to pages of the bit set close to each other, which keeps the pages of a file sieve being written few
+	var wg sync.WaitGroup
+	segments := make(chan int64)
+	workers = max(workers, 1)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for low := range segments {
+				s.sieveSegment(low, min(low+segmentSize, limit), base)
+			}
+		}()
+	}
+
+distribute:
+	for low := int64(0); low < limit; low += segmentSize {
+		select {
+		case <-ctx.Done():
+			break distribute
+		case segments <- low:
+		}
+	}
+	close(segments)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// IsPrime reports whether num is prime. Numbers outside of the sieved range are reported as not prime
+func (s *Sieve) IsPrime(num int64) bool {
+	if num < 0 || num >= s.limit {
+		return false
+	}
+	return s.composite[num/64]&(1<<(num%64)) == 0
+}
+
+// Primes returns the prime numbers in the sieved range in ascending order
+func (s *Sieve) Primes() []int64 {
+	return slices.Collect(s.PrimesFrom(0))
+}
+
+// PrimesFrom returns an iterator over the prime numbers of the sieved range from from up, in ascending order.
+// Unlike Primes it doesn't hold them all at once, which a range sieved to a file may have more of than fit in memory
+func (s *Sieve) PrimesFrom(from int64) iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		from = max(from, 0)
+		for word := from / 64; word < int64(len(s.composite)); word++ {
+			// The set bits of the inverted word are its primes, the ones below from cleared in its first word
+			primes := ^s.composite[word]
+			if word == from/64 {
+				primes &^= 1<<(from%64) - 1
+			}
+			for ; primes != 0; primes &= primes - 1 {
+				num := word*64 + int64(bits.TrailingZeros64(primes))
+				if num >= s.limit || !yield(num) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close releases the file of a sieve made by NewFileSieve, which mustn't be used afterwards. It does nothing for a sieve made by NewSieve
+func (s *Sieve) Close() error {
+	if s.unmap == nil {
+		return nil
+	}
+	unmap := s.unmap
+	s.unmap, s.composite, s.limit = nil, nil, 0
+	return unmap()
+}
+
+// mark records num as composite
+func (s *Sieve) mark(num int64) {
+	s.composite[num/64] |= 1 << (num % 64)
+}
+
+// sieveSegment marks the multiples of the base primes within low to high (exclusive)
+func (s *Sieve) sieveSegment(low, high int64, base []int64) {
+	for _, p := range base {
+		if p*p >= high {
+			break
+		}
+		// Start at the first multiple of p in the segment, leaving p itself unmarked
+		start := max(p*p, (low+p-1)/p*p)
+		for num := start; num < high; num += p {
+			s.mark(num)
+		}
+	}
+}
+
+// smallPrimes returns the primes up to and including limit, using a simple single-threaded sieve
+func smallPrimes(limit int64) []int64 {
+	if limit < 2 {
+		return nil
+	}
+	composite := make([]bool, limit+1)
+	var primes []int64
+	for num := int64(2); num <= limit; num++ {
+		if composite[num] {
+			continue
+		}
+		primes = append(primes, num)
+		for multiple := num * num; multiple <= limit; multiple += num {
+			composite[multiple] = true
+		}
+	}
+	return primes
+}
+
+// isqrt returns the integer square root of num, correcting for floating point error
+func isqrt(num int64) int64 {
+	if num < 0 {
+		return 0
+	}
+	root := int64(math.Sqrt(float64(num)))
+	for root*root > num {
+		root--
+	}
+	for (root+1)*(root+1) <= num {
+		root++
+	}
+	return root
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+)
+
+// NewFileSieve fails where there's no mmap, a sieve can only be kept in memory with NewSieve
+func NewFileSieve(ctx context.Context, limit int64, workers int, path string) (*Sieve, error) {
+	return nil, errors.New("sieving to a file needs mmap, which this system doesn't have")
+}
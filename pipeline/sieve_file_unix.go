@@ -0,0 +1,55 @@
+//go:build unix
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// NewFileSieve is NewSieve with the bit set kept in the file at path, mapped into memory, rather than allocated: the kernel writes the pages
+// sieved out to the file and reads them back as they're used, so a range needing more memory than the machine has (a bit per number,
+// 125GB for a trillion numbers) can still be sieved. The file is created, or truncated, to the size of the bit set, and is the caller's:
+// closing the sieve, which it must be to unmap it, leaves the file in place for the caller to remove. If the sieve can't be made,
+// or the context is cancelled first, the file is removed
+func NewFileSieve(ctx context.Context, limit int64, workers int, path string) (s *Sieve, err error) {
+	s = &Sieve{limit: max(limit, 0)}
+	size := (s.limit + 63) / 64 * 8
+	if size > math.MaxInt {
+		return nil, fmt.Errorf("sieving to %s: a bit set of %d bytes can't be mapped here", path, size)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// The mapping holds on to the file once it's made, the descriptor isn't needed past it
+	defer f.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	// Truncating to the size makes a sparse file, which reads as zeros: every number not marked composite yet
+	if err := f.Truncate(size); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return s, nil
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mapping %s: %w", path, err)
+	}
+	s.composite = unsafe.Slice((*uint64)(unsafe.Pointer(&data[0])), size/8)
+	s.unmap = func() error { return unix.Munmap(data) }
+	if err := s.sieve(ctx, workers); err != nil {
+		return nil, errors.Join(err, s.Close())
+	}
+	return s, nil
+}
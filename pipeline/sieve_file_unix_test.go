@@ -0,0 +1,29 @@
+//go:build unix
+
+package pipeline_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+func TestFileSieve(t *testing.T) {
+	const limit = 1_000_003
+	path := filepath.Join(t.TempDir(), "sieve.bits")
+	sieve, err := pipeline.NewFileSieve(context.Background(), limit, 4, path)
+	if err != nil {
+		t.Fatalf("NewFileSieve: %v", err)
+	}
+	checkSieve(t, sieve, limit)
+	if err := sieve.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// The file is the caller's to remove once the sieve is closed
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("sieve file gone once closed: %v", err)
+	}
+}
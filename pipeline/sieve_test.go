@@ -0,0 +1,27 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pbangia/go-concurrency-sample/pipeline"
+)
+
+// checkSieve fails the test unless the sieve agrees with DeterministicPrime on every number below limit
+func checkSieve(t *testing.T, sieve *pipeline.Sieve, limit int64) {
+	t.Helper()
+	for num := range limit {
+		if got, want := sieve.IsPrime(num), pipeline.DeterministicPrime(num); got != want {
+			t.Fatalf("IsPrime(%d) = %v, want %v", num, got, want)
+		}
+	}
+}
+
+func TestSieve(t *testing.T) {
+	const limit = 3_000_017
+	sieve, err := pipeline.NewSieve(context.Background(), limit, 4)
+	if err != nil {
+		t.Fatalf("NewSieve: %v", err)
+	}
+	checkSieve(t, sieve, limit)
+}
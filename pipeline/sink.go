@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SinkToFile is a terminal stage that writes each item of a stream to the file at path, one per line as formatted by fmt.Println.
+// Items are written to a temporary file in the same directory, which is renamed to path once the input stream closes.
+// If the context is cancelled first, or a write fails, the temporary file is removed instead, so a consumer never sees a partially written file at path.
+// The returned channel carries the error of a failed write, and is closed once the sink has finished (after the rename, if there was one)
+func SinkToFile[T any](ctx context.Context, valueStream <-chan T, path string, opts ...Option) <-chan error {
+	o := applyOptions(opts)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "file sink", "path", path)()
+		defer close(errc)
+		if err := sinkToFile(ctx, valueStream, path); err != nil {
+			errc <- err
+		}
+	})
+	return errc
+}
+
+// sinkToFile runs the SinkToFile stage, returning nil if the input stream ended cleanly or the context was cancelled
+func sinkToFile[T any](ctx context.Context, valueStream <-chan T, path string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	closed, renamed := false, false
+	defer func() {
+		if !closed {
+			err = errors.Join(err, tmp.Close())
+		}
+		if !renamed {
+			err = errors.Join(err, os.Remove(tmp.Name()))
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case item, ok := <-valueStream:
+			if !ok {
+				// Both cases can be ready once the context is cancelled, so check it again before keeping the file
+				if ctx.Err() != nil {
+					return nil
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				// CreateTemp makes the file readable by its owner only
+				if err := tmp.Chmod(0o644); err != nil {
+					return err
+				}
+				if err := tmp.Sync(); err != nil {
+					return err
+				}
+				closed = true
+				if err := tmp.Close(); err != nil {
+					return err
+				}
+				if err := os.Rename(tmp.Name(), path); err != nil {
+					return err
+				}
+				renamed = true
+				return nil
+			}
+			if _, err := fmt.Fprintln(w, item); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	cryptorand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CreateValueStream gets values from a specified getter, and queues the result on a stream of the getter's type.
+// If the getter fails, the error is reported on the returned error channel and the stream is closed.
+// A getter returning ErrExhausted closes the stream without reporting an error
+func CreateValueStream[T any](ctx context.Context, getValue func() (T, error), opts ...Option) (<-chan T, <-chan error) {
+	o := applyOptions(opts)
+	valStream := make(chan T, o.buffer)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "value stream")()
+		defer close(valStream)
+		defer close(errc)
+		for {
+			val, err := getValue() // Call getter, place result on stream
+			if errors.Is(err, ErrExhausted) {
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			if !send(ctx, o, valStream, val) {
+				return
+			}
+		}
+	})
+	return valStream, errc
+}
+
+// RandVal returns a function, which returns a random int from 0 to num. A range that isn't positive is reported as ErrInvalidInput
+func RandVal(num int64) func() (int64, error) {
+	return RandValBetween(0, num)
+}
+
+// RandValBetween is RandVal drawing from low (included) to high (excluded) rather than from 0, such as a window near 2^62.
+// An empty range is reported as ErrInvalidInput
+func RandValBetween(low, high int64) func() (int64, error) {
+	return func() (int64, error) {
+		if err := checkRange(low, high); err != nil {
+			return 0, err
+		}
+		return low + rand.Int63n(high-low), nil
+	}
+}
+
+// SeededRandVal returns a function, which returns a random int from 0 to num from a source seeded with seed, so the sequence of ints is reproducible.
+// Unlike RandVal, the function isn't safe for concurrent use and should only be called by one producer
+func SeededRandVal(num int64, seed int64) func() (int64, error) {
+	return SeededRandValBetween(0, num, seed)
+}
+
+// SeededRandValBetween is SeededRandVal drawing from low to high. With a low bound of 0 it draws the same sequence as SeededRandVal
+func SeededRandValBetween(low, high int64, seed int64) func() (int64, error) {
+	return RandValFrom(rand.New(rand.NewSource(seed)), low, high)
+}
+
+// Rand is a source of random ints, such as a *rand.Rand. The pipelinetest package has one returning scripted values
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+// RandValFrom is RandValBetween drawing from the given source. The function is only safe for concurrent use if the source is
+func RandValFrom(rng Rand, low, high int64) func() (int64, error) {
+	return func() (int64, error) {
+		if err := checkRange(low, high); err != nil {
+			return 0, err
+		}
+		return low + rng.Int63n(high-low), nil
+	}
+}
+
+// checkRange returns ErrInvalidInput if no int can be drawn from low (included) to high (excluded).
+// The size of the range must fit an int64, which it does for the non-negative ranges candidates come from
+func checkRange(low, high int64) error {
+	switch {
+	case low == 0 && high <= 0:
+		return fmt.Errorf("%w: range %d must be positive", ErrInvalidInput, high)
+	case high <= low || high-low <= 0:
+		return fmt.Errorf("%w: range %d-%d is empty", ErrInvalidInput, low, high)
+	}
+	return nil
+}
+
+// SubSeed derives an independent seed for the given index (a worker in our usage) from a parent seed, by mixing them with SplitMix64
+func SubSeed(seed int64, index int) int64 {
+	return int64(Mix64(uint64(seed) + uint64(index+1)*0x9e3779b97f4a7c15))
+}
+
+// CryptoRandVal returns a function, which returns a random int from 0 to num drawn from crypto/rand instead of math/rand.
+// Failing to read from the entropy source is returned as an error, as is a range that isn't positive (ErrInvalidInput)
+func CryptoRandVal(num int64) func() (int64, error) {
+	return CryptoRandValBetween(0, num)
+}
+
+// CryptoRandValBetween is CryptoRandVal drawing from low to high
+func CryptoRandValBetween(low, high int64) func() (int64, error) {
+	size := big.NewInt(high - low)
+	return func() (int64, error) {
+		if err := checkRange(low, high); err != nil {
+			return 0, err
+		}
+		val, err := cryptorand.Int(cryptorand.Reader, size)
+		if err != nil {
+			return 0, fmt.Errorf("reading crypto/rand: %w", err)
+		}
+		return low + val.Int64(), nil
+	}
+}
+
+// SequentialVal returns a function, which returns the ints from 0 to num in order and then ErrExhausted.
+// The function is safe to share between several producers, each int is only returned once
+func SequentialVal(num int64) func() (int64, error) {
+	return SequentialValFrom(0, num)
+}
+
+// SequentialValFrom is SequentialVal starting from start rather than 0, such as for a window of the range or when resuming a run that stopped part way through it
+func SequentialValFrom(start, num int64) func() (int64, error) {
+	var next atomic.Int64
+	next.Store(start)
+	return func() (int64, error) {
+		val := next.Add(1) - 1
+		if val >= num {
+			return 0, ErrExhausted
+		}
+		return val, nil
+	}
+}
+
+// ReaderVal returns a function, which returns the ints read from r, one per line, and then ErrExhausted once r is used up.
+// Blank lines are skipped. A line that isn't an int is returned as ErrInvalidInput, along with its line number, as is a failure to read from r.
+// The function is safe to share between several producers, each line is only returned once
+func ReaderVal(r io.Reader) func() (int64, error) {
+	var mu sync.Mutex
+	scanner := bufio.NewScanner(r)
+	line := 0
+	return func() (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			val, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w: line %d: %q is not an integer", ErrInvalidInput, line, text)
+			}
+			return val, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return 0, fmt.Errorf("reading input: %w", err)
+		}
+		return 0, ErrExhausted
+	}
+}
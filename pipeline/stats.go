@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts the work done by a worker. Several workers can share one Stats for a combined count, and counters are safe to read while the pipeline is running
+type Stats struct {
+	Tested      atomic.Int64 // Numbers checked by the worker
+	Found       atomic.Int64 // Prime numbers found by the worker
+	TestTime    atomic.Int64 // Nanoseconds the worker spent running its test (such as ProbablyPrime)
+	SendBlocked atomic.Int64 // Nanoseconds the worker spent waiting for the next stage to take a prime number
+	Restarts    atomic.Int64 // Times the worker was restarted by Supervise after a panic
+	Stolen      atomic.Int64 // Items a StealingWorkers worker took from another worker's deque
+
+	testStart atomic.Int64        // Unix nanoseconds when the worker started its current test, 0 while it isn't testing
+	watched   atomic.Bool         // Set by Watch, the worker then records the item it's testing
+	current   atomic.Pointer[any] // The item the worker is testing, when it's watched
+}
+
+// Watch makes the worker record the item it's testing, for Testing to return. Recording it costs an allocation per item,
+// so it's only done once a watchdog asks for it, starting with the worker's next test
+func (s *Stats) Watch() {
+	s.watched.Store(true)
+}
+
+// Testing returns when the worker started the test it's running, or false if it isn't running one (such as while it waits for input).
+// Each test a worker finishes is a heartbeat, so one started long ago means the worker is stuck on it.
+// item is the item being tested if the worker is watched (see Watch), nil otherwise
+func (s *Stats) Testing() (item any, since time.Time, ok bool) {
+	start := s.testStart.Load()
+	if start == 0 {
+		return nil, time.Time{}, false
+	}
+	if current := s.current.Load(); current != nil {
+		item = *current
+	}
+	return item, time.Unix(0, start), true
+}
+
+// startTest records the test a worker is starting on an item. It's a function rather than a method so the item is only
+// boxed in an any while the worker is watched
+func startTest[T any](s *Stats, item T, start time.Time) {
+	if s.watched.Load() {
+		var current any = item
+		s.current.Store(&current)
+	}
+	s.testStart.Store(start.UnixNano())
+}
+
+// endTest records that the worker finished its test, whether or not it succeeded
+func (s *Stats) endTest() {
+	s.testStart.Store(0)
+	if s.watched.Load() {
+		s.current.Store(nil)
+	}
+}
+
+// TestDuration returns the time the worker spent testing numbers
+func (s *Stats) TestDuration() time.Duration {
+	return time.Duration(s.TestTime.Load())
+}
+
+// SendBlockedTime returns the time the worker spent blocked sending prime numbers downstream
+func (s *Stats) SendBlockedTime() time.Duration {
+	return time.Duration(s.SendBlocked.Load())
+}
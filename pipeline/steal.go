@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// stealDequeSize is how many items a worker's deque holds, the distributor waits once every deque is full
+const stealDequeSize = 64
+
+// stealDeques are the deques of StealingWorkers, one per worker. The distributor pushes items onto the bottom of the deques in turn,
+// a worker pops its own from the bottom and steals from the top of the fullest deque when its own is empty.
+// One lock guards them all: the items are CPU-bound tests, far longer than a push or a pop
+type stealDeques[T any] struct {
+	mu      sync.Mutex
+	changed *sync.Cond // Broadcast on every push, pop and change of state
+	deques  [][]T
+	next    int  // Deque the distributor pushes onto next
+	closed  bool // The input stream is done, workers stop once the deques are empty
+	stopped bool // The context is done or a worker failed, everything stops
+}
+
+// push adds an item to the next deque with room, waiting for room if they're all full. It returns false once stopped
+func (d *stealDeques[T]) push(item T) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for !d.stopped {
+		for range d.deques {
+			i := d.next
+			d.next = (d.next + 1) % len(d.deques)
+			if len(d.deques[i]) < stealDequeSize {
+				d.deques[i] = append(d.deques[i], item)
+				d.changed.Broadcast()
+				return true
+			}
+		}
+		d.changed.Wait()
+	}
+	return false
+}
+
+// take returns the next item of a worker, from the bottom of its own deque or else stolen from the top of the fullest one.
+// It waits while the deques are empty, and returns false once they're empty and closed, or stopped
+func (d *stealDeques[T]) take(worker int) (item T, stolen, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for !d.stopped {
+		if own := d.deques[worker]; len(own) > 0 {
+			item, d.deques[worker] = own[len(own)-1], own[:len(own)-1]
+			d.changed.Broadcast()
+			return item, false, true
+		}
+		victim := -1
+		for i, deque := range d.deques {
+			if len(deque) > 0 && (victim < 0 || len(deque) > len(d.deques[victim])) {
+				victim = i
+			}
+		}
+		if victim >= 0 {
+			item, d.deques[victim] = d.deques[victim][0], d.deques[victim][1:]
+			d.changed.Broadcast()
+			return item, true, true
+		}
+		if d.closed {
+			break
+		}
+		d.changed.Wait()
+	}
+	var zero T
+	return zero, false, false
+}
+
+// finish marks the deques closed (no more items coming) or stopped
+func (d *stealDeques[T]) finish(stop bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.stopped = d.stopped || stop
+	d.changed.Broadcast()
+}
+
+// StealingWorkers runs one worker per stats (none of which may be nil) like a set of MapWorkers, each returning whether to keep its result, for items that vary widely in cost.
+// Rather than sharing one input stream, each worker has a deque a distributor fills in turn, and a worker whose deque runs dry steals from the fullest one,
+// so a worker stuck on an expensive item doesn't hold back the items queued behind it. Items taken from another worker's deque are counted in Stats.Stolen.
+// The first error (a panic in work included, as a PanicError) is reported on the returned error channel and stops every worker
+func StealingWorkers[In, Out any](ctx context.Context, valueStream <-chan In, work func(In) (Out, bool, error), stats []*Stats, opts ...Option) ([]<-chan Out, <-chan error) {
+	o := applyOptions(opts)
+	d := &stealDeques[In]{deques: make([][]In, len(stats))}
+	d.changed = sync.NewCond(&d.mu)
+	stop := context.AfterFunc(ctx, func() { d.finish(true) })
+	errc := make(chan error, 1)
+	var once sync.Once
+	fail := func(err error) {
+		once.Do(func() {
+			reportError(ctx, errc, err)
+			d.finish(true)
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	o.spawn(func() {
+		defer wg.Done()
+		defer logLifetime(ctx, o.logger, "steal distributor", "workers", len(stats))()
+		defer d.finish(false)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok || !d.push(item) {
+				return
+			}
+		}
+	})
+
+	outs := make([]<-chan Out, len(stats))
+	for worker, stats := range stats {
+		resultStream := make(chan Out, o.buffer)
+		outs[worker] = resultStream
+		wg.Add(1)
+		o.spawn(func() {
+			defer wg.Done()
+			defer logLifetime(ctx, o.logger, "stealing worker", "worker", worker)()
+			defer close(resultStream)
+			for {
+				item, stolen, ok := d.take(worker)
+				if !ok {
+					return
+				}
+				if stolen {
+					stats.Stolen.Add(1)
+				}
+				var result Out
+				found, err := runTest(item, func(item In) (bool, error) {
+					var keep bool
+					var err error
+					result, keep, err = work(item)
+					return keep, err
+				}, stats)
+				if err == nil && found {
+					err = sendItem(ctx, o, result, stats, resultStream)
+				}
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		})
+	}
+	o.spawn(func() {
+		wg.Wait()
+		stop()
+		close(errc)
+	})
+	return outs, errc
+}
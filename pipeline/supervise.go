@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+)
+
+// Supervise runs a worker started by start, forwarding its output, and starts it again if it stops on a panic (see PanicError), up to maxRestarts times.
+// The restarted worker reads on from the same input stream, so only the item the worker panicked on is lost.
+// Any other error, or a panic once the restarts are used up, is reported on the returned error channel. Restarts are counted in stats, which may be nil
+func Supervise[T any](ctx context.Context, start func() (<-chan T, <-chan error), maxRestarts int, stats *Stats, opts ...Option) (<-chan T, <-chan error) {
+	o := applyOptions(opts)
+	supervisedStream := make(chan T, o.buffer)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "supervisor", "max_restarts", maxRestarts)()
+		defer close(supervisedStream)
+		defer close(errc)
+		for restarts := 0; ; restarts++ {
+			workerStream, workerErrs := start()
+			for {
+				item, ok := receive(ctx, o, workerStream)
+				if !ok {
+					break
+				}
+				if !send(ctx, o, supervisedStream, item) {
+					return
+				}
+			}
+			// The worker's error is queued before its streams are closed
+			err := <-workerErrs
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, ErrPanicked) || restarts == maxRestarts {
+				reportError(ctx, errc, err)
+				return
+			}
+			o.logger.Warn("restarting worker after a panic", "err", err, "restart", restarts+1, "max_restarts", maxRestarts)
+			if stats != nil {
+				stats.Restarts.Add(1)
+			}
+		}
+	})
+	return supervisedStream, errc
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Throttle forwards the items of a stream in order, at no more than ratePerSec items per second on average, using a token bucket of burst tokens.
+// The bucket starts full so the first burst items go straight through, and it refills while the stage waits on either side, up to burst again.
+// Holding items back blocks the stage feeding it, so the limit backs up the pipeline to the producers (backpressure) rather than dropping anything.
+// A burst below 1 is taken as 1, and a rate that isn't positive forwards items without a limit
+func Throttle[T any](ctx context.Context, valueStream <-chan T, ratePerSec float64, burst int, opts ...Option) <-chan T {
+	o := applyOptions(opts)
+	throttledStream := make(chan T, o.buffer)
+	burst = max(burst, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "throttle", "rate", ratePerSec, "burst", burst)()
+		defer close(throttledStream)
+		tokens, last := float64(burst), o.clock.Now()
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			if ratePerSec > 0 {
+				now := o.clock.Now()
+				tokens = min(float64(burst), tokens+now.Sub(last).Seconds()*ratePerSec)
+				last = now
+				if tokens < 1 {
+					// Wait for the bucket to fill up to one token
+					wait := time.Duration((1 - tokens) / ratePerSec * float64(time.Second))
+					timer := o.clock.NewTimer(wait)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return
+					case <-timer.C():
+					}
+					tokens, last = 1, last.Add(wait)
+				}
+				tokens--
+			}
+			if !send(ctx, o, throttledStream, item) {
+				return
+			}
+		}
+	})
+	return throttledStream
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+)
+
+// uniqueEntryBytes is roughly the memory a value takes in UniqueValBetween's hash set, including the map's spare capacity
+const uniqueEntryBytes = 16
+
+// UniqueValBetween wraps a getter drawing from low (included) to high (excluded), such as RandValBetween, so it never returns the same int twice:
+// a value drawn again is skipped for another draw. The values returned are remembered in a bitset of the range if it fits in budget bytes,
+// and in a hash set otherwise. Once every int of the range has been returned it returns ErrExhausted.
+// A hash set holding budget bytes' worth of values stops remembering new ones, which can then come up again, so memory stays within the budget.
+// A value drawn outside of the range is returned as ErrInvalidInput.
+// The function is safe to share between several producers if getValue is, each int is only returned once
+func UniqueValBetween(getValue func() (int64, error), low, high int64, budget int64) func() (int64, error) {
+	var mu sync.Mutex
+	size := high - low
+	var bits []uint64
+	var set map[int64]struct{}
+	if size > 0 && (size+63)/64*8 <= budget {
+		bits = make([]uint64, (size+63)/64)
+	} else {
+		set = make(map[int64]struct{})
+	}
+	var returned int64
+	return func() (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if bits != nil && returned == size {
+			return 0, ErrExhausted
+		}
+		for {
+			val, err := getValue()
+			if err != nil {
+				return 0, err
+			}
+			if val < low || val >= high {
+				return 0, fmt.Errorf("%w: %d drawn outside of %d-%d", ErrInvalidInput, val, low, high)
+			}
+			if bits != nil {
+				i := val - low
+				if bits[i/64]&(1<<(i%64)) != 0 {
+					continue
+				}
+				bits[i/64] |= 1 << (i % 64)
+			} else {
+				if _, ok := set[val]; ok {
+					continue
+				}
+				if int64(len(set)+1)*uniqueEntryBytes <= budget {
+					set[val] = struct{}{}
+				}
+			}
+			returned++
+			return val, nil
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package pipeline
+
+import "context"
+
+// WheelModulus is the product of the primes the wheel is built from, 2·3·5·7. Of any WheelModulus consecutive numbers, 48 have none of them as a factor
+const WheelModulus = 2 * 3 * 5 * 7
+
+// wheelSpokes marks the residues modulo WheelModulus of the numbers with none of 2, 3, 5 and 7 as a factor
+var wheelSpokes = func() (spokes [WheelModulus]bool) {
+	for residue := range spokes {
+		spokes[residue] = residue%2 != 0 && residue%3 != 0 && residue%5 != 0 && residue%7 != 0
+	}
+	return spokes
+}()
+
+// OnWheel reports whether num can be prime as far as the wheel tells: it isn't a multiple of 2, 3, 5 or 7, or it's one of those primes.
+// 0 and 1 aren't on it. Negative numbers are, so the workers still report them as invalid input
+func OnWheel(num int64) bool {
+	switch {
+	case num < 0:
+		return true
+	case num <= 7:
+		return num == 2 || num == 3 || num == 5 || num == 7
+	case num&1 == 0:
+		return false
+	}
+	return wheelSpokes[num%WheelModulus]
+}
+
+// WheelFilter forwards the numbers of a stream that are on the wheel (see OnWheel), a cheap pre-filter ahead of the workers' primality test:
+// the multiples of 2, 3, 5 and 7 it drops, 77% of the numbers, are never tested. The numbers dropped are passed to the stage's WithDiscard function
+func WheelFilter(ctx context.Context, intStream <-chan int64, opts ...Option) <-chan int64 {
+	return Filter(ctx, intStream, OnWheel, opts...)
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// WindowByCount groups the items of a stream into consecutive windows of n items, sending each one as a slice once it's full.
+// The last window can be partial, it's sent when the input stream closes. It's a Batch that never sends a batch early,
+// for when each slice should cover the same number of items, such as to compare the primes among every thousand candidates
+func WindowByCount[T any](ctx context.Context, valueStream <-chan T, n int, opts ...Option) <-chan []T {
+	return Batch(ctx, valueStream, n, 0, opts...)
+}
+
+// WindowByTime groups the items of a stream into consecutive windows of d, starting when the stage does, sending each one as a slice when it ends.
+// A window no item came in is sent as a nil slice, so the stage after it sees every window (such as to report how many items came in the last 10s
+// even when there were none). The next window starts once the stage after it has taken the last one, the input waits meanwhile.
+// The window in progress is sent when the input stream closes, even if it's empty
+func WindowByTime[T any](ctx context.Context, valueStream <-chan T, d time.Duration, opts ...Option) <-chan []T {
+	o := applyOptions(opts)
+	windowStream := make(chan []T, o.buffer)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "time window", "window", d)()
+		defer close(windowStream)
+		var window []T
+		timer := o.clock.NewTimer(d)
+		defer func() { timer.Stop() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-valueStream:
+				if !ok {
+					send(ctx, o, windowStream, window)
+					return
+				}
+				window = append(window, item)
+			case <-timer.C():
+				if !send(ctx, o, windowStream, window) {
+					return
+				}
+				window = nil
+				timer = o.clock.NewTimer(d)
+			}
+		}
+	})
+	return windowStream
+}
@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PrimeNumberWorker reads an input stream of numbers and outputs a stream of prime numbers it finds, checking each number with the given test.
+// A negative number is reported as ErrInvalidInput on the returned error channel, and the worker stops.
+// The worker's progress is added to stats, which may be nil
+func PrimeNumberWorker(ctx context.Context, intStream <-chan int64, isPrime PrimalityTest, stats *Stats, opts ...Option) (<-chan int64, <-chan error) {
+	return FilterWorker(ctx, intStream, primeFilter(isPrime), stats, opts...)
+}
+
+// PrimeNumberBatchWorker is a PrimeNumberWorker that reads batches of numbers (see Batch), cutting the cost of channel synchronization on large runs
+func PrimeNumberBatchWorker(ctx context.Context, batchStream <-chan []int64, isPrime PrimalityTest, stats *Stats, opts ...Option) (<-chan int64, <-chan error) {
+	return FilterBatchWorker(ctx, batchStream, primeFilter(isPrime), stats, opts...)
+}
+
+// FilterBatchWorker is a FilterWorker that reads batches of items (see Batch), outputting the items of each batch that pass the keep test one at a time
+func FilterBatchWorker[T any](ctx context.Context, batchStream <-chan []T, keep func(T) (bool, error), stats *Stats, opts ...Option) (<-chan T, <-chan error) {
+	return PartitionBatchWorker(ctx, batchStream, keep, nil, stats, opts...)
+}
+
+// PartitionBatchWorker is a PartitionWorker that reads batches of items (see Batch)
+func PartitionBatchWorker[T any](ctx context.Context, batchStream <-chan []T, keep func(T) (bool, error), rejected chan<- T, stats *Stats, opts ...Option) (<-chan T, <-chan error) {
+	o := applyOptions(opts)
+	keptStream := make(chan T, o.buffer)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "batch worker")()
+		defer close(keptStream)
+		defer close(errc)
+		for {
+			batch, ok := receive(ctx, o, batchStream)
+			if !ok {
+				return
+			}
+			for _, item := range batch {
+				if err := testItem(ctx, o, item, keep, stats, keptStream, rejected); err != nil {
+					reportError(ctx, errc, err)
+					return
+				}
+			}
+		}
+	})
+	return keptStream, errc
+}
+
+// FilterWorker reads an input stream and outputs the items that pass the keep test, generalizing PrimeNumberWorker to any item type (such as an Item envelope).
+// An error from the test is reported on the returned error channel, and the worker stops. The worker's progress is added to stats, which may be nil
+func FilterWorker[T any](ctx context.Context, valueStream <-chan T, keep func(T) (bool, error), stats *Stats, opts ...Option) (<-chan T, <-chan error) {
+	return PartitionWorker(ctx, valueStream, keep, nil, stats, opts...)
+}
+
+// PartitionWorker is a FilterWorker with a second output: the items failing the keep test are sent on rejected (such as the composites among the candidates)
+// rather than dropped. The worker waits for rejected to take an item as it waits for its kept stream, and doesn't close it:
+// rejected is the caller's, who closes it once every worker sending on it has stopped, so several workers can share it
+// and a worker restarted by Supervise carries on sending on it. A nil rejected drops the items as FilterWorker does
+func PartitionWorker[T any](ctx context.Context, valueStream <-chan T, keep func(T) (bool, error), rejected chan<- T, stats *Stats, opts ...Option) (<-chan T, <-chan error) {
+	o := applyOptions(opts)
+	keptStream := make(chan T, o.buffer)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "worker")()
+		defer close(keptStream)
+		defer close(errc)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			if err := testItem(ctx, o, item, keep, stats, keptStream, rejected); err != nil {
+				reportError(ctx, errc, err)
+				return
+			}
+		}
+	})
+	return keptStream, errc
+}
+
+// MapWorker reads an input stream and outputs the result of fn for every item, for CPU-bound work that transforms items rather than filters them
+// (such as FactorWorker). Fanning out several MapWorkers doesn't keep the order of the input stream, unlike Map.
+// An error from fn is reported on the returned error channel, and the worker stops. The worker's progress is added to stats, which may be nil,
+// with every item counted as found
+func MapWorker[In, Out any](ctx context.Context, valueStream <-chan In, fn func(In) (Out, error), stats *Stats, opts ...Option) (<-chan Out, <-chan error) {
+	o := applyOptions(opts)
+	mappedStream := make(chan Out, o.buffer)
+	errc := make(chan error, 1)
+	o.spawn(func() {
+		defer logLifetime(ctx, o.logger, "map worker")()
+		defer close(mappedStream)
+		defer close(errc)
+		for {
+			item, ok := receive(ctx, o, valueStream)
+			if !ok {
+				return
+			}
+			var result Out
+			_, err := runTest(item, func(item In) (bool, error) {
+				var err error
+				result, err = fn(item)
+				return true, err
+			}, stats)
+			if err == nil {
+				err = sendItem(ctx, o, result, stats, mappedStream)
+			}
+			if err != nil {
+				reportError(ctx, errc, err)
+				return
+			}
+		}
+	})
+	return mappedStream, errc
+}
+
+// primeFilter adapts a PrimalityTest to the test of a FilterWorker, rejecting negative numbers as ErrInvalidInput
+func primeFilter(isPrime PrimalityTest) func(int64) (bool, error) {
+	return func(num int64) (bool, error) {
+		if num < 0 {
+			return false, fmt.Errorf("%w: negative candidate %d", ErrInvalidInput, num)
+		}
+		return isPrime(num), nil
+	}
+}
+
+// testItem checks an item for a worker, sending it on the worker's stream if it passes the test, or on rejected (unless it's nil) if it doesn't.
+// It returns an error when the worker should stop, either because the test failed or the context was cancelled
+func testItem[T any](ctx context.Context, o stageOptions, item T, keep func(T) (bool, error), stats *Stats, keptStream, rejected chan<- T) error {
+	found, err := runTest(item, keep, stats)
+	switch {
+	case err != nil:
+		return err
+	case found:
+		return sendItem(ctx, o, item, stats, keptStream)
+	case rejected != nil && !send(ctx, o, rejected, item):
+		return ctx.Err()
+	}
+	return nil
+}
+
+// runTest runs a worker's test on an item, counting it in the worker's stats (which may be nil).
+// A panic in the test is recovered and returned as a PanicError, so it stops the worker rather than the process
+func runTest[T any](item T, keep func(T) (bool, error), stats *Stats) (found bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			found, err = false, &PanicError{Item: item, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	// Check if prime number found
+	testStart := time.Now()
+	if stats != nil {
+		startTest(stats, item, testStart)
+		defer stats.endTest()
+	}
+	found, err = keep(item)
+	if err != nil {
+		return false, err
+	}
+	if stats != nil {
+		stats.TestTime.Add(int64(time.Since(testStart)))
+		stats.Tested.Add(1)
+		if found {
+			stats.Found.Add(1)
+		}
+	}
+	return found, nil
+}
+
+// sendItem sends an item a worker kept downstream, recording how long the worker was blocked in its stats (which may be nil)
+func sendItem[T any](ctx context.Context, o stageOptions, item T, stats *Stats, keptStream chan<- T) error {
+	sendStart := time.Now()
+	if !send(ctx, o, keptStream, item) {
+		return ctx.Err()
+	}
+	if stats != nil {
+		stats.SendBlocked.Add(int64(time.Since(sendStart)))
+	}
+	return nil
+}
+
+// reportError queues a stage's error on its error channel, unless the stage stopped because the context was cancelled (which isn't a failure).
+// The channel holds one error, any further errors are dropped rather than blocking the stage
+func reportError(ctx context.Context, errc chan<- error, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case errc <- err:
+	default:
+	}
+}
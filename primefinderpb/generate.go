@@ -0,0 +1,7 @@
+// Package primefinderpb holds the protobuf messages and gRPC service of the PrimeFinder API, generated from primefinder.proto,
+// and the messages of the CLI's proto output, generated from results.proto
+package primefinderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative primefinder.proto
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative coordinator.proto
+//go:generate protoc --go_out=. --go_opt=paths=source_relative results.proto